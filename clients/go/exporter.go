@@ -0,0 +1,571 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrExporterClosed = errors.New("scopion: exporter is shut down")
+
+/*
+* ExporterOptions tunes the background exporter,
+* zero values fall back to defaults
+**/
+type ExporterOptions struct {
+	/*
+	* events waiting to be sent, beyond this Export drops them
+	**/
+	BufferSize int
+
+	BatchSize     int
+	FlushInterval time.Duration
+
+	/*
+	* Deprecated: each batch is sent in a single request,
+	* this is ignored
+	**/
+	Concurrency int
+
+	/*
+	* attempts after the first for events the server answered
+	* 429 or 5xx, or that failed to reach it
+	**/
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	/*
+	* per request
+	**/
+	Timeout time.Duration
+
+	/*
+	* called for events given up on, from the exporter goroutine
+	**/
+	OnError func(Event, error)
+
+	/*
+	* when set, events the server couldn't take after every retry,
+	* or that didn't fit in the buffer, are written here and sent in
+	* order once it is reachable again. while anything is spooled new
+	* events are spooled behind it. overflowing events are written in
+	* the background, BufferSize of them can wait for it.
+	* the exporter doesn't close it
+	**/
+	Spool *Spool
+}
+
+func (o *ExporterOptions) applyDefaults() {
+	if o.BufferSize <= 0 {
+		o.BufferSize = 4096
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = 100
+	}
+	if o.FlushInterval <= 0 {
+		o.FlushInterval = time.Second
+	}
+	if o.MaxRetries <= 0 {
+		o.MaxRetries = 5
+	}
+	if o.InitialBackoff <= 0 {
+		o.InitialBackoff = 100 * time.Millisecond
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = 10 * time.Second
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 10 * time.Second
+	}
+}
+
+type ExporterStats struct {
+	Queued  int
+	Sent    uint64
+	Retried uint64
+
+	/*
+	* events that didn't fit in the buffer
+	**/
+	Dropped uint64
+
+	/*
+	* events rejected by the server or out of retries
+	**/
+	Failed uint64
+
+	/*
+	* events written to the spool, nil without one
+	**/
+	Spooled uint64
+	Spool   *SpoolStats
+}
+
+/*
+* Exporter sends events in the background so callers never wait on
+* the server, a batch at a time to the batch endpoint. events get an
+* id up front, so a retried event is stored once
+**/
+type Exporter struct {
+	client *Client
+	opts   ExporterOptions
+
+	events  chan Event
+	flushes chan chan struct{}
+	done    chan struct{}
+
+	/*
+	* events that didn't fit in the buffer, written to the spool by
+	* writeOverflow so Export never waits on the disk. nil without a spool
+	**/
+	overflow        chan Event
+	overflowFlushes chan chan struct{}
+	overflowDone    chan struct{}
+
+	/*
+	* cancelled when Shutdown runs out of time, aborting retries
+	**/
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.RWMutex
+	closed bool
+
+	sent    atomic.Uint64
+	retried atomic.Uint64
+	dropped atomic.Uint64
+	failed  atomic.Uint64
+	spooled atomic.Uint64
+}
+
+func (c *Client) NewExporter(opts ExporterOptions) *Exporter {
+	opts.applyDefaults()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &Exporter{
+		client:  c,
+		opts:    opts,
+		events:  make(chan Event, opts.BufferSize),
+		flushes: make(chan chan struct{}),
+		done:    make(chan struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+
+	if opts.Spool != nil {
+		e.overflow = make(chan Event, opts.BufferSize)
+		e.overflowFlushes = make(chan chan struct{})
+		e.overflowDone = make(chan struct{})
+		go e.writeOverflow()
+	}
+
+	go e.run()
+
+	return e
+}
+
+/*
+* Export queues an event without blocking. it returns false, and
+* counts the event as dropped, when the buffer (and the spool's
+* overflow buffer) is full or the exporter is shut down.
+* id and timestamp are filled in when empty
+**/
+func (e *Exporter) Export(ev Event) bool {
+	if ev.ID == "" {
+		ev.ID = newEventID()
+	}
+	if ev.Timestamp.IsZero() {
+		ev.Timestamp = time.Now().UTC()
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.closed {
+		e.dropped.Add(1)
+		return false
+	}
+
+	select {
+	case e.events <- ev:
+		return true
+	default:
+	}
+
+	select {
+	case e.overflow <- ev:
+		return true
+	default:
+		e.dropped.Add(1)
+		return false
+	}
+}
+
+/*
+* Flush sends everything queued so far and waits until it
+* is delivered or given up on, or ctx is done
+**/
+func (e *Exporter) Flush(ctx context.Context) error {
+	done := make(chan struct{})
+
+	select {
+	case e.flushes <- done:
+	case <-e.done:
+		return ErrExporterClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+/*
+* Shutdown stops accepting events and sends what is queued. when
+* ctx is done first, retries are abandoned and the rest is dropped
+**/
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return ErrExporterClosed
+	}
+	e.closed = true
+	close(e.events)
+	if e.overflow != nil {
+		close(e.overflow)
+	}
+	e.mu.Unlock()
+
+	select {
+	case <-e.done:
+		e.cancel()
+		return nil
+	case <-ctx.Done():
+		e.cancel()
+		<-e.done
+		return ctx.Err()
+	}
+}
+
+func (e *Exporter) Stats() ExporterStats {
+	stats := ExporterStats{
+		Queued:  len(e.events) + len(e.overflow),
+		Sent:    e.sent.Load(),
+		Retried: e.retried.Load(),
+		Dropped: e.dropped.Load(),
+		Failed:  e.failed.Load(),
+		Spooled: e.spooled.Load(),
+	}
+	if e.opts.Spool != nil {
+		spool := e.opts.Spool.Stats()
+		stats.Spool = &spool
+	}
+	return stats
+}
+
+func (e *Exporter) run() {
+	defer close(e.done)
+
+	ticker := time.NewTicker(e.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Event, 0, e.opts.BatchSize)
+	for {
+		select {
+		case ev, ok := <-e.events:
+			if !ok {
+				e.send(batch)
+				if e.overflowDone != nil {
+					<-e.overflowDone
+				}
+				return
+			}
+			batch = append(batch, ev)
+			if len(batch) >= e.opts.BatchSize {
+				e.send(batch)
+				batch = batch[:0]
+			}
+
+		case <-ticker.C:
+			e.send(batch)
+			batch = batch[:0]
+			e.drain()
+
+		case flushed := <-e.flushes:
+			/*
+			* everything queued before the flush was asked for
+			**/
+			for range len(e.events) {
+				batch = append(batch, <-e.events)
+				if len(batch) >= e.opts.BatchSize {
+					e.send(batch)
+					batch = batch[:0]
+				}
+			}
+			e.send(batch)
+			batch = batch[:0]
+			e.flushOverflow()
+			e.drain()
+			close(flushed)
+		}
+	}
+}
+
+func (e *Exporter) send(batch []Event) {
+	if len(batch) == 0 {
+		return
+	}
+
+	/*
+	* behind a backlog, so the server sees events in order
+	**/
+	if e.opts.Spool != nil && e.opts.Spool.Stats().Events > 0 && e.spool(batch...) {
+		return
+	}
+
+	e.deliver(batch)
+}
+
+/*
+* sends batch, trying the events the server couldn't take for
+* now again until they run out of attempts
+**/
+func (e *Exporter) deliver(batch []Event) {
+	for attempt := 0; len(batch) > 0; attempt++ {
+		errs := e.post(batch)
+
+		var retry, spool []Event
+		var retryErr error
+		var spoolErrs []error
+		for i, ev := range batch {
+			err := errs[i]
+			switch {
+			case err == nil:
+				e.sent.Add(1)
+			case !IsRetryable(err):
+				e.fail(ev, err)
+			case attempt < e.opts.MaxRetries && e.ctx.Err() == nil:
+				retry = append(retry, ev)
+				if retryErr == nil || retryAfter(err) > retryAfter(retryErr) {
+					retryErr = err
+				}
+			case e.opts.Spool != nil:
+				spool = append(spool, ev)
+				spoolErrs = append(spoolErrs, err)
+			default:
+				e.fail(ev, err)
+			}
+		}
+
+		if len(spool) > 0 && !e.spool(spool...) {
+			for i, ev := range spool {
+				e.fail(ev, spoolErrs[i])
+			}
+		}
+
+		if len(retry) == 0 {
+			return
+		}
+		e.retried.Add(uint64(len(retry)))
+		select {
+		case <-time.After(e.backoff(attempt, retryErr)):
+		case <-e.ctx.Done():
+		}
+		batch = retry
+	}
+}
+
+/*
+* the error of each event is at its index,
+* a failed request fails every event in it
+**/
+func (e *Exporter) post(events []Event) []error {
+	ctx, cancel := context.WithTimeout(e.ctx, e.opts.Timeout)
+	errs, err := e.client.sendBatch(ctx, events)
+	cancel()
+
+	if errs == nil {
+		errs = make([]error, len(events))
+	}
+	if err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+	}
+	return errs
+}
+
+func (e *Exporter) fail(ev Event, err error) {
+	e.failed.Add(1)
+	if e.opts.OnError != nil {
+		e.opts.OnError(ev, err)
+	}
+}
+
+/*
+* spools overflowing events in batches of what has piled up
+**/
+func (e *Exporter) writeOverflow() {
+	defer close(e.overflowDone)
+
+	for {
+		select {
+		case ev, ok := <-e.overflow:
+			if !ok {
+				return
+			}
+			e.spoolOverflow(e.takeOverflow([]Event{ev}, e.opts.BatchSize))
+
+		case flushed := <-e.overflowFlushes:
+			for pending := len(e.overflow); pending > 0; {
+				batch := e.takeOverflow(nil, min(pending, e.opts.BatchSize))
+				if len(batch) == 0 {
+					break
+				}
+				pending -= len(batch)
+				e.spoolOverflow(batch)
+			}
+			close(flushed)
+		}
+	}
+}
+
+/*
+* adds events already waiting to batch until it holds n, never blocks
+**/
+func (e *Exporter) takeOverflow(batch []Event, n int) []Event {
+	for len(batch) < n {
+		select {
+		case ev, ok := <-e.overflow:
+			if !ok {
+				return batch
+			}
+			batch = append(batch, ev)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+/*
+* events the spool can't take are counted as dropped
+**/
+func (e *Exporter) spoolOverflow(batch []Event) {
+	if !e.spool(batch...) {
+		e.dropped.Add(uint64(len(batch)))
+	}
+}
+
+/*
+* waits until events that overflowed before now are spooled
+**/
+func (e *Exporter) flushOverflow() {
+	if e.overflow == nil {
+		return
+	}
+
+	flushed := make(chan struct{})
+	select {
+	case e.overflowFlushes <- flushed:
+		<-flushed
+	case <-e.overflowDone:
+	}
+}
+
+/*
+* reports whether events were written to the spool
+**/
+func (e *Exporter) spool(events ...Event) bool {
+	if e.opts.Spool == nil || e.opts.Spool.Append(events...) != nil {
+		return false
+	}
+	e.spooled.Add(uint64(len(events)))
+	return true
+}
+
+/*
+* sends spooled events oldest first, a batch at a time. it stops
+* at the first the server can't take for now, which is tried again
+* on the next flush. events behind it that the server took are sent
+* again with it, their ids keep them from being stored twice
+**/
+func (e *Exporter) drain() {
+	if e.opts.Spool == nil {
+		return
+	}
+
+	for e.ctx.Err() == nil {
+		events, err := e.opts.Spool.Next(e.opts.BatchSize)
+		if err != nil || len(events) == 0 {
+			return
+		}
+
+		errs := e.post(events)
+		for i, ev := range events {
+			switch err := errs[i]; {
+			case err == nil:
+				e.sent.Add(1)
+			case IsRetryable(err):
+				e.opts.Spool.Ack(i)
+				return
+			default:
+				e.fail(ev, err)
+			}
+		}
+		e.opts.Spool.Ack(len(events))
+	}
+}
+
+/*
+* exponential backoff with full jitter, a Retry-After
+* from the server is honoured when it is longer
+**/
+func (e *Exporter) backoff(attempt int, err error) time.Duration {
+	ceiling := min(e.opts.MaxBackoff, e.opts.InitialBackoff<<min(attempt, 16))
+	wait := rand.N(ceiling) + 1
+
+	if after := retryAfter(err); after > wait {
+		wait = min(after, e.opts.MaxBackoff)
+	}
+	return wait
+}
+
+/*
+* how long the server asked to wait, 0 when it didn't
+**/
+func retryAfter(err error) time.Duration {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.RetryAfter
+	}
+	return 0
+}
+
+var errExportDropped = errors.New("scopion: event dropped by exporter")
+
+/*
+* queues ev on exporter when there is one, otherwise sends it now
+**/
+func (c *Client) emit(ctx context.Context, exporter *Exporter, ev Event) error {
+	if exporter == nil {
+		return c.send(ctx, ev)
+	}
+	if !exporter.Export(ev) {
+		return errExportDropped
+	}
+	return nil
+}
+
+func newEventID() string {
+	return randomHex(16)
+}
@@ -0,0 +1,292 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xonoxc/scopion/clients/go/types"
+)
+
+/*
+* records events by id, failing the first attempts of each
+* with the given status. takes single events and batches
+**/
+type ingestServer struct {
+	mu       sync.Mutex
+	failures int
+	status   int
+	attempts map[string]int
+	events   map[string]Event
+	requests int
+}
+
+func newIngestServer(t *testing.T, failures, status int) (*ingestServer, *Client) {
+	t.Helper()
+
+	s := &ingestServer{failures: failures, status: status, attempts: map[string]int{}, events: map[string]Event{}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.requests++
+
+		if r.URL.Path == "/ingest/batch" {
+			var events []Event
+			if err := json.NewDecoder(r.Body).Decode(&events); err != nil {
+				w.WriteHeader(400)
+				return
+			}
+			result := types.BatchResult{Rejected: []types.BatchRejection{}}
+			for i, e := range events {
+				if status := s.take(e.ID, e); status != 0 {
+					result.Rejected = append(result.Rejected, types.BatchRejection{Index: i, Status: status, Error: "try later"})
+					continue
+				}
+				result.Accepted++
+			}
+			w.WriteHeader(202)
+			json.NewEncoder(w).Encode(result)
+			return
+		}
+
+		var e Event
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			w.WriteHeader(400)
+			return
+		}
+		if status := s.take(r.Header.Get("Idempotency-Key"), e); status != 0 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(status)
+			return
+		}
+		w.WriteHeader(202)
+	}))
+	t.Cleanup(server.Close)
+
+	return s, NewClient(server.URL)
+}
+
+/*
+* stores e under key once its failures are used up,
+* or returns the status it fails with
+**/
+func (s *ingestServer) take(key string, e Event) int {
+	s.attempts[key]++
+	if s.attempts[key] <= s.failures {
+		return s.status
+	}
+	s.events[key] = e
+	return 0
+}
+
+/*
+* answers a batch as taken whole, returning its events
+**/
+func acceptBatch(w http.ResponseWriter, r *http.Request) []Event {
+	var events []Event
+	json.NewDecoder(r.Body).Decode(&events)
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(types.BatchResult{Accepted: len(events), Rejected: []types.BatchRejection{}})
+	return events
+}
+
+func (s *ingestServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.events)
+}
+
+func fastRetries(opts ExporterOptions) ExporterOptions {
+	opts.InitialBackoff = time.Millisecond
+	opts.MaxBackoff = 5 * time.Millisecond
+	return opts
+}
+
+func TestExporterFlushesBatches(t *testing.T) {
+	server, client := newIngestServer(t, 0, 0)
+	exporter := client.NewExporter(ExporterOptions{BatchSize: 3, FlushInterval: time.Hour})
+
+	for range 5 {
+		if !exporter.Export(Event{Level: "info", Service: "api", Name: "request"}) {
+			t.Fatal("Expected event to be queued")
+		}
+	}
+
+	if err := exporter.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if server.count() != 5 {
+		t.Fatalf("Expected 5 events after flush, got %d", server.count())
+	}
+	if server.requests != 2 {
+		t.Errorf("Expected a request per batch, got %d", server.requests)
+	}
+	for id, e := range server.events {
+		if e.ID != id || e.Timestamp.IsZero() {
+			t.Errorf("Expected id and timestamp to be filled in, got %+v", e)
+		}
+	}
+
+	if err := exporter.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if stats := exporter.Stats(); stats.Sent != 5 || stats.Dropped != 0 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestExporterRetriesWithSameKey(t *testing.T) {
+	server, client := newIngestServer(t, 2, http.StatusServiceUnavailable)
+	exporter := client.NewExporter(fastRetries(ExporterOptions{}))
+
+	exporter.Export(Event{ID: "evt-1", Level: "info", Service: "api", Name: "request"})
+	if err := exporter.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if server.attempts["evt-1"] != 3 || server.count() != 1 {
+		t.Errorf("Expected 3 attempts storing one event, got %d attempts", server.attempts["evt-1"])
+	}
+	if stats := exporter.Stats(); stats.Sent != 1 || stats.Retried != 2 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestExporterGivesUpOnClientErrors(t *testing.T) {
+	server, client := newIngestServer(t, 10, http.StatusBadRequest)
+
+	var failed []error
+	exporter := client.NewExporter(fastRetries(ExporterOptions{
+		OnError: func(_ Event, err error) { failed = append(failed, err) },
+	}))
+
+	exporter.Export(Event{ID: "bad", Service: "api", Name: "request"})
+	exporter.Shutdown(context.Background())
+
+	if server.attempts["bad"] != 1 {
+		t.Errorf("Expected no retries for a 400, got %d attempts", server.attempts["bad"])
+	}
+	if len(failed) != 1 || exporter.Stats().Failed != 1 {
+		t.Errorf("Expected the event to be reported as failed, got %v", failed)
+	}
+	if apiErr, ok := failed[0].(*APIError); !ok || apiErr.StatusCode != 400 {
+		t.Errorf("Expected an api error, got %v", failed[0])
+	}
+}
+
+func TestExporterFailsOnlyRejectedEventsOfBatch(t *testing.T) {
+	var sizes []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var events []Event
+		json.NewDecoder(r.Body).Decode(&events)
+		sizes = append(sizes, len(events))
+
+		if len(events) > 2 {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		result := types.BatchResult{Rejected: []types.BatchRejection{}}
+		for i, e := range events {
+			if e.ID == "bad" {
+				result.Rejected = append(result.Rejected, types.BatchRejection{Index: i, Status: 400, Error: "invalid event"})
+				continue
+			}
+			result.Accepted++
+		}
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(result)
+	}))
+	defer server.Close()
+
+	var failed []string
+	exporter := NewClient(server.URL).NewExporter(fastRetries(ExporterOptions{
+		BatchSize: 4,
+		OnError:   func(ev Event, _ error) { failed = append(failed, ev.ID) },
+	}))
+
+	for _, id := range []string{"a", "bad", "b", "c"} {
+		exporter.Export(Event{ID: id, Service: "api", Name: "request"})
+	}
+	exporter.Shutdown(context.Background())
+
+	if fmt.Sprint(sizes) != "[4 2 2]" {
+		t.Errorf("Expected a batch too large to be sent in halves, got requests of %v", sizes)
+	}
+	if fmt.Sprint(failed) != "[bad]" {
+		t.Errorf("Expected only the rejected event to fail, got %v", failed)
+	}
+	if stats := exporter.Stats(); stats.Sent != 3 || stats.Failed != 1 || stats.Retried != 0 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestExporterDropsOnOverflow(t *testing.T) {
+	received := make(chan struct{}, 16)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		<-release
+		acceptBatch(w, r)
+	}))
+	defer server.Close()
+
+	exporter := NewClient(server.URL).NewExporter(ExporterOptions{BufferSize: 2, BatchSize: 1})
+
+	/*
+	* the exporter is stuck sending the first event, so the buffer fills
+	**/
+	exporter.Export(Event{Service: "api", Name: "request"})
+	<-received
+
+	accepted := 0
+	for range 10 {
+		if exporter.Export(Event{Service: "api", Name: "request"}) {
+			accepted++
+		}
+	}
+	if stats := exporter.Stats(); accepted != 2 || stats.Dropped != 8 {
+		t.Errorf("Expected 2 events buffered and 8 dropped, got %+v", stats)
+	}
+
+	close(release)
+	exporter.Shutdown(context.Background())
+	if stats := exporter.Stats(); stats.Sent != 3 {
+		t.Errorf("Expected buffered events to be sent on shutdown, got %+v", stats)
+	}
+	if exporter.Export(Event{}) {
+		t.Error("Expected export after shutdown to fail")
+	}
+}
+
+func TestExporterShutdownGivesUp(t *testing.T) {
+	_, client := newIngestServer(t, 1000, http.StatusTooManyRequests)
+	exporter := client.NewExporter(ExporterOptions{InitialBackoff: time.Hour, MaxBackoff: time.Hour})
+
+	exporter.Export(Event{Service: "api", Name: "request"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := exporter.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Expected shutdown to time out, got %v", err)
+	}
+	if stats := exporter.Stats(); stats.Failed != 1 {
+		t.Errorf("Expected the retried event to be given up on, got %+v", stats)
+	}
+}
+
+func TestExporterBackoffStaysBounded(t *testing.T) {
+	e := &Exporter{opts: ExporterOptions{InitialBackoff: 100 * time.Millisecond, MaxBackoff: 30 * time.Second}}
+
+	for _, attempt := range []int{0, 10, 40, 63, 64, 1000} {
+		if wait := e.backoff(attempt, nil); wait <= 0 || wait > e.opts.MaxBackoff {
+			t.Errorf("Expected attempt %d to wait up to %v, got %v", attempt, e.opts.MaxBackoff, wait)
+		}
+	}
+}
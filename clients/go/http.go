@@ -0,0 +1,232 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const TRACEPARENT_HEADER = "traceparent"
+
+type TracingOptions struct {
+	/*
+	* service spans are sent under, required by the server
+	**/
+	Service string
+
+	/*
+	* sends spans in the background when set, which is
+	* recommended, otherwise each span is sent as it ends
+	**/
+	Exporter *Exporter
+
+	/*
+	* names the route of a request, the pattern of the
+	* ServeMux that handled it or the url path by default
+	**/
+	Route func(*http.Request) string
+
+	/*
+	* called when a span couldn't be sent
+	**/
+	OnError func(error)
+}
+
+type tracer struct {
+	client *Client
+	opts   TracingOptions
+}
+
+/*
+* span events are named "METHOD /route" and carry the span ids,
+* http attributes and duration_ms, 5xx responses and failed
+* requests are errors
+**/
+func (t tracer) finish(ctx context.Context, kind string, r *http.Request, span, parent SpanContext, start time.Time, status int, err error) {
+	route := routeOf(r)
+	if t.opts.Route != nil {
+		route = t.opts.Route(r)
+	}
+
+	data := map[string]any{
+		"span.kind":        kind,
+		"span_id":          span.SpanID,
+		"http.method":      r.Method,
+		"http.route":       route,
+		"http.status_code": status,
+		"duration_ms":      float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if parent.SpanID != "" {
+		data["parent_span_id"] = parent.SpanID
+	}
+	if kind == "client" {
+		data["http.host"] = r.URL.Host
+	}
+
+	level := "info"
+	switch {
+	case err != nil:
+		level = "error"
+		data["error"] = err.Error()
+	case status >= 500:
+		level = "error"
+	case status >= 400:
+		level = "warn"
+	}
+
+	ev := Event{
+		ID:        newEventID(),
+		Timestamp: start.UTC(),
+		Level:     level,
+		Service:   t.opts.Service,
+		Name:      r.Method + " " + route,
+		TraceID:   span.TraceID,
+		Data:      data,
+	}
+
+	if err := t.client.emit(context.WithoutCancel(ctx), t.opts.Exporter, ev); err != nil && t.opts.OnError != nil {
+		t.opts.OnError(err)
+	}
+}
+
+/*
+* the route a ServeMux matched without its method and host
+**/
+func routeOf(r *http.Request) string {
+	pattern := r.Pattern
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		pattern = path
+	}
+	if i := strings.IndexByte(pattern, '/'); i > 0 {
+		pattern = pattern[i:]
+	}
+
+	if pattern == "" {
+		return r.URL.Path
+	}
+	return pattern
+}
+
+/*
+* child of the span in ctx, or the root of a new trace
+**/
+func startSpan(parent SpanContext, ok bool) SpanContext {
+	if !ok {
+		return SpanContext{TraceID: newTraceID(), SpanID: newSpanID(), Sampled: true}
+	}
+	return SpanContext{TraceID: parent.TraceID, SpanID: newSpanID(), Sampled: parent.Sampled}
+}
+
+/*
+* Middleware records a server span for every request. a traceparent
+* header continues the caller's trace, and the span is stored in
+* the request context for handlers, loggers and outgoing requests
+**/
+func (c *Client) Middleware(opts TracingOptions) func(http.Handler) http.Handler {
+	t := tracer{client: c, opts: opts}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			parent, ok := ParseTraceparent(r.Header.Get(TRACEPARENT_HEADER))
+			span := startSpan(parent, ok)
+
+			r = r.WithContext(ContextWithSpan(r.Context(), span))
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			start := time.Now()
+
+			defer func() {
+				if p := recover(); p != nil {
+					t.finish(r.Context(), "server", r, span, parent, start, http.StatusInternalServerError, fmt.Errorf("panic: %v", p))
+					panic(p)
+				}
+				t.finish(r.Context(), "server", r, span, parent, start, rec.status, nil)
+			}()
+
+			next.ServeHTTP(rec, r)
+		})
+	}
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+/*
+* keeps streaming handlers working behind the middleware
+**/
+func (w *statusRecorder) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+/*
+* Transport records a client span for every request and sends the
+* trace on in a traceparent header. requests without a span in
+* their context start a new trace. base is http.DefaultTransport
+* when nil
+**/
+func (c *Client) Transport(base http.RoundTripper, opts TracingOptions) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &tracingTransport{base: base, tracer: tracer{client: c, opts: opts}}
+}
+
+type tracingTransport struct {
+	base   http.RoundTripper
+	tracer tracer
+}
+
+/*
+* marks the client's own requests, so a tracing DefaultTransport
+* doesn't trace the events it sends
+**/
+type untracedKey struct{}
+
+func (t *tracingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Context().Value(untracedKey{}) != nil {
+		return t.base.RoundTrip(r)
+	}
+
+	parent, ok := SpanFromContext(r.Context())
+	span := startSpan(parent, ok)
+
+	/*
+	* a RoundTripper must not modify the request it is given
+	**/
+	r = r.Clone(r.Context())
+	if header := span.Traceparent(); header != "" {
+		r.Header.Set(TRACEPARENT_HEADER, header)
+	}
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(r)
+
+	status := 0
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	t.tracer.finish(r.Context(), "client", r, span, parent, start, status, err)
+
+	return resp, err
+}
@@ -0,0 +1,177 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	span, ok := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if !ok || span.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || span.SpanID != "00f067aa0ba902b7" || !span.Sampled {
+		t.Fatalf("Unexpected span %+v", span)
+	}
+	if span.Traceparent() != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Errorf("Expected the header to round trip, got %s", span.Traceparent())
+	}
+
+	for _, header := range []string{
+		"",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	} {
+		if _, ok := ParseTraceparent(header); ok {
+			t.Errorf("Expected %q to be rejected", header)
+		}
+	}
+
+	if _, ok := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"); !ok {
+		t.Error("Expected later versions to be read as version 00")
+	}
+}
+
+func spansByKind(t *testing.T, s *ingestServer) map[string][]Event {
+	t.Helper()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	spans := map[string][]Event{}
+	for _, e := range s.events {
+		kind, _ := e.Data["span.kind"].(string)
+		spans[kind] = append(spans[kind], e)
+	}
+	return spans
+}
+
+func TestTracingAcrossServices(t *testing.T) {
+	collector, client := newIngestServer(t, 0, 0)
+
+	backendMux := http.NewServeMux()
+	backendMux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	backend := httptest.NewServer(client.Middleware(TracingOptions{Service: "users"})(backendMux))
+	defer backend.Close()
+
+	outbound := &http.Client{Transport: client.Transport(nil, TracingOptions{Service: "gateway"})}
+
+	frontendMux := http.NewServeMux()
+	frontendMux.HandleFunc("GET /profile", func(w http.ResponseWriter, r *http.Request) {
+		req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, backend.URL+"/users/42", nil)
+		resp, err := outbound.Do(req)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		resp.Body.Close()
+		w.WriteHeader(http.StatusOK)
+	})
+	frontend := httptest.NewServer(client.Middleware(TracingOptions{Service: "gateway"})(frontendMux))
+	defer frontend.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, frontend.URL+"/profile", nil)
+	req.Header.Set(TRACEPARENT_HEADER, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	spans := spansByKind(t, collector)
+	if len(spans["server"]) != 2 || len(spans["client"]) != 1 {
+		t.Fatalf("Expected two server spans and a client span, got %v", spans)
+	}
+
+	var gateway, users Event
+	for _, e := range spans["server"] {
+		if e.Service == "users" {
+			users = e
+		} else {
+			gateway = e
+		}
+	}
+	call := spans["client"][0]
+
+	for _, e := range []Event{gateway, call, users} {
+		if e.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("Expected every span to continue the incoming trace, got %+v", e)
+		}
+	}
+	if gateway.Name != "GET /profile" || gateway.Data["parent_span_id"] != "00f067aa0ba902b7" {
+		t.Errorf("Unexpected gateway span %+v", gateway)
+	}
+	if call.Data["parent_span_id"] != gateway.Data["span_id"] || users.Data["parent_span_id"] != call.Data["span_id"] {
+		t.Errorf("Expected spans to be chained, got %v -> %v -> %v", gateway.Data, call.Data, users.Data)
+	}
+	if users.Name != "GET /users/{id}" || users.Level != "warn" || users.Data["http.status_code"] != 404.0 {
+		t.Errorf("Unexpected backend span %+v", users)
+	}
+}
+
+func TestIngestTakesTraceFromMiddleware(t *testing.T) {
+	collector, client := newIngestServer(t, 0, 0)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /checkout", func(w http.ResponseWriter, r *http.Request) {
+		if err := client.IngestEvent(r.Context(), "info", "shop", "cart loaded", "", nil); err != nil {
+			t.Error(err)
+		}
+		if err := client.Ingest(r.Context(), Event{Level: "info", Service: "shop", Name: "payment", TraceID: "other"}); err != nil {
+			t.Error(err)
+		}
+	})
+	server := httptest.NewServer(client.Middleware(TracingOptions{Service: "shop"})(mux))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/checkout", nil)
+	req.Header.Set(TRACEPARENT_HEADER, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	events := spansByKind(t, collector)[""]
+	if len(events) != 2 {
+		t.Fatalf("Expected the two events logged by the handler, got %v", events)
+	}
+	for _, e := range events {
+		want := "4bf92f3577b34da6a3ce929d0e0e4736"
+		if e.Name == "payment" {
+			want = "other"
+		}
+		if e.TraceID != want {
+			t.Errorf("Expected %q to have trace %q, got %q", e.Name, want, e.TraceID)
+		}
+	}
+}
+
+func TestTransportStartsTraces(t *testing.T) {
+	collector, client := newIngestServer(t, 0, 0)
+
+	var header string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get(TRACEPARENT_HEADER)
+	}))
+	defer upstream.Close()
+
+	outbound := &http.Client{Transport: client.Transport(nil, TracingOptions{Service: "worker"})}
+	resp, err := outbound.Get(upstream.URL + "/jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	span, ok := ParseTraceparent(header)
+	if !ok {
+		t.Fatalf("Expected a traceparent header, got %q", header)
+	}
+
+	call := spansByKind(t, collector)["client"][0]
+	if call.TraceID != span.TraceID || call.Data["span_id"] != span.SpanID || call.Data["parent_span_id"] != nil {
+		t.Errorf("Expected a root span matching the header, got %+v", call)
+	}
+}
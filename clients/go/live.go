@@ -0,0 +1,401 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrLiveDropped = errors.New("scopion: live stream dropped, the subscriber fell behind")
+
+/*
+* LiveFilter picks the events the server streams, the zero
+* value streams every event of the project
+**/
+type LiveFilter struct {
+	Services []string
+	Levels   []string
+
+	/*
+	* case insensitive substring of the event name
+	**/
+	Name string
+
+	/*
+	* substring of the trace id
+	**/
+	TraceID string
+
+	/*
+	* fraction of traces to receive, a kept trace arrives whole
+	**/
+	SampleRate float64
+}
+
+func (f LiveFilter) query() url.Values {
+	q := url.Values{}
+	if len(f.Services) > 0 {
+		q.Set("service", strings.Join(f.Services, ","))
+	}
+	if len(f.Levels) > 0 {
+		q.Set("level", strings.Join(f.Levels, ","))
+	}
+	if f.Name != "" {
+		q.Set("name", f.Name)
+	}
+	if f.TraceID != "" {
+		q.Set("trace_id", f.TraceID)
+	}
+	if f.SampleRate > 0 {
+		q.Set("sample", strconv.FormatFloat(f.SampleRate, 'f', -1, 64))
+	}
+	return q
+}
+
+type LiveState string
+
+const (
+	LIVE_CONNECTED LiveState = "connected"
+
+	/*
+	* the stream broke, Err says why and RetryIn when
+	* the next attempt is made
+	**/
+	LIVE_DISCONNECTED LiveState = "disconnected"
+
+	/*
+	* events between LastEventID and the resumed stream were
+	* lost, the server no longer had them
+	**/
+	LIVE_GAP LiveState = "gap"
+
+	/*
+	* the subscription ended, Err is set when it gave up
+	* rather than being cancelled
+	**/
+	LIVE_CLOSED LiveState = "closed"
+)
+
+type LiveStatus struct {
+	State       LiveState
+	Err         error
+	RetryIn     time.Duration
+	LastEventID string
+}
+
+type LiveOptions struct {
+	Filter LiveFilter
+
+	/*
+	* resumes after this event, the server replays what
+	* it still has since then
+	**/
+	LastEventID string
+
+	/*
+	* events waiting to be received, the stream stalls beyond this
+	**/
+	Buffer int
+
+	/*
+	* wait before reconnecting, grows with failed attempts. the
+	* server's retry hint replaces MinBackoff when it sends one
+	**/
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	/*
+	* the server sends a heartbeat every 15s, a stream silent
+	* for this long is assumed dead and reconnected
+	**/
+	IdleTimeout time.Duration
+
+	/*
+	* told about connection changes and errors, from the
+	* subscription goroutine so it should return quickly
+	**/
+	OnStatus func(LiveStatus)
+}
+
+func (o *LiveOptions) applyDefaults() {
+	if o.Buffer <= 0 {
+		o.Buffer = 64
+	}
+	if o.MinBackoff <= 0 {
+		o.MinBackoff = time.Second
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = 30 * time.Second
+	}
+	if o.IdleTimeout <= 0 {
+		o.IdleTimeout = 45 * time.Second
+	}
+}
+
+/*
+* SubscribeLive streams live events until ctx is done, reconnecting
+* with backoff and resuming from the last event received. the first
+* connection is made before returning, so a bad filter or key fails
+* right away. the channel is closed when the subscription ends
+**/
+func (c *Client) SubscribeLive(ctx context.Context, opts LiveOptions) (<-chan Event, error) {
+	opts.applyDefaults()
+
+	s := &liveStream{
+		client: c,
+		opts:   opts,
+		events: make(chan Event, opts.Buffer),
+		lastID: opts.LastEventID,
+		retry:  opts.MinBackoff,
+	}
+
+	resp, err := s.connect(ctx)
+	if err != nil && !IsRetryable(err) {
+		return nil, err
+	}
+
+	go s.run(ctx, resp, err)
+
+	return s.events, nil
+}
+
+type liveStream struct {
+	client *Client
+	opts   LiveOptions
+	events chan Event
+
+	lastID string
+
+	/*
+	* base reconnect delay, the server may change it
+	**/
+	retry time.Duration
+}
+
+func (s *liveStream) status(st LiveStatus) {
+	if s.opts.OnStatus != nil {
+		st.LastEventID = s.lastID
+		s.opts.OnStatus(st)
+	}
+}
+
+func (s *liveStream) connect(ctx context.Context) (*http.Response, error) {
+	u := s.client.BaseURL + "/api/live"
+	if q := s.opts.Filter.query(); len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+
+	req, err := s.client.newRequest(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if s.lastID != "" {
+		req.Header.Set("Last-Event-ID", s.lastID)
+	}
+
+	resp, err := s.client.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, newAPIError(resp)
+	}
+	return resp, nil
+}
+
+/*
+* resp and err are the outcome of the first connection attempt
+**/
+func (s *liveStream) run(ctx context.Context, resp *http.Response, err error) {
+	defer close(s.events)
+
+	for attempt := 0; ; attempt++ {
+		if err == nil {
+			s.status(LiveStatus{State: LIVE_CONNECTED})
+			attempt = 0
+			err = s.read(ctx, resp.Body)
+			resp.Body.Close()
+		}
+
+		if ctx.Err() != nil {
+			s.status(LiveStatus{State: LIVE_CLOSED})
+			return
+		}
+		if !IsRetryable(err) {
+			s.status(LiveStatus{State: LIVE_CLOSED, Err: err})
+			return
+		}
+
+		wait := s.backoff(attempt, err)
+		s.status(LiveStatus{State: LIVE_DISCONNECTED, Err: err, RetryIn: wait})
+
+		select {
+		case <-ctx.Done():
+			s.status(LiveStatus{State: LIVE_CLOSED})
+			return
+		case <-time.After(wait):
+		}
+
+		resp, err = s.connect(ctx)
+	}
+}
+
+func (s *liveStream) backoff(attempt int, err error) time.Duration {
+	ceiling := min(s.opts.MaxBackoff, s.retry<<min(attempt, 16))
+	wait := ceiling/2 + rand.N(ceiling/2+1)
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > wait {
+		wait = min(apiErr.RetryAfter, s.opts.MaxBackoff)
+	}
+	return wait
+}
+
+/*
+* reads frames until the stream ends, which is always an error
+* since the server never ends a healthy stream by itself
+**/
+func (s *liveStream) read(ctx context.Context, body io.Reader) error {
+	connCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	idle := time.AfterFunc(s.opts.IdleTimeout, cancel)
+	defer idle.Stop()
+
+	/*
+	* unblocks the read below when the connection is given up on
+	**/
+	go func() {
+		<-connCtx.Done()
+		if closer, ok := body.(io.Closer); ok {
+			closer.Close()
+		}
+	}()
+
+	r := newSSEReader(body)
+	for {
+		frame, err := r.next(func() { idle.Reset(s.opts.IdleTimeout) })
+		if err != nil {
+			if ctx.Err() == nil && connCtx.Err() != nil {
+				return errors.New("scopion: live stream went silent")
+			}
+			if err == io.EOF {
+				return io.ErrUnexpectedEOF
+			}
+			return err
+		}
+
+		if frame.retry > 0 {
+			s.retry = max(frame.retry, s.opts.MinBackoff)
+		}
+		if frame.id != nil {
+			s.lastID = *frame.id
+		}
+
+		switch frame.event {
+		case "", "message":
+			if frame.data == "" {
+				continue
+			}
+			var e Event
+			if err := json.Unmarshal([]byte(frame.data), &e); err != nil {
+				s.status(LiveStatus{State: LIVE_CONNECTED, Err: err})
+				continue
+			}
+			select {
+			case s.events <- e:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+
+		case "gap":
+			s.status(LiveStatus{State: LIVE_GAP})
+
+		case "dropped":
+			return ErrLiveDropped
+		}
+	}
+}
+
+/*
+* one dispatched server sent event. id is nil when the frame
+* didn't set it, as an empty id resets the last event id
+**/
+type sseFrame struct {
+	event string
+	data  string
+	id    *string
+	retry time.Duration
+}
+
+type sseReader struct {
+	r *bufio.Reader
+}
+
+func newSSEReader(r io.Reader) *sseReader {
+	return &sseReader{r: bufio.NewReader(r)}
+}
+
+/*
+* reads lines of any length up to the blank line ending a frame,
+* calling seen for each so idle streams can be told apart from
+* slow ones. retry only frames are returned too
+**/
+func (s *sseReader) next(seen func()) (sseFrame, error) {
+	var frame sseFrame
+	var data strings.Builder
+	hasData := false
+
+	for {
+		line, err := s.r.ReadString('\n')
+		if err != nil {
+			return sseFrame{}, err
+		}
+		seen()
+
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		if line == "" {
+			if !hasData && frame.id == nil && frame.retry == 0 {
+				frame.event = ""
+				continue
+			}
+			frame.data = data.String()
+			return frame, nil
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch field {
+		case "event":
+			frame.event = value
+		case "data":
+			if hasData {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				frame.id = &value
+			}
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil && ms >= 0 {
+				frame.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
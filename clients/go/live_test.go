@@ -0,0 +1,165 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func receive(t *testing.T, ch <-chan Event) Event {
+	t.Helper()
+
+	select {
+	case e, ok := <-ch:
+		if !ok {
+			t.Fatal("Expected an event, the channel was closed")
+		}
+		return e
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected to receive an event within timeout")
+	}
+	return Event{}
+}
+
+func TestSubscribeLiveReadsFrames(t *testing.T) {
+	big := strings.Repeat("x", 200*1024)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("service"); got != "api,worker" {
+			t.Errorf("Expected the filter to be sent, got service=%q", got)
+		}
+		if got := r.URL.Query().Get("level"); got != "error" {
+			t.Errorf("Expected the filter to be sent, got level=%q", got)
+		}
+
+		payload, _ := json.Marshal(Event{ID: "large", Data: map[string]any{"blob": big}})
+		fmt.Fprint(w, "retry: 10\n\n: heartbeat\n\n")
+		fmt.Fprint(w, "id: 1-1\ndata: {\"id\":\ndata: \"split\"}\n\n")
+		fmt.Fprintf(w, "id: 1-2\r\ndata: %s\r\n\r\n", payload)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch, err := NewClient(server.URL).SubscribeLive(ctx, LiveOptions{
+		Filter: LiveFilter{Services: []string{"api", "worker"}, Levels: []string{"error"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e := receive(t, ch); e.ID != "split" {
+		t.Errorf("Expected data lines to be joined, got %+v", e)
+	}
+	if e := receive(t, ch); e.ID != "large" || e.Data["blob"] != big {
+		t.Errorf("Expected the large event intact, got id %q", e.ID)
+	}
+
+	cancel()
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Error("Expected no more events")
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("Expected the channel to close once cancelled")
+	}
+}
+
+func TestSubscribeLiveReconnects(t *testing.T) {
+	var mu sync.Mutex
+	var lastIDs []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		lastIDs = append(lastIDs, r.Header.Get("Last-Event-ID"))
+		n := len(lastIDs)
+		mu.Unlock()
+
+		fmt.Fprint(w, "retry: 1\n\n")
+		switch n {
+		case 1:
+			fmt.Fprint(w, "id: 1-7\ndata: {\"id\":\"first\"}\n\n")
+		case 2:
+			fmt.Fprint(w, "event: dropped\ndata: {\"reason\":\"client too slow\"}\n\n")
+		default:
+			fmt.Fprint(w, "event: gap\ndata: {}\n\nid: 1-9\ndata: {\"id\":\"resumed\"}\n\n")
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}
+	}))
+	defer server.Close()
+
+	var statuses []LiveStatus
+	var statusMu sync.Mutex
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch, err := NewClient(server.URL).SubscribeLive(ctx, LiveOptions{
+		MinBackoff: time.Millisecond,
+		MaxBackoff: 5 * time.Millisecond,
+		OnStatus: func(s LiveStatus) {
+			statusMu.Lock()
+			statuses = append(statuses, s)
+			statusMu.Unlock()
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e := receive(t, ch); e.ID != "first" {
+		t.Fatalf("Unexpected event %+v", e)
+	}
+	if e := receive(t, ch); e.ID != "resumed" {
+		t.Fatalf("Unexpected event %+v", e)
+	}
+
+	mu.Lock()
+	if len(lastIDs) != 3 || lastIDs[0] != "" || lastIDs[1] != "1-7" || lastIDs[2] != "1-7" {
+		t.Errorf("Expected reconnects to resume from the last event, got %q", lastIDs)
+	}
+	mu.Unlock()
+
+	statusMu.Lock()
+	defer statusMu.Unlock()
+
+	var states []string
+	dropped := false
+	for _, s := range statuses {
+		states = append(states, string(s.State))
+		dropped = dropped || errors.Is(s.Err, ErrLiveDropped)
+	}
+	want := "connected disconnected connected disconnected connected gap"
+	if strings.Join(states, " ") != want {
+		t.Errorf("Expected states %q, got %q", want, strings.Join(states, " "))
+	}
+	if !dropped {
+		t.Error("Expected the dropped notice to be reported")
+	}
+}
+
+func TestSubscribeLiveFailsFast(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "sample must be a number in (0, 1]", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	_, err := NewClient(server.URL).SubscribeLive(context.Background(), LiveOptions{Filter: LiveFilter{SampleRate: 2}})
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 400 {
+		t.Errorf("Expected the rejected filter to fail the call, got %v", err)
+	}
+}
@@ -1,98 +1,405 @@
 package client
 
 import (
-	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/xonoxc/scopion/clients/go/types"
+)
+
+/*
+* the types of the http api, shared with the server
+**/
+type (
+	Event          = types.Event
+	Stats          = types.Stats
+	ServiceInfo    = types.ServiceInfo
+	TraceInfo      = types.TraceInfo
+	ThroughputData = types.ThroughputData
+	ErrorByService = types.ErrorByService
+	Violation      = types.Violation
 )
 
 type Client struct {
 	BaseURL string
+
+	/*
+	* sent as a bearer token when set, servers running
+	* with auth need an ingest key to ingest and a read key to query
+	**/
+	APIKey string
+
+	/*
+	* sent as X-Scopion-Project when set, ignored by the
+	* server when the api key already belongs to a project
+	**/
+	Project string
+
+	/*
+	* used for every request, http.DefaultClient when nil
+	**/
+	HTTPClient *http.Client
 }
 
 func NewClient(baseURL string) *Client {
 	return &Client{BaseURL: baseURL}
 }
 
-type Event struct {
-	ID        string         `json:"id"`
-	Timestamp string         `json:"timestamp"`
-	Level     string         `json:"level"`
-	Service   string         `json:"service"`
-	Name      string         `json:"name"`
-	TraceID   *string        `json:"trace_id,omitempty"`
-	Data      map[string]any `json:"data,omitempty"`
+func NewClientWithKey(baseURL, apiKey string) *Client {
+	return &Client{BaseURL: baseURL, APIKey: apiKey}
+}
+
+func (c *Client) newRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+	if c.Project != "" {
+		req.Header.Set("X-Scopion-Project", c.Project)
+	}
+	return req, nil
+}
+
+func (c *Client) do(ctx context.Context, method, url string, body io.Reader) (*http.Response, error) {
+	req, err := c.newRequest(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	return c.httpClient().Do(req)
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
 }
 
-func (c *Client) IngestEvent(level, service, name string, traceID *string, customData map[string]any) error {
-	data := map[string]any{
-		"level":   level,
-		"service": service,
-		"name":    name,
+/*
+* decodes the json answer to a GET of path into dst
+**/
+func (c *Client) getJSON(ctx context.Context, path string, query url.Values, dst any) error {
+	u := c.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
 	}
-	if traceID != nil {
-		data["trace_id"] = *traceID
+
+	resp, err := c.do(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError(resp)
 	}
-	if customData != nil {
-		data["data"] = customData
+	return json.NewDecoder(resp.Body).Decode(dst)
+}
+
+/*
+* APIError is a response the server didn't accept
+**/
+type APIError struct {
+	StatusCode int
+	Message    string
+
+	/*
+	* from the Retry-After header of 429 and 503 responses
+	**/
+	RetryAfter time.Duration
+
+	/*
+	* every rule an event broke, when the server rejected it as invalid
+	**/
+	Violations []Violation
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("unexpected status: %d", e.StatusCode)
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if len(e.Violations) > 0 {
+		rules := make([]string, len(e.Violations))
+		for i, v := range e.Violations {
+			rules[i] = v.Field + " " + v.Message
+		}
+		msg += ": " + strings.Join(rules, ", ")
+	}
+	return msg
+}
+
+func newAPIError(resp *http.Response) *APIError {
+	err := &APIError{StatusCode: resp.StatusCode}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	var parsed struct {
+		Error      string      `json:"error"`
+		Violations []Violation `json:"violations"`
+	}
+	if json.Unmarshal(body, &parsed) == nil && parsed.Error != "" {
+		err.Message = parsed.Error
+		err.Violations = parsed.Violations
+	} else {
+		err.Message = strings.TrimSpace(string(body))
+	}
+
+	if secs, perr := strconv.Atoi(resp.Header.Get("Retry-After")); perr == nil && secs > 0 {
+		err.RetryAfter = time.Duration(secs) * time.Second
+	}
+
+	return err
+}
+
+/*
+* IsRetryable reports whether err is worth another try: rate
+* limited and server side failures are, as is anything that
+* never got a response
+**/
+func IsRetryable(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return true
+	}
+	return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500
+}
+
+/*
+* IngestEvent sends an event and waits for the server to accept it,
+* an empty traceID uses the trace in ctx, if there is one
+**/
+func (c *Client) IngestEvent(ctx context.Context, level, service, name, traceID string, customData map[string]any) error {
+	return c.Ingest(ctx, Event{
+		Level:   level,
+		Service: service,
+		Name:    name,
+		TraceID: traceID,
+		Data:    customData,
+	})
+}
+
+/*
+* Ingest sends e and waits for the server to accept it. an id is
+* filled in when empty, so retrying a failed call stores e once,
+* and an empty trace id is taken from ctx
+**/
+func (c *Client) Ingest(ctx context.Context, e Event) error {
+	if e.ID == "" {
+		e.ID = newEventID()
+	}
+	if e.TraceID == "" {
+		e.TraceID, _ = TraceIDFromContext(ctx)
+	}
+	return c.send(ctx, e)
+}
+
+/*
+* sends a single event, keyed by its id so a retry is stored once
+**/
+func (c *Client) send(ctx context.Context, e Event) error {
+	jsonData, err := json.Marshal(e)
+	if err != nil {
+		return err
 	}
-	jsonData, err := json.Marshal(data)
+
+	ctx = context.WithValue(ctx, untracedKey{}, true)
+	req, err := c.newRequest(ctx, http.MethodPost, c.BaseURL+"/ingest", bytes.NewReader(jsonData))
 	if err != nil {
 		return err
 	}
-	resp, err := http.Post(c.BaseURL+"/ingest", "application/json", bytes.NewBuffer(jsonData))
+	req.Header.Set("Idempotency-Key", e.ID)
+
+	resp, err := c.httpClient().Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
-	if resp.StatusCode != 202 {
-		return fmt.Errorf("unexpected status: %d", resp.StatusCode)
+
+	if resp.StatusCode != http.StatusAccepted {
+		return newAPIError(resp)
 	}
+	io.Copy(io.Discard, resp.Body)
 	return nil
 }
 
-func (c *Client) GetEvents(limit int) ([]Event, error) {
-	url := fmt.Sprintf("%s/api/events?limit=%d", c.BaseURL, limit)
-	resp, err := http.Get(url)
+/*
+* IngestBatch sends events in a single request. events without an id
+* get one in place, so sending the same slice again stores each once,
+* and empty trace ids are taken from ctx. errs holds an *APIError at
+* the index of every event the server didn't take and is nil when it
+* took them all, err is set when the request as a whole failed.
+* the server caps how many events a batch may hold, 1000 by default,
+* and one it finds too large is sent again in halves
+**/
+func (c *Client) IngestBatch(ctx context.Context, events []Event) (errs []error, err error) {
+	traceID, _ := TraceIDFromContext(ctx)
+	for i := range events {
+		if events[i].ID == "" {
+			events[i].ID = newEventID()
+		}
+		if events[i].TraceID == "" {
+			events[i].TraceID = traceID
+		}
+	}
+	return c.sendBatch(ctx, events)
+}
+
+/*
+* sends events as they are, each keyed by its id. a batch
+* the server finds too large is sent again in halves
+**/
+func (c *Client) sendBatch(ctx context.Context, events []Event) ([]error, error) {
+	errs, err := c.postBatch(ctx, events)
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusRequestEntityTooLarge || len(events) < 2 {
+		return errs, err
+	}
+
+	half := len(events) / 2
+	errs = make([]error, len(events))
+	failed := false
+	for _, part := range [][2]int{{0, half}, {half, len(events)}} {
+		partErrs, err := c.sendBatch(ctx, events[part[0]:part[1]])
+		for i := part[0]; i < part[1]; i++ {
+			switch {
+			case err != nil:
+				errs[i] = err
+			case partErrs != nil:
+				errs[i] = partErrs[i-part[0]]
+			}
+			failed = failed || errs[i] != nil
+		}
+	}
+	if !failed {
+		return nil, nil
+	}
+	return errs, nil
+}
+
+func (c *Client) postBatch(ctx context.Context, events []Event) ([]error, error) {
+	if len(events) == 0 {
+		return nil, nil
+	}
+
+	jsonData, err := json.Marshal(events)
 	if err != nil {
 		return nil, err
 	}
 
+	ctx = context.WithValue(ctx, untracedKey{}, true)
+	resp, err := c.do(ctx, http.MethodPost, c.BaseURL+"/ingest/batch", bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, err
+	}
 	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("unexpected status: %d", resp.StatusCode)
+
+	if resp.StatusCode != http.StatusAccepted {
+		return nil, newAPIError(resp)
 	}
 
+	var result types.BatchResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode batch result: %w", err)
+	}
+	if len(result.Rejected) == 0 {
+		return nil, nil
+	}
+
+	errs := make([]error, len(events))
+	for _, r := range result.Rejected {
+		if r.Index < 0 || r.Index >= len(events) {
+			continue
+		}
+		errs[r.Index] = &APIError{
+			StatusCode: r.Status,
+			Message:    r.Error,
+			RetryAfter: time.Duration(r.RetryAfter) * time.Second,
+			Violations: r.Violations,
+		}
+	}
+	return errs, nil
+}
+
+/*
+* limits of 0 leave the default of the server
+**/
+func limitQuery(key string, n int) url.Values {
+	if n <= 0 {
+		return nil
+	}
+	return url.Values{key: {strconv.Itoa(n)}}
+}
+
+/*
+* GetEvents returns the most recent events, oldest first
+**/
+func (c *Client) GetEvents(ctx context.Context, limit int) ([]Event, error) {
 	var events []Event
-	err = json.NewDecoder(resp.Body).Decode(&events)
+	err := c.getJSON(ctx, "/api/events", limitQuery("limit", limit), &events)
 	return events, err
 }
 
-func (c *Client) SubscribeLive() (<-chan Event, error) {
-	ch := make(chan Event)
-	go func() {
-		defer close(ch)
-		resp, err := http.Get(c.BaseURL + "/api/live")
-		if err != nil {
-			return
-		}
+func (c *Client) GetStats(ctx context.Context) (Stats, error) {
+	var stats Stats
+	err := c.getJSON(ctx, "/api/stats", nil, &stats)
+	return stats, err
+}
 
-		defer resp.Body.Close()
-		scanner := bufio.NewScanner(resp.Body)
-
-		for scanner.Scan() {
-			line := scanner.Text()
-			if after, ok := strings.CutPrefix(line, "data: "); ok {
-				data := after
-				var event Event
-				if err := json.Unmarshal([]byte(data), &event); err == nil {
-					ch <- event
-				}
-			}
-		}
-	}()
-	return ch, nil
+func (c *Client) GetServices(ctx context.Context) ([]ServiceInfo, error) {
+	var services []ServiceInfo
+	err := c.getJSON(ctx, "/api/services", nil, &services)
+	return services, err
+}
+
+func (c *Client) GetTraces(ctx context.Context, limit int) ([]TraceInfo, error) {
+	var traces []TraceInfo
+	err := c.getJSON(ctx, "/api/traces", limitQuery("limit", limit), &traces)
+	return traces, err
+}
+
+func (c *Client) GetTraceEvents(ctx context.Context, traceID string) ([]Event, error) {
+	var events []Event
+	err := c.getJSON(ctx, "/api/trace-events", url.Values{"trace_id": {traceID}}, &events)
+	return events, err
+}
+
+/*
+* SearchEvents matches query against event names, services and trace ids
+**/
+func (c *Client) SearchEvents(ctx context.Context, query string) ([]Event, error) {
+	var events []Event
+	err := c.getJSON(ctx, "/api/search", url.Values{"q": {query}}, &events)
+	return events, err
+}
+
+/*
+* GetThroughput returns event counts over the last hours, 0 for
+* the default of the server
+**/
+func (c *Client) GetThroughput(ctx context.Context, hours int) ([]ThroughputData, error) {
+	var throughput []ThroughputData
+	err := c.getJSON(ctx, "/api/throughput", limitQuery("hours", hours), &throughput)
+	return throughput, err
+}
+
+func (c *Client) GetErrorsByService(ctx context.Context, hours int) ([]ErrorByService, error) {
+	var errs []ErrorByService
+	err := c.getJSON(ctx, "/api/errors-by-service", limitQuery("hours", hours), &errs)
+	return errs, err
 }
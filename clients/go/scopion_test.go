@@ -1,7 +1,9 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -19,7 +21,7 @@ func TestIngestEvent(t *testing.T) {
 	defer server.Close()
 
 	client := NewClient(server.URL)
-	err := client.IngestEvent("info", "test", "event", nil, nil)
+	err := client.IngestEvent(context.Background(), "info", "test", "event", "", nil)
 	if err != nil {
 		t.Errorf("Expected no error, got %v", err)
 	}
@@ -37,7 +39,7 @@ func TestIngestEventWithTrace(t *testing.T) {
 
 	client := NewClient(server.URL)
 	traceID := "trace123"
-	err := client.IngestEvent("error", "api", "timeout", &traceID, nil)
+	err := client.IngestEvent(context.Background(), "error", "api", "timeout", traceID, nil)
 	if err != nil {
 		t.Errorf("Expected no error, got %v", err)
 	}
@@ -59,15 +61,44 @@ func TestIngestEventWithCustomData(t *testing.T) {
 		"action":     "login",
 		"ip_address": "192.168.1.1",
 	}
-	err := client.IngestEvent("info", "auth", "user_login", nil, customData)
+	err := client.IngestEvent(context.Background(), "info", "auth", "user_login", "", customData)
 	if err != nil {
 		t.Errorf("Expected no error, got %v", err)
 	}
 }
 
+func TestIngestBatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ingest/batch" {
+			w.WriteHeader(404)
+			return
+		}
+		w.WriteHeader(202)
+		w.Write([]byte(`{"accepted":1,"rejected":[{"index":1,"status":429,"error":"quota exceeded","retry_after":30}]}`))
+	}))
+	defer server.Close()
+
+	events := []Event{{Service: "api", Name: "one"}, {Service: "api", Name: "two"}}
+	errs, err := NewClient(server.URL).IngestBatch(context.Background(), events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if events[0].ID == "" || events[1].ID == "" {
+		t.Error("Expected ids to be filled in on the events")
+	}
+
+	var apiErr *APIError
+	if len(errs) != 2 || errs[0] != nil || !errors.As(errs[1], &apiErr) {
+		t.Fatalf("Expected an error for the second event only, got %v", errs)
+	}
+	if apiErr.StatusCode != 429 || apiErr.RetryAfter != 30*time.Second || !IsRetryable(apiErr) {
+		t.Errorf("Unexpected error %+v", apiErr)
+	}
+}
+
 func TestGetEvents(t *testing.T) {
 	events := []Event{
-		{ID: "1", Timestamp: "2023-01-01", Level: "info", Service: "test", Name: "event"},
+		{ID: "1", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), Level: "info", Service: "test", Name: "event"},
 	}
 	jsonData, _ := json.Marshal(events)
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -82,7 +113,7 @@ func TestGetEvents(t *testing.T) {
 	defer server.Close()
 
 	client := NewClient(server.URL)
-	result, err := client.GetEvents(10)
+	result, err := client.GetEvents(context.Background(), 10)
 	if err != nil {
 		t.Errorf("Expected no error, got %v", err)
 	}
@@ -91,32 +122,134 @@ func TestGetEvents(t *testing.T) {
 	}
 }
 
-func TestSubscribeLive(t *testing.T) {
+func TestClientSendsAPIKey(t *testing.T) {
+	var got string
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == "/api/live" && r.Method == "GET" {
-			w.Header().Set("Content-Type", "text/event-stream")
-			w.WriteHeader(200)
-			w.Write([]byte("data: {\"id\": \"1\"}\n\n"))
-			// Close after sending
-			w.(http.Flusher).Flush()
-		} else {
-			w.WriteHeader(404)
-		}
+		got = r.Header.Get("Authorization")
+		w.WriteHeader(202)
 	}))
 	defer server.Close()
 
+	client := NewClientWithKey(server.URL, "sk_test")
+	if err := client.IngestEvent(context.Background(), "info", "test", "event", "", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got != "Bearer sk_test" {
+		t.Errorf("Expected bearer api key, got %q", got)
+	}
+}
+
+func TestReadAPI(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	mux := http.NewServeMux()
+	respond := func(path string, want map[string]string, v any) {
+		mux.HandleFunc("GET "+path, func(w http.ResponseWriter, r *http.Request) {
+			for key, value := range want {
+				if got := r.URL.Query().Get(key); got != value {
+					t.Errorf("%s: expected %s=%s, got %q", path, key, value, got)
+				}
+			}
+			json.NewEncoder(w).Encode(v)
+		})
+	}
+	respond("/api/stats", nil, Stats{TotalEvents: 10, StoredEvents: 5})
+	respond("/api/services", nil, []ServiceInfo{{Name: "api", LastActivity: ts}})
+	respond("/api/traces", map[string]string{"limit": "5"}, []TraceInfo{{ID: "t1", Timestamp: ts}})
+	respond("/api/trace-events", map[string]string{"trace_id": "t1"}, []Event{{ID: "e1", TraceID: "t1", Timestamp: ts}})
+	respond("/api/search", map[string]string{"q": "checkout failed"}, []Event{{ID: "e2"}})
+	respond("/api/throughput", map[string]string{"hours": "6"}, []ThroughputData{{Time: "12:00", Events: 3}})
+	respond("/api/errors-by-service", map[string]string{"hours": ""}, []ErrorByService{{Service: "api", Count: 2}})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ctx := context.Background()
 	client := NewClient(server.URL)
-	ch, err := client.SubscribeLive()
-	if err != nil {
-		t.Errorf("Expected no error, got %v", err)
+
+	stats, err := client.GetStats(ctx)
+	if err != nil || stats.TotalEvents != 10 || stats.StoredEvents != 5 {
+		t.Errorf("Unexpected stats %+v, %v", stats, err)
+	}
+	services, err := client.GetServices(ctx)
+	if err != nil || len(services) != 1 || !services[0].LastActivity.Equal(ts) {
+		t.Errorf("Unexpected services %+v, %v", services, err)
+	}
+	traces, err := client.GetTraces(ctx, 5)
+	if err != nil || len(traces) != 1 || traces[0].ID != "t1" {
+		t.Errorf("Unexpected traces %+v, %v", traces, err)
 	}
+	events, err := client.GetTraceEvents(ctx, "t1")
+	if err != nil || len(events) != 1 || !events[0].Timestamp.Equal(ts) {
+		t.Errorf("Unexpected trace events %+v, %v", events, err)
+	}
+	found, err := client.SearchEvents(ctx, "checkout failed")
+	if err != nil || len(found) != 1 || found[0].ID != "e2" {
+		t.Errorf("Unexpected search results %+v, %v", found, err)
+	}
+	throughput, err := client.GetThroughput(ctx, 6)
+	if err != nil || len(throughput) != 1 || throughput[0].Events != 3 {
+		t.Errorf("Unexpected throughput %+v, %v", throughput, err)
+	}
+	errs, err := client.GetErrorsByService(ctx, 0)
+	if err != nil || len(errs) != 1 || errs[0].Count != 2 {
+		t.Errorf("Unexpected errors by service %+v, %v", errs, err)
+	}
+}
 
-	select {
-	case event := <-ch:
-		if event.ID != "1" {
-			t.Errorf("Expected event ID 1, got %s", event.ID)
-		}
-	case <-time.After(100 * time.Millisecond):
-		t.Error("Expected to receive an event within timeout")
+type countingTransport struct {
+	requests int
+}
+
+func (c *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	c.requests++
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestAPIErrorsAndHTTPClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "trace_id parameter is required", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	transport := &countingTransport{}
+	client := NewClient(server.URL)
+	client.HTTPClient = &http.Client{Transport: transport}
+
+	_, err := client.GetTraceEvents(context.Background(), "")
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 400 || apiErr.Message != "trace_id parameter is required" {
+		t.Errorf("Expected a structured api error, got %v", err)
+	}
+	if transport.requests != 1 {
+		t.Errorf("Expected the custom http client to be used, got %d requests", transport.requests)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.GetStats(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancelled context to stop the call, got %v", err)
+	}
+}
+
+func TestAPIErrorKeepsViolations(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid event","violations":[{"field":"name","message":"is required"},{"field":"level","message":"\"loud\" is not a level"}]}`))
+	}))
+	defer server.Close()
+
+	err := NewClient(server.URL).Ingest(context.Background(), Event{Service: "api", Level: "loud"})
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected an api error, got %v", err)
+	}
+	if len(apiErr.Violations) != 2 || apiErr.Violations[0].Field != "name" || apiErr.Message != "invalid event" {
+		t.Errorf("Expected the violations to be decoded, got %+v", apiErr)
+	}
+	if want := `unexpected status: 400: invalid event: name is required, level "loud" is not a level`; err.Error() != want {
+		t.Errorf("Expected %q, got %q", want, err.Error())
 	}
 }
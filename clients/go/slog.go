@@ -0,0 +1,250 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"time"
+)
+
+type HandlerOptions struct {
+	/*
+	* service every record is logged under, required by the server
+	**/
+	Service string
+
+	/*
+	* records below this are skipped, info when nil
+	**/
+	Level slog.Leveler
+
+	/*
+	* adds the file and line of the log call as data.source
+	**/
+	AddSource bool
+
+	/*
+	* sends records in the background when set, otherwise
+	* each record is sent before the log call returns
+	**/
+	Exporter *Exporter
+
+	/*
+	* picks the trace id of a record from the context of
+	* the log call, TraceIDFromContext when nil
+	**/
+	TraceID func(context.Context) (string, bool)
+}
+
+/*
+* Handler is a slog.Handler that sends records as events. the
+* message becomes the event name, attributes end up in Data with
+* groups flattened into dotted keys
+**/
+type Handler struct {
+	client *Client
+	opts   HandlerOptions
+
+	/*
+	* attributes added through WithAttrs, already flattened
+	**/
+	attrs  map[string]any
+	prefix string
+}
+
+func NewHandler(c *Client, opts HandlerOptions) *Handler {
+	if opts.Level == nil {
+		opts.Level = slog.LevelInfo
+	}
+	if opts.TraceID == nil {
+		opts.TraceID = TraceIDFromContext
+	}
+
+	return &Handler{client: c, opts: opts}
+}
+
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.opts.Level.Level()
+}
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	ts := r.Time
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+
+	data := make(map[string]any, len(h.attrs)+r.NumAttrs())
+	maps.Copy(data, h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		flatten(data, h.prefix, a)
+		return true
+	})
+	if h.opts.AddSource && r.PC != 0 {
+		if src := r.Source(); src != nil {
+			data["source"] = fmt.Sprintf("%s:%d", src.File, src.Line)
+		}
+	}
+
+	ev := Event{
+		ID:        newEventID(),
+		Timestamp: ts.UTC(),
+		Level:     SlogLevel(r.Level),
+		Service:   h.opts.Service,
+		Name:      r.Message,
+	}
+	if traceID, ok := h.opts.TraceID(ctx); ok {
+		ev.TraceID = traceID
+	}
+	if len(data) > 0 {
+		ev.Data = data
+	}
+
+	return h.client.emit(ctx, h.opts.Exporter, ev)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+
+	h2 := *h
+	h2.attrs = maps.Clone(h.attrs)
+	if h2.attrs == nil {
+		h2.attrs = map[string]any{}
+	}
+	for _, a := range attrs {
+		flatten(h2.attrs, h.prefix, a)
+	}
+	return &h2
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	h2 := *h
+	h2.prefix = h.prefix + name + "."
+	return &h2
+}
+
+/*
+* SlogLevel maps a slog level onto the nearest event level,
+* levels beyond error are fatal
+**/
+func SlogLevel(level slog.Level) string {
+	switch {
+	case level < slog.LevelDebug:
+		return "trace"
+	case level < slog.LevelInfo:
+		return "debug"
+	case level < slog.LevelWarn:
+		return "info"
+	case level < slog.LevelError:
+		return "warn"
+	case level < slog.LevelError+4:
+		return "error"
+	default:
+		return "fatal"
+	}
+}
+
+func flatten(data map[string]any, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		group := prefix
+		if a.Key != "" {
+			group += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			flatten(data, group, ga)
+		}
+		return
+	}
+
+	data[prefix+a.Key] = attrValue(a.Value)
+}
+
+/*
+* values the server can store as json
+**/
+func attrValue(v slog.Value) any {
+	switch v.Kind() {
+	case slog.KindString:
+		return v.String()
+	case slog.KindInt64:
+		return v.Int64()
+	case slog.KindUint64:
+		return v.Uint64()
+	case slog.KindFloat64:
+		return v.Float64()
+	case slog.KindBool:
+		return v.Bool()
+	case slog.KindDuration:
+		return v.Duration().String()
+	case slog.KindTime:
+		return v.Time().UTC().Format(time.RFC3339Nano)
+	}
+
+	switch x := v.Any().(type) {
+	case error:
+		return x.Error()
+	case fmt.Stringer:
+		return x.String()
+	default:
+		return x
+	}
+}
+
+/*
+* Tee sends every record to each of handlers, so events can go to
+* scopion while logs keep going wherever they went before
+**/
+func Tee(handlers ...slog.Handler) slog.Handler {
+	return teeHandler(handlers)
+}
+
+type teeHandler []slog.Handler
+
+func (t teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range t {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t teeHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range t {
+		if h.Enabled(ctx, r.Level) {
+			if err := h.Handle(ctx, r.Clone()); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (t teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make(teeHandler, len(t))
+	for i, h := range t {
+		out[i] = h.WithAttrs(attrs)
+	}
+	return out
+}
+
+func (t teeHandler) WithGroup(name string) slog.Handler {
+	out := make(teeHandler, len(t))
+	for i, h := range t {
+		out[i] = h.WithGroup(name)
+	}
+	return out
+}
@@ -0,0 +1,99 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func onlyEvent(t *testing.T, s *ingestServer) Event {
+	t.Helper()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.events) != 1 {
+		t.Fatalf("Expected one event, got %d", len(s.events))
+	}
+	for _, e := range s.events {
+		return e
+	}
+	return Event{}
+}
+
+func TestHandlerSendsRecords(t *testing.T) {
+	server, client := newIngestServer(t, 0, 0)
+	logger := slog.New(NewHandler(client, HandlerOptions{Service: "checkout"}))
+
+	ctx := ContextWithTraceID(context.Background(), "trace-1")
+	logger.With("user", "ada").WithGroup("http").WarnContext(ctx, "slow request",
+		"status", 200,
+		slog.Group("db", "table", "orders", "rows", 3),
+		"err", errors.New("timeout"),
+	)
+
+	e := onlyEvent(t, server)
+	if e.Level != "warn" || e.Service != "checkout" || e.Name != "slow request" {
+		t.Errorf("Unexpected event %+v", e)
+	}
+	if e.TraceID != "trace-1" {
+		t.Errorf("Expected trace id from the context, got %v", e.TraceID)
+	}
+
+	want := map[string]any{
+		"user":          "ada",
+		"http.status":   200.0,
+		"http.db.table": "orders",
+		"http.db.rows":  3.0,
+		"http.err":      "timeout",
+	}
+	for key, value := range want {
+		if e.Data[key] != value {
+			t.Errorf("Expected %s=%v, got %v", key, value, e.Data[key])
+		}
+	}
+}
+
+func TestHandlerLevels(t *testing.T) {
+	_, client := newIngestServer(t, 0, 0)
+	h := NewHandler(client, HandlerOptions{Service: "api", Level: slog.LevelWarn})
+
+	if h.Enabled(context.Background(), slog.LevelInfo) || !h.Enabled(context.Background(), slog.LevelError) {
+		t.Error("Expected records below the handler level to be skipped")
+	}
+
+	levels := map[slog.Level]string{
+		slog.LevelDebug - 4: "trace",
+		slog.LevelDebug:     "debug",
+		slog.LevelInfo:      "info",
+		slog.LevelWarn + 1:  "warn",
+		slog.LevelError:     "error",
+		slog.LevelError + 4: "fatal",
+	}
+	for level, want := range levels {
+		if got := SlogLevel(level); got != want {
+			t.Errorf("Expected %v to map to %s, got %s", level, want, got)
+		}
+	}
+}
+
+func TestTee(t *testing.T) {
+	server, client := newIngestServer(t, 0, 0)
+
+	var buf bytes.Buffer
+	logger := slog.New(Tee(
+		slog.NewTextHandler(&buf, nil),
+		NewHandler(client, HandlerOptions{Service: "api"}),
+	))
+	logger.Info("started", "port", 8080)
+
+	if !strings.Contains(buf.String(), "msg=started port=8080") {
+		t.Errorf("Expected the record to still be logged as text, got %q", buf.String())
+	}
+	if e := onlyEvent(t, server); e.Name != "started" || e.Data["port"] != 8080.0 {
+		t.Errorf("Unexpected event %+v", e)
+	}
+}
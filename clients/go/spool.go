@@ -0,0 +1,446 @@
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const spoolExt = ".spool"
+
+var ErrSpoolClosed = errors.New("scopion: spool is closed")
+
+/*
+* SpoolOptions controls the on-disk spool,
+* zero values fall back to defaults
+**/
+type SpoolOptions struct {
+	/*
+	* directory holding the spool segments, required.
+	* one process at a time may use it
+	**/
+	Dir string
+
+	/*
+	* size a segment grows to before a new one is started
+	**/
+	SegmentBytes int64
+
+	/*
+	* size of the whole spool, beyond this the oldest
+	* segments are deleted and their events counted as dropped
+	**/
+	MaxBytes int64
+
+	/*
+	* fsync every append, survives power loss and
+	* not only process crashes at the cost of throughput
+	**/
+	Sync bool
+}
+
+func (o *SpoolOptions) applyDefaults() {
+	if o.SegmentBytes <= 0 {
+		o.SegmentBytes = 1 << 20
+	}
+	if o.MaxBytes <= 0 {
+		o.MaxBytes = 64 << 20
+	}
+	o.MaxBytes = max(o.MaxBytes, o.SegmentBytes)
+}
+
+type SpoolStats struct {
+	Events   int
+	Bytes    int64
+	Segments int
+
+	/*
+	* age of the oldest event waiting, 0 when empty
+	**/
+	OldestAge time.Duration
+
+	/*
+	* events deleted to stay under MaxBytes
+	**/
+	Dropped uint64
+}
+
+type spoolSegment struct {
+	id     uint64
+	path   string
+	bytes  int64
+	events int
+	first  time.Time
+
+	/*
+	* set while the segment is appended to, only the last one is
+	**/
+	file *os.File
+
+	/*
+	* events of the head segment, loaded when it is read,
+	* and how many of them were delivered
+	**/
+	loaded []Event
+	acked  int
+}
+
+/*
+* Spool keeps events on disk until they can be delivered. events are
+* appended to segment files of json lines and read back in the order
+* they were written, across restarts. a segment is deleted once every
+* event in it is acknowledged, events read again after a crash are
+* deduplicated by the server through their ids
+**/
+type Spool struct {
+	opts SpoolOptions
+	now  func() time.Time
+
+	mu       sync.Mutex
+	segments []*spoolSegment
+	nextID   uint64
+	dropped  uint64
+	closed   bool
+
+	/*
+	* the head segment events returned by Next came from
+	**/
+	reading *spoolSegment
+}
+
+/*
+* OpenSpool opens the spool in opts.Dir, picking up
+* whatever a previous run left there
+**/
+func OpenSpool(opts SpoolOptions) (*Spool, error) {
+	if opts.Dir == "" {
+		return nil, errors.New("scopion: spool directory is required")
+	}
+	opts.applyDefaults()
+
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create spool directory: %w", err)
+	}
+
+	s := &Spool{opts: opts, now: time.Now}
+
+	paths, err := filepath.Glob(filepath.Join(opts.Dir, "*"+spoolExt))
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(paths)
+
+	for _, path := range paths {
+		id, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(path), spoolExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		s.nextID = max(s.nextID, id+1)
+
+		events, size, err := readSpoolSegment(path)
+		if err != nil {
+			return nil, fmt.Errorf("read spool %s: %w", path, err)
+		}
+		if len(events) == 0 {
+			os.Remove(path)
+			continue
+		}
+
+		s.segments = append(s.segments, &spoolSegment{
+			id:     id,
+			path:   path,
+			bytes:  size,
+			events: len(events),
+			first:  events[0].Timestamp,
+		})
+	}
+
+	return s, nil
+}
+
+/*
+* Append writes events after everything already spooled
+**/
+func (s *Spool) Append(events ...Event) error {
+	var buf []byte
+	for _, e := range events {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("encode event: %w", err)
+		}
+		buf = append(buf, line...)
+		buf = append(buf, '\n')
+	}
+	if len(buf) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrSpoolClosed
+	}
+
+	seg, err := s.writable()
+	if err != nil {
+		return err
+	}
+
+	if _, err := seg.file.Write(buf); err != nil {
+		return fmt.Errorf("write spool: %w", err)
+	}
+	if s.opts.Sync {
+		if err := seg.file.Sync(); err != nil {
+			return fmt.Errorf("sync spool: %w", err)
+		}
+	}
+
+	if seg.events == 0 {
+		seg.first = events[0].Timestamp
+	}
+	seg.bytes += int64(len(buf))
+	seg.events += len(events)
+	if seg.loaded != nil {
+		seg.loaded = append(seg.loaded, events...)
+	}
+
+	if seg.bytes >= s.opts.SegmentBytes {
+		seg.file.Close()
+		seg.file = nil
+	}
+
+	s.enforceCap()
+	return nil
+}
+
+/*
+* the last segment when it is still open for appends,
+* otherwise a new one
+**/
+func (s *Spool) writable() (*spoolSegment, error) {
+	if n := len(s.segments); n > 0 && s.segments[n-1].file != nil {
+		return s.segments[n-1], nil
+	}
+
+	id := s.nextID
+	s.nextID++
+
+	path := filepath.Join(s.opts.Dir, fmt.Sprintf("%020d%s", id, spoolExt))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND|os.O_TRUNC, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open spool: %w", err)
+	}
+
+	seg := &spoolSegment{id: id, path: path, file: file}
+	s.segments = append(s.segments, seg)
+	return seg, nil
+}
+
+/*
+* deletes the oldest segments while over MaxBytes,
+* the one being appended to is kept
+**/
+func (s *Spool) enforceCap() {
+	var total int64
+	for _, seg := range s.segments {
+		total += seg.bytes
+	}
+
+	for total > s.opts.MaxBytes && len(s.segments) > 1 {
+		head := s.segments[0]
+		total -= head.bytes
+		s.dropped += uint64(head.events - head.acked)
+		s.removeHead()
+	}
+}
+
+func (s *Spool) removeHead() {
+	head := s.segments[0]
+	if head.file != nil {
+		head.file.Close()
+	}
+	os.Remove(head.path)
+
+	s.segments[0] = nil
+	s.segments = s.segments[1:]
+	if s.reading == head {
+		s.reading = nil
+	}
+}
+
+/*
+* Next returns up to n of the oldest events not yet acknowledged,
+* the same events again until Ack is called. it returns nothing
+* when the spool is empty
+**/
+func (s *Spool) Next(n int) ([]Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil, ErrSpoolClosed
+	}
+
+	for len(s.segments) > 0 {
+		head := s.segments[0]
+		if head.loaded == nil {
+			events, _, err := readSpoolSegment(head.path)
+			if err != nil {
+				return nil, fmt.Errorf("read spool %s: %w", head.path, err)
+			}
+			head.loaded = events
+			head.events = len(events)
+		}
+
+		if head.acked < len(head.loaded) {
+			s.reading = head
+			end := min(len(head.loaded), head.acked+n)
+			return slices.Clone(head.loaded[head.acked:end]), nil
+		}
+
+		if head.file != nil {
+			return nil, nil
+		}
+		s.removeHead()
+	}
+
+	return nil, nil
+}
+
+/*
+* Ack marks the first n events last returned by Next as delivered.
+* it does nothing when their segment was dropped in the meantime
+**/
+func (s *Spool) Ack(n int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrSpoolClosed
+	}
+	if s.reading == nil || len(s.segments) == 0 || s.segments[0] != s.reading {
+		return nil
+	}
+
+	head := s.reading
+	head.acked = min(head.acked+n, len(head.loaded))
+	if head.acked < head.events {
+		return nil
+	}
+
+	if head.file == nil {
+		s.removeHead()
+		return nil
+	}
+
+	/*
+	* everything appended so far is delivered, start the
+	* open segment over so a restart replays nothing
+	**/
+	if err := head.file.Truncate(0); err != nil {
+		return fmt.Errorf("truncate spool: %w", err)
+	}
+	head.bytes, head.events, head.acked = 0, 0, 0
+	head.loaded = nil
+	return nil
+}
+
+func (s *Spool) Stats() SpoolStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := SpoolStats{Dropped: s.dropped}
+	for _, seg := range s.segments {
+		stats.Events += seg.events - seg.acked
+		stats.Bytes += seg.bytes
+		if seg.events > 0 {
+			stats.Segments++
+		}
+	}
+
+	if oldest, ok := s.oldest(); ok {
+		stats.OldestAge = max(s.now().Sub(oldest), 0)
+	}
+	return stats
+}
+
+func (s *Spool) oldest() (time.Time, bool) {
+	for _, seg := range s.segments {
+		switch {
+		case seg.loaded != nil && seg.acked < len(seg.loaded):
+			return seg.loaded[seg.acked].Timestamp, true
+		case seg.loaded == nil && seg.events > 0:
+			return seg.first, true
+		}
+	}
+	return time.Time{}, false
+}
+
+/*
+* Close closes the open segment, whatever is
+* spooled is picked up by the next OpenSpool
+**/
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	for _, seg := range s.segments {
+		if seg.file != nil {
+			seg.file.Close()
+			seg.file = nil
+		}
+		if seg.events == 0 {
+			os.Remove(seg.path)
+		}
+	}
+	return nil
+}
+
+/*
+* reads the events of a segment. a torn last line from a crash
+* mid write, or any line that doesn't decode, is skipped
+**/
+func readSpoolSegment(path string) ([]Event, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	var events []Event
+	var size int64
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		size += int64(len(line))
+
+		var e Event
+		if err := json.Unmarshal(line, &e); err != nil {
+			continue
+		}
+		events = append(events, e)
+	}
+
+	return events, size, nil
+}
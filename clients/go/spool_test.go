@@ -0,0 +1,250 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func openTestSpool(t *testing.T, opts SpoolOptions) *Spool {
+	t.Helper()
+
+	s, err := OpenSpool(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func spoolEvents(from, to int, ts time.Time) []Event {
+	var events []Event
+	for i := from; i < to; i++ {
+		events = append(events, Event{ID: fmt.Sprintf("evt-%02d", i), Timestamp: ts.Add(time.Duration(i) * time.Second)})
+	}
+	return events
+}
+
+func ids(events []Event) string {
+	out := ""
+	for _, e := range events {
+		out += e.ID + " "
+	}
+	return out
+}
+
+func TestSpoolReplaysInOrderAcrossRestarts(t *testing.T) {
+	dir := t.TempDir()
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	s := openTestSpool(t, SpoolOptions{Dir: dir, SegmentBytes: 200})
+	s.now = func() time.Time { return ts.Add(time.Minute) }
+
+	for _, e := range spoolEvents(0, 6, ts) {
+		if err := s.Append(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats := s.Stats()
+	if stats.Events != 6 || stats.Segments < 2 || stats.OldestAge != time.Minute {
+		t.Fatalf("Unexpected stats %+v", stats)
+	}
+
+	batch, err := s.Next(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch) == 0 || batch[0].ID != "evt-00" {
+		t.Fatalf("Expected the oldest events first, got %s", ids(batch))
+	}
+	s.Ack(1)
+	s.Close()
+
+	reopened := openTestSpool(t, SpoolOptions{Dir: dir, SegmentBytes: 200})
+	reopened.Append(spoolEvents(6, 7, ts)...)
+
+	var got []Event
+	for {
+		batch, err := reopened.Next(2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(batch) == 0 {
+			break
+		}
+		got = append(got, batch...)
+		reopened.Ack(len(batch))
+	}
+
+	/*
+	* the acknowledged event's segment wasn't finished,
+	* so it is read again and deduplicated by the server
+	**/
+	if want := ids(spoolEvents(0, 7, ts)); ids(got) != want {
+		t.Errorf("Expected %s, got %s", want, ids(got))
+	}
+	if stats := reopened.Stats(); stats.Events != 0 || stats.OldestAge != 0 {
+		t.Errorf("Expected an empty spool, got %+v", stats)
+	}
+}
+
+func TestSpoolDropsOldestBeyondCap(t *testing.T) {
+	s := openTestSpool(t, SpoolOptions{Dir: t.TempDir(), SegmentBytes: 100, MaxBytes: 300})
+
+	for _, e := range spoolEvents(0, 20, time.Now()) {
+		s.Append(e)
+	}
+
+	stats := s.Stats()
+	if stats.Bytes > 300+100 || stats.Dropped == 0 || stats.Events+int(stats.Dropped) != 20 {
+		t.Errorf("Expected the oldest segments to be dropped, got %+v", stats)
+	}
+
+	batch, _ := s.Next(1)
+	if len(batch) != 1 || batch[0].ID == "evt-00" {
+		t.Errorf("Expected the oldest event to be gone, got %s", ids(batch))
+	}
+}
+
+func TestSpoolSkipsTornLines(t *testing.T) {
+	dir := t.TempDir()
+
+	line, _ := json.Marshal(Event{ID: "whole"})
+	content := append(line, '\n')
+	content = append(content, []byte(`{"id":"torn`)...)
+	os.WriteFile(filepath.Join(dir, fmt.Sprintf("%020d%s", 3, spoolExt)), content, 0o644)
+
+	s := openTestSpool(t, SpoolOptions{Dir: dir})
+	batch, err := s.Next(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids(batch) != "whole " {
+		t.Errorf("Expected only the whole event, got %s", ids(batch))
+	}
+}
+
+func TestExporterSpoolsWhileServerIsDown(t *testing.T) {
+	var up atomic.Bool
+	var mu sync.Mutex
+	var received []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		events := acceptBatch(w, r)
+
+		mu.Lock()
+		for _, e := range events {
+			received = append(received, e.ID)
+		}
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	spool := openTestSpool(t, SpoolOptions{Dir: t.TempDir()})
+	exporter := NewClient(server.URL).NewExporter(fastRetries(ExporterOptions{
+		MaxRetries:    1,
+		FlushInterval: time.Hour,
+		Spool:         spool,
+	}))
+	defer exporter.Shutdown(context.Background())
+
+	ctx := context.Background()
+	for _, e := range spoolEvents(0, 3, time.Now()) {
+		exporter.Export(e)
+	}
+	exporter.Flush(ctx)
+
+	for _, e := range spoolEvents(3, 5, time.Now()) {
+		exporter.Export(e)
+	}
+	exporter.Flush(ctx)
+
+	stats := exporter.Stats()
+	if stats.Spooled != 5 || stats.Failed != 0 || stats.Spool.Events != 5 {
+		t.Fatalf("Expected every event to be spooled, got %+v %+v", stats, stats.Spool)
+	}
+
+	up.Store(true)
+	exporter.Flush(ctx)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if fmt.Sprint(received) != "[evt-00 evt-01 evt-02 evt-03 evt-04]" {
+		t.Errorf("Expected spooled events in order, got %v", received)
+	}
+	if stats := exporter.Stats(); stats.Sent != 5 || stats.Spool.Events != 0 {
+		t.Errorf("Expected the spool to be drained, got %+v %+v", stats, stats.Spool)
+	}
+}
+
+func TestExporterSpoolsOverflowInBackground(t *testing.T) {
+	received := make(chan struct{}, 16)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		<-release
+		acceptBatch(w, r)
+	}))
+	defer server.Close()
+
+	unblock := sync.OnceFunc(func() { close(release) })
+	defer unblock()
+
+	spool := openTestSpool(t, SpoolOptions{Dir: t.TempDir()})
+	exporter := NewClient(server.URL).NewExporter(ExporterOptions{BufferSize: 2, BatchSize: 1, Spool: spool})
+
+	exporter.Export(Event{Service: "api", Name: "request"})
+	<-received
+
+	/*
+	* a spool stuck on the disk must not hold up Export, two events
+	* fill the buffer and two more wait to be spooled
+	**/
+	spool.mu.Lock()
+	unlock := sync.OnceFunc(spool.mu.Unlock)
+	t.Cleanup(unlock)
+
+	exported := make(chan int)
+	go func() {
+		accepted := 0
+		for range 4 {
+			if exporter.Export(Event{Service: "api", Name: "request"}) {
+				accepted++
+			}
+		}
+		exported <- accepted
+	}()
+
+	select {
+	case accepted := <-exported:
+		if accepted != 4 {
+			t.Errorf("Expected every event to be accepted, got %d", accepted)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected Export not to wait on the spool")
+	}
+	unlock()
+
+	unblock()
+	if err := exporter.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	exporter.Shutdown(context.Background())
+
+	if stats := exporter.Stats(); stats.Spooled < 2 || stats.Sent != 5 || stats.Dropped != 0 {
+		t.Errorf("Expected the overflow to be spooled and sent, got %+v %+v", stats, stats.Spool)
+	}
+}
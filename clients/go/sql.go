@@ -0,0 +1,608 @@
+package client
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+type SQLOptions struct {
+	/*
+	* service spans are sent under, required by the server
+	**/
+	Service string
+
+	/*
+	* sends spans in the background when set, which is
+	* recommended, otherwise each span is sent as it ends
+	**/
+	Exporter *Exporter
+
+	/*
+	* only statements and transactions taking at least this long are
+	* recorded, along with anything that failed. 0 records everything
+	**/
+	SlowThreshold time.Duration
+
+	/*
+	* recorded as db.system, e.g. postgresql or sqlite
+	**/
+	System string
+
+	/*
+	* called when a span couldn't be sent
+	**/
+	OnError func(error)
+}
+
+/*
+* WrapDriver traces every query, exec and transaction run through
+* d. register the result with sql.Register and open it by that name
+**/
+func (c *Client) WrapDriver(d driver.Driver, opts SQLOptions) driver.Driver {
+	return &sqlDriver{base: d, t: &sqlTracer{client: c, opts: opts}}
+}
+
+/*
+* WrapConnector is WrapDriver for connectors, for use with sql.OpenDB
+**/
+func (c *Client) WrapConnector(connector driver.Connector, opts SQLOptions) driver.Connector {
+	d := &sqlDriver{base: connector.Driver(), t: &sqlTracer{client: c, opts: opts}}
+	return &sqlConnector{base: connector, driver: d}
+}
+
+type sqlTracer struct {
+	client *Client
+	opts   SQLOptions
+}
+
+/*
+* a child of the open transaction on conn, or of the span in ctx
+**/
+func (t *sqlTracer) start(ctx context.Context, conn *sqlConn) (span, parent SpanContext) {
+	if conn.tx != nil {
+		return startSpan(conn.tx.span, true), conn.tx.span
+	}
+
+	parent, ok := SpanFromContext(ctx)
+	return startSpan(parent, ok), parent
+}
+
+/*
+* rows is -1 when unknown. fast statements that succeeded are
+* skipped when a slow threshold is set
+**/
+func (t *sqlTracer) finish(ctx context.Context, op, query string, span, parent SpanContext, start time.Time, rows int64, err error) {
+	duration := time.Since(start)
+	slow := t.opts.SlowThreshold > 0 && duration >= t.opts.SlowThreshold
+	if err == nil && t.opts.SlowThreshold > 0 && !slow {
+		return
+	}
+
+	data := map[string]any{
+		"span.kind":   "client",
+		"span_id":     span.SpanID,
+		"db.op":       op,
+		"duration_ms": float64(duration.Microseconds()) / 1000,
+	}
+	if parent.SpanID != "" {
+		data["parent_span_id"] = parent.SpanID
+	}
+	if query != "" {
+		data["db.statement"] = sanitizeSQL(query)
+	}
+	if rows >= 0 {
+		data["db.rows"] = rows
+	}
+	if t.opts.System != "" {
+		data["db.system"] = t.opts.System
+	}
+
+	level := "info"
+	switch {
+	case err != nil:
+		level = "error"
+		data["error"] = err.Error()
+	case slow:
+		level = "warn"
+		data["db.slow"] = true
+	}
+
+	ev := Event{
+		ID:        newEventID(),
+		Timestamp: start.UTC(),
+		Level:     level,
+		Service:   t.opts.Service,
+		Name:      "db." + op,
+		TraceID:   span.TraceID,
+		Data:      data,
+	}
+
+	if err := t.client.emit(context.WithoutCancel(ctx), t.opts.Exporter, ev); err != nil && t.opts.OnError != nil {
+		t.opts.OnError(err)
+	}
+}
+
+type sqlDriver struct {
+	base driver.Driver
+	t    *sqlTracer
+}
+
+func (d *sqlDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.base.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlConn{base: conn, t: d.t}, nil
+}
+
+func (d *sqlDriver) OpenConnector(name string) (driver.Connector, error) {
+	if dc, ok := d.base.(driver.DriverContext); ok {
+		connector, err := dc.OpenConnector(name)
+		if err != nil {
+			return nil, err
+		}
+		return &sqlConnector{base: connector, driver: d}, nil
+	}
+	return &sqlConnector{base: dsnConnector{name: name, driver: d.base}, driver: d}, nil
+}
+
+type dsnConnector struct {
+	name   string
+	driver driver.Driver
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.name)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+type sqlConnector struct {
+	base   driver.Connector
+	driver *sqlDriver
+}
+
+func (c *sqlConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.base.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlConn{base: conn, t: c.driver.t}, nil
+}
+
+func (c *sqlConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+/*
+* database/sql uses a conn from one goroutine at a time,
+* so the open transaction needs no lock
+**/
+type sqlConn struct {
+	base driver.Conn
+	t    *sqlTracer
+	tx   *sqlTx
+}
+
+func (c *sqlConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *sqlConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+	if pc, ok := c.base.(driver.ConnPrepareContext); ok {
+		stmt, err = pc.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.base.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sqlStmt{base: stmt, conn: c, query: query}, nil
+}
+
+func (c *sqlConn) Close() error {
+	return c.base.Close()
+}
+
+func (c *sqlConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *sqlConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	span, parent := c.t.start(ctx, c)
+	start := time.Now()
+
+	var tx driver.Tx
+	var err error
+	if bt, ok := c.base.(driver.ConnBeginTx); ok {
+		tx, err = bt.BeginTx(ctx, opts)
+	} else if opts.Isolation != 0 || opts.ReadOnly {
+		err = errors.New("sql: driver does not support non-default transaction options")
+	} else {
+		tx, err = c.base.Begin()
+	}
+	if err != nil {
+		c.t.finish(ctx, "begin", "", span, parent, start, -1, err)
+		return nil, err
+	}
+
+	c.tx = &sqlTx{base: tx, conn: c, ctx: ctx, span: span, parent: parent, start: start}
+	return c.tx, nil
+}
+
+func (c *sqlConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	ex, ok := c.base.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	span, parent := c.t.start(ctx, c)
+	start := time.Now()
+
+	res, err := ex.ExecContext(ctx, query, args)
+	if errors.Is(err, driver.ErrSkip) {
+		return nil, err
+	}
+	c.t.finish(ctx, "exec", query, span, parent, start, rowsAffected(res), err)
+
+	return res, err
+}
+
+func (c *sqlConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.base.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	span, parent := c.t.start(ctx, c)
+	start := time.Now()
+
+	rows, err := q.QueryContext(ctx, query, args)
+	if errors.Is(err, driver.ErrSkip) {
+		return nil, err
+	}
+	if err != nil {
+		c.t.finish(ctx, "query", query, span, parent, start, -1, err)
+		return nil, err
+	}
+
+	return &sqlRows{base: rows, finish: func(n int64, err error) {
+		c.t.finish(ctx, "query", query, span, parent, start, n, err)
+	}}, nil
+}
+
+func (c *sqlConn) Ping(ctx context.Context) error {
+	if p, ok := c.base.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *sqlConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.base.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *sqlConn) IsValid() bool {
+	if v, ok := c.base.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *sqlConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if nc, ok := c.base.(driver.NamedValueChecker); ok {
+		return nc.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+/*
+* a span from begin to commit or rollback, statements
+* run in the transaction are its children
+**/
+type sqlTx struct {
+	base driver.Tx
+	conn *sqlConn
+
+	ctx          context.Context
+	span, parent SpanContext
+	start        time.Time
+}
+
+func (tx *sqlTx) Commit() error {
+	return tx.end("commit", tx.base.Commit())
+}
+
+func (tx *sqlTx) Rollback() error {
+	return tx.end("rollback", tx.base.Rollback())
+}
+
+func (tx *sqlTx) end(outcome string, err error) error {
+	tx.conn.tx = nil
+	tx.conn.t.finish(tx.ctx, "tx."+outcome, "", tx.span, tx.parent, tx.start, -1, err)
+	return err
+}
+
+type sqlStmt struct {
+	base  driver.Stmt
+	conn  *sqlConn
+	query string
+}
+
+func (s *sqlStmt) Close() error {
+	return s.base.Close()
+}
+
+func (s *sqlStmt) NumInput() int {
+	return s.base.NumInput()
+}
+
+func (s *sqlStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.base.Exec(args)
+}
+
+func (s *sqlStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.base.Query(args)
+}
+
+func (s *sqlStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	span, parent := s.conn.t.start(ctx, s.conn)
+	start := time.Now()
+
+	var res driver.Result
+	var err error
+	if se, ok := s.base.(driver.StmtExecContext); ok {
+		res, err = se.ExecContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValues(args); err == nil {
+			res, err = s.base.Exec(values)
+		}
+	}
+	s.conn.t.finish(ctx, "exec", s.query, span, parent, start, rowsAffected(res), err)
+
+	return res, err
+}
+
+func (s *sqlStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	span, parent := s.conn.t.start(ctx, s.conn)
+	start := time.Now()
+
+	var rows driver.Rows
+	var err error
+	if sq, ok := s.base.(driver.StmtQueryContext); ok {
+		rows, err = sq.QueryContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValues(args); err == nil {
+			rows, err = s.base.Query(values)
+		}
+	}
+	if err != nil {
+		s.conn.t.finish(ctx, "query", s.query, span, parent, start, -1, err)
+		return nil, err
+	}
+
+	return &sqlRows{base: rows, finish: func(n int64, err error) {
+		s.conn.t.finish(ctx, "query", s.query, span, parent, start, n, err)
+	}}, nil
+}
+
+func (s *sqlStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if nc, ok := s.base.(driver.NamedValueChecker); ok {
+		return nc.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+func namedValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errors.New("sql: driver does not support the use of Named Parameters")
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
+
+func rowsAffected(res driver.Result) int64 {
+	if res == nil {
+		return -1
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+/*
+* counts rows as they are read, the query span ends when the
+* rows are closed. optional interfaces of the driver's rows are
+* passed through, with database/sql's defaults when missing
+**/
+type sqlRows struct {
+	base   driver.Rows
+	count  int64
+	err    error
+	finish func(int64, error)
+	once   sync.Once
+}
+
+func (r *sqlRows) Columns() []string {
+	return r.base.Columns()
+}
+
+func (r *sqlRows) Close() error {
+	err := r.base.Close()
+	r.once.Do(func() { r.finish(r.count, r.err) })
+	return err
+}
+
+func (r *sqlRows) Next(dest []driver.Value) error {
+	err := r.base.Next(dest)
+	switch {
+	case err == nil:
+		r.count++
+	case err != io.EOF:
+		r.err = err
+	}
+	return err
+}
+
+func (r *sqlRows) HasNextResultSet() bool {
+	if n, ok := r.base.(driver.RowsNextResultSet); ok {
+		return n.HasNextResultSet()
+	}
+	return false
+}
+
+func (r *sqlRows) NextResultSet() error {
+	if n, ok := r.base.(driver.RowsNextResultSet); ok {
+		return n.NextResultSet()
+	}
+	return io.EOF
+}
+
+func (r *sqlRows) ColumnTypeScanType(index int) reflect.Type {
+	if c, ok := r.base.(driver.RowsColumnTypeScanType); ok {
+		return c.ColumnTypeScanType(index)
+	}
+	return reflect.TypeFor[any]()
+}
+
+func (r *sqlRows) ColumnTypeDatabaseTypeName(index int) string {
+	if c, ok := r.base.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return c.ColumnTypeDatabaseTypeName(index)
+	}
+	return ""
+}
+
+func (r *sqlRows) ColumnTypeLength(index int) (int64, bool) {
+	if c, ok := r.base.(driver.RowsColumnTypeLength); ok {
+		return c.ColumnTypeLength(index)
+	}
+	return 0, false
+}
+
+func (r *sqlRows) ColumnTypeNullable(index int) (bool, bool) {
+	if c, ok := r.base.(driver.RowsColumnTypeNullable); ok {
+		return c.ColumnTypeNullable(index)
+	}
+	return false, false
+}
+
+func (r *sqlRows) ColumnTypePrecisionScale(index int) (int64, int64, bool) {
+	if c, ok := r.base.(driver.RowsColumnTypePrecisionScale); ok {
+		return c.ColumnTypePrecisionScale(index)
+	}
+	return 0, 0, false
+}
+
+/*
+* replaces literals with ? and drops comments and extra
+* whitespace, so statements carry no values and group by shape
+**/
+func sanitizeSQL(query string) string {
+	var b strings.Builder
+	b.Grow(len(query))
+
+	space := false
+	emit := func(s string) {
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteString(s)
+	}
+
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			space = true
+			i++
+
+		case strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			space = true
+			i += end
+
+		case strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				end = len(query) - i - 4
+			}
+			space = true
+			i += end + 4
+
+		case c == '\'':
+			i++
+			for i < len(query) {
+				if query[i] == '\'' {
+					if i+1 < len(query) && query[i+1] == '\'' {
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				i++
+			}
+			emit("?")
+
+		case c == '"' || c == '`':
+			end := strings.IndexByte(query[i+1:], c)
+			if end < 0 {
+				end = len(query) - i - 2
+			}
+			emit(query[i : i+end+2])
+			i += end + 2
+
+		case c >= '0' && c <= '9' && !continuesWord(b.String(), space):
+			for i < len(query) && (isWordByte(query[i]) || query[i] == '.') {
+				i++
+			}
+			emit("?")
+
+		default:
+			emit(query[i : i+1])
+			i++
+		}
+	}
+
+	return b.String()
+}
+
+/*
+* digits right after an identifier or placeholder
+* prefix belong to it, as in t1 or $1
+**/
+func continuesWord(out string, space bool) bool {
+	if space || out == "" {
+		return false
+	}
+	last := out[len(out)-1]
+	return isWordByte(last) || last == '$' || last == '@' || last == ':' || last == '?'
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
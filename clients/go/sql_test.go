@@ -0,0 +1,208 @@
+package client
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+* a driver that answers every query with three rows and every
+* exec with two affected rows, statements containing "fail" fail
+**/
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return fakeConn{}, nil
+}
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return fakeStmt{query: query}, nil
+}
+
+func (fakeConn) Close() error {
+	return nil
+}
+
+func (fakeConn) Begin() (driver.Tx, error) {
+	return fakeTx{}, nil
+}
+
+func (fakeConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	return fakeExec(query)
+}
+
+func (fakeConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	return fakeQuery(query)
+}
+
+func fakeExec(query string) (driver.Result, error) {
+	if strings.Contains(query, "fail") {
+		return nil, errors.New("syntax error")
+	}
+	return driver.RowsAffected(2), nil
+}
+
+func fakeQuery(query string) (driver.Rows, error) {
+	if strings.Contains(query, "fail") {
+		return nil, errors.New("syntax error")
+	}
+	return &fakeRows{left: 3}, nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+/*
+* only the legacy methods, so the wrapper has to fall back
+**/
+type fakeStmt struct {
+	query string
+}
+
+func (fakeStmt) Close() error                                 { return nil }
+func (fakeStmt) NumInput() int                                { return -1 }
+func (s fakeStmt) Exec([]driver.Value) (driver.Result, error) { return fakeExec(s.query) }
+func (s fakeStmt) Query([]driver.Value) (driver.Rows, error)  { return fakeQuery(s.query) }
+
+type fakeRows struct {
+	left int
+}
+
+func (*fakeRows) Columns() []string { return []string{"id"} }
+func (*fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.left == 0 {
+		return io.EOF
+	}
+	r.left--
+	dest[0] = int64(r.left)
+	return nil
+}
+
+func openTracedDB(t *testing.T, c *Client, opts SQLOptions) *sql.DB {
+	t.Helper()
+
+	db := sql.OpenDB(c.WrapConnector(dsnConnector{driver: fakeDriver{}}, opts))
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func spansByName(t *testing.T, s *ingestServer) map[string][]Event {
+	t.Helper()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	spans := map[string][]Event{}
+	for _, e := range s.events {
+		spans[e.Name] = append(spans[e.Name], e)
+	}
+	return spans
+}
+
+func TestSQLSpans(t *testing.T) {
+	collector, client := newIngestServer(t, 0, 0)
+	db := openTracedDB(t, client, SQLOptions{Service: "users", System: "fake"})
+
+	parent := SpanContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7"}
+	ctx := ContextWithSpan(context.Background(), parent)
+
+	if _, err := db.ExecContext(ctx, "INSERT INTO users (name, age) VALUES ('o''brien', 36)"); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT id FROM users WHERE age > $1", 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+	}
+	rows.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.ExecContext(ctx, "DELETE FROM sessions")
+	tx.Commit()
+
+	spans := spansByName(t, collector)
+	if len(spans["db.exec"]) != 2 || len(spans["db.query"]) != 1 || len(spans["db.tx.commit"]) != 1 {
+		t.Fatalf("Unexpected spans %v", spans)
+	}
+
+	query := spans["db.query"][0]
+	if query.Data["db.statement"] != "SELECT id FROM users WHERE age > $1" || query.Data["db.rows"] != 3.0 || query.Data["db.system"] != "fake" {
+		t.Errorf("Unexpected query span %v", query.Data)
+	}
+	if query.TraceID != parent.TraceID || query.Data["parent_span_id"] != parent.SpanID {
+		t.Errorf("Expected the query to be a child of the context span, got %+v", query)
+	}
+
+	commit := spans["db.tx.commit"][0]
+	for _, exec := range spans["db.exec"] {
+		switch exec.Data["db.statement"] {
+		case "INSERT INTO users (name, age) VALUES (?, ?)":
+			if exec.Data["db.rows"] != 2.0 || exec.Data["parent_span_id"] != parent.SpanID {
+				t.Errorf("Unexpected insert span %v", exec.Data)
+			}
+		case "DELETE FROM sessions":
+			if exec.Data["parent_span_id"] != commit.Data["span_id"] {
+				t.Errorf("Expected statements in a transaction to be its children, got %v", exec.Data)
+			}
+		default:
+			t.Errorf("Unexpected statement %v", exec.Data["db.statement"])
+		}
+	}
+}
+
+func TestSQLSlowThreshold(t *testing.T) {
+	collector, client := newIngestServer(t, 0, 0)
+	db := openTracedDB(t, client, SQLOptions{Service: "users", SlowThreshold: time.Hour})
+
+	db.Exec("UPDATE users SET seen = 1")
+	if _, err := db.Exec("UPDATE users SET fail = 1"); err == nil {
+		t.Fatal("Expected the statement to fail")
+	}
+
+	stmt, err := db.Prepare("SELECT fail FROM users WHERE id = ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	stmt.Query(1)
+
+	spans := spansByName(t, collector)
+	if len(spans["db.exec"]) != 1 || len(spans["db.query"]) != 1 {
+		t.Fatalf("Expected only the failed statements to be recorded, got %v", spans)
+	}
+	exec := spans["db.exec"][0]
+	if exec.Level != "error" || exec.Data["error"] != "syntax error" || exec.Data["db.statement"] != "UPDATE users SET fail = ?" {
+		t.Errorf("Unexpected span %+v", exec)
+	}
+}
+
+func TestSanitizeSQL(t *testing.T) {
+	tests := map[string]string{
+		"SELECT * FROM t1 WHERE id = 42":                                "SELECT * FROM t1 WHERE id = ?",
+		"select name\n  from users -- by email\n where email = 'a@b.c'": "select name from users where email = ?",
+		"INSERT INTO \"order 2\" VALUES (1.5e3, 'it''s', $1, :name)":    "INSERT INTO \"order 2\" VALUES (?, ?, $1, :name)",
+		"/* app=web */ UPDATE t SET n = n - 1 WHERE id IN (1, 2, 3)":    "UPDATE t SET n = n - ? WHERE id IN (?, ?, ?)",
+	}
+	for query, want := range tests {
+		if got := sanitizeSQL(query); got != want {
+			t.Errorf("sanitizeSQL(%q) = %q, want %q", query, got, want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"context"
+	cryptorand "crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
+/*
+* SpanContext is the trace a piece of work belongs to and the span
+* doing it, ids are lowercase hex as in w3c trace context
+**/
+type SpanContext struct {
+	TraceID string
+	SpanID  string
+	Sampled bool
+}
+
+/*
+* Traceparent formats s as a traceparent header, it is empty
+* when the ids aren't valid w3c ids
+**/
+func (s SpanContext) Traceparent() string {
+	if !validID(s.TraceID, 32) || !validID(s.SpanID, 16) {
+		return ""
+	}
+
+	flags := "00"
+	if s.Sampled {
+		flags = "01"
+	}
+	return "00-" + s.TraceID + "-" + s.SpanID + "-" + flags
+}
+
+/*
+* ParseTraceparent reads a traceparent header, unknown versions
+* are read as far as version 00 defines them
+**/
+func ParseTraceparent(header string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 {
+		return SpanContext{}, false
+	}
+
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	if !isHex(version, 2) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return SpanContext{}, false
+	}
+	if !validID(traceID, 32) || !validID(spanID, 16) || !isHex(flags, 2) {
+		return SpanContext{}, false
+	}
+
+	b, _ := hex.DecodeString(flags)
+
+	return SpanContext{TraceID: traceID, SpanID: spanID, Sampled: b[0]&1 == 1}, true
+}
+
+/*
+* lowercase hex of n characters that isn't all zeros
+**/
+func validID(id string, n int) bool {
+	return isHex(id, n) && strings.Trim(id, "0") != ""
+}
+
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func newTraceID() string {
+	return randomHex(16)
+}
+
+func newSpanID() string {
+	return randomHex(8)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	cryptorand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+type spanKey struct{}
+
+func ContextWithSpan(ctx context.Context, span SpanContext) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+func SpanFromContext(ctx context.Context) (SpanContext, bool) {
+	if ctx == nil {
+		return SpanContext{}, false
+	}
+	span, ok := ctx.Value(spanKey{}).(SpanContext)
+	return span, ok && span.TraceID != ""
+}
+
+/*
+* ContextWithTraceID marks ctx as part of a trace, events
+* logged with it are sent with that trace id
+**/
+func ContextWithTraceID(ctx context.Context, traceID string) context.Context {
+	return ContextWithSpan(ctx, SpanContext{TraceID: traceID})
+}
+
+func TraceIDFromContext(ctx context.Context) (string, bool) {
+	span, ok := SpanFromContext(ctx)
+	return span.TraceID, ok
+}
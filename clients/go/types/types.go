@@ -0,0 +1,91 @@
+/*
+* Package types holds what the scopion http api sends and receives.
+* the server and the go client both use these, so they can't drift
+**/
+package types
+
+import "time"
+
+type Event struct {
+	ID        string         `json:"id"`
+	Project   string         `json:"project"`
+	Timestamp time.Time      `json:"timestamp"`
+	Level     string         `json:"level"`
+	Service   string         `json:"service"`
+	Name      string         `json:"name"`
+	TraceID   string         `json:"trace_id"`
+	Data      map[string]any `json:"data,omitempty"`
+
+	/*
+	* fraction of similar events that were kept when this one was
+	* sampled, 0 when it wasn't. only written, reads leave it empty
+	**/
+	SampleRate float64 `json:"sample_rate,omitempty"`
+}
+
+/*
+* TotalEvents is extrapolated from sampled events,
+* StoredEvents is what is actually in the store
+**/
+type Stats struct {
+	TotalEvents    int     `json:"total_events"`
+	StoredEvents   int     `json:"stored_events"`
+	ErrorRate      float64 `json:"error_rate"`
+	ActiveServices int     `json:"active_services"`
+}
+
+type ServiceInfo struct {
+	Name         string    `json:"name"`
+	ErrorCount   int       `json:"error_count"`
+	LastActivity time.Time `json:"last_activity"`
+	EventCount   int       `json:"event_count"`
+}
+
+type ErrorByService struct {
+	Service string `json:"service"`
+	Count   int    `json:"count"`
+}
+
+type ThroughputData struct {
+	Time   string `json:"time"`
+	Events int    `json:"events"`
+}
+
+type TraceInfo struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Service   string    `json:"service"`
+	Duration  int       `json:"duration"` // Placeholder, will need actual trace data
+	Spans     int       `json:"spans"`    // Placeholder
+	Timestamp time.Time `json:"timestamp"`
+	HasError  bool      `json:"has_error"`
+}
+
+/*
+* a rule an ingested event breaks
+**/
+type Violation struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+/*
+* BatchResult answers a batch ingest. events that weren't accepted
+* are listed by their position in the request
+**/
+type BatchResult struct {
+	Accepted int              `json:"accepted"`
+	Rejected []BatchRejection `json:"rejected"`
+}
+
+/*
+* Status is what a request holding only this event would have been
+* answered with, RetryAfter in seconds comes with 429 and 503
+**/
+type BatchRejection struct {
+	Index      int         `json:"index"`
+	Status     int         `json:"status"`
+	Error      string      `json:"error"`
+	Violations []Violation `json:"violations,omitempty"`
+	RetryAfter int         `json:"retry_after,omitempty"`
+}
@@ -5,10 +5,12 @@ go 1.25.5
 require (
 	github.com/fsouza/go-dockerclient v1.12.3
 	github.com/google/uuid v1.6.0
+	github.com/gorilla/websocket v1.5.3
 	github.com/jackc/pgx/v5 v5.7.5
 	github.com/mattn/go-sqlite3 v1.14.33
 	github.com/pressly/goose/v3 v3.26.0
 	github.com/spf13/cobra v1.10.2
+	github.com/xonoxc/scopion/clients/go v0.0.0
 )
 
 require (
@@ -43,3 +45,5 @@ require (
 	golang.org/x/sys v0.38.0 // indirect
 	golang.org/x/text v0.27.0 // indirect
 )
+
+replace github.com/xonoxc/scopion/clients/go => ./clients/go
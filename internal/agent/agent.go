@@ -0,0 +1,135 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/xonoxc/scopion/internal/model"
+)
+
+/*
+* Agent tails the configured files and forwards what it reads.
+* offsets are only persisted once the events read up to them
+* have been delivered or spooled, so a crash replays rather than loses
+**/
+type Agent struct {
+	cfg       *Config
+	tailers   []*Tailer
+	offsets   *Offsets
+	buffer    *DiskBuffer
+	forwarder *Forwarder
+	pending   []model.Event
+
+	reportedDrops   int64
+	reportedRejects int64
+}
+
+func New(cfg *Config, sender Sender) (*Agent, error) {
+	offsets, err := LoadOffsets(cfg.StatePath)
+	if err != nil {
+		return nil, err
+	}
+
+	tailers := make([]*Tailer, 0, len(cfg.Files))
+	for i, f := range cfg.Files {
+		t, err := NewTailer(f, offsets)
+		if err != nil {
+			return nil, fmt.Errorf("files[%d]: %w", i, err)
+		}
+		tailers = append(tailers, t)
+	}
+
+	buffer := NewDiskBuffer(cfg.BufferPath, cfg.MaxBufferBytes)
+
+	return &Agent{
+		cfg:       cfg,
+		tailers:   tailers,
+		offsets:   offsets,
+		buffer:    buffer,
+		forwarder: NewForwarder(sender, buffer, cfg.BatchSize, cfg.MaxRetries),
+	}, nil
+}
+
+func (a *Agent) Run(ctx context.Context) error {
+	defer a.close()
+
+	pollTicker := time.NewTicker(a.cfg.PollInterval.Duration)
+	defer pollTicker.Stop()
+
+	flushTicker := time.NewTicker(a.cfg.FlushInterval.Duration)
+	defer flushTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			/*
+			* last flush gets a fresh context so
+			* shutdown still delivers or spools
+			**/
+			a.poll()
+			return a.flush(context.Background())
+
+		case <-pollTicker.C:
+			a.poll()
+			if len(a.pending) >= a.cfg.BatchSize {
+				if err := a.flush(ctx); err != nil {
+					log.Printf("agent: flush failed: %v", err)
+				}
+			}
+
+		case <-flushTicker.C:
+			if err := a.flush(ctx); err != nil {
+				log.Printf("agent: flush failed: %v", err)
+			}
+		}
+	}
+}
+
+func (a *Agent) poll() {
+	for _, t := range a.tailers {
+		events, err := t.Poll()
+		if err != nil {
+			log.Printf("agent: %v", err)
+		}
+		a.pending = append(a.pending, events...)
+	}
+}
+
+func (a *Agent) flush(ctx context.Context) error {
+	/*
+	* retries whatever is spooled even when nothing new was read
+	**/
+	if len(a.pending) == 0 && a.buffer.Size() > 0 {
+		if err := a.forwarder.Forward(ctx, nil); err != nil {
+			return err
+		}
+	}
+
+	for len(a.pending) > 0 {
+		n := min(len(a.pending), a.cfg.BatchSize)
+
+		if err := a.forwarder.Forward(ctx, a.pending[:n]); err != nil {
+			return err
+		}
+		a.pending = a.pending[n:]
+	}
+
+	if dropped := a.buffer.Dropped(); dropped > a.reportedDrops {
+		log.Printf("agent: buffer full, %d events dropped so far", dropped)
+		a.reportedDrops = dropped
+	}
+	if rejected := a.forwarder.Rejected(); rejected > a.reportedRejects {
+		log.Printf("agent: %d events rejected by the server so far", rejected)
+		a.reportedRejects = rejected
+	}
+
+	return a.offsets.Save()
+}
+
+func (a *Agent) close() {
+	for _, t := range a.tailers {
+		t.Close()
+	}
+}
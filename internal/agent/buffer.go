@@ -0,0 +1,166 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+
+	"github.com/xonoxc/scopion/internal/model"
+)
+
+const maxBufferedLine = 4 * 1024 * 1024
+
+/*
+* DiskBuffer spools events as JSON lines while the server
+* is unreachable. once it reaches maxBytes new events are
+* dropped and counted rather than filling the disk
+**/
+type DiskBuffer struct {
+	path     string
+	maxBytes int64
+	dropped  atomic.Int64
+}
+
+func NewDiskBuffer(path string, maxBytes int64) *DiskBuffer {
+	return &DiskBuffer{
+		path:     path,
+		maxBytes: maxBytes,
+	}
+}
+
+func (b *DiskBuffer) Dropped() int64 {
+	return b.dropped.Load()
+}
+
+func (b *DiskBuffer) Size() int64 {
+	info, err := os.Stat(b.path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+func (b *DiskBuffer) Append(events []model.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	f, err := os.OpenFile(b.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open buffer: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat buffer: %w", err)
+	}
+	size := info.Size()
+
+	w := bufio.NewWriter(f)
+	for i, e := range events {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("failed to marshal buffered event: %w", err)
+		}
+
+		if size+int64(len(line))+1 > b.maxBytes {
+			b.dropped.Add(int64(len(events) - i))
+			break
+		}
+
+		w.Write(line)
+		w.WriteByte('\n')
+		size += int64(len(line)) + 1
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write buffer: %w", err)
+	}
+
+	return f.Sync()
+}
+
+/*
+* Drain hands buffered events to send in order, batchSize at a time.
+* send returns the events of a batch it couldn't deliver, on the
+* first such batch they are written back ahead of the rest and
+* its error returned
+**/
+func (b *DiskBuffer) Drain(batchSize int, send func([]model.Event) ([]model.Event, error)) error {
+	raw, err := os.ReadFile(b.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read buffer: %w", err)
+	}
+
+	var events []model.Event
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxBufferedLine)
+	for scanner.Scan() {
+		var e model.Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// a torn write from a crash, nothing to recover
+			continue
+		}
+		events = append(events, e)
+	}
+
+	for len(events) > 0 {
+		n := min(len(events), batchSize)
+		if unsent, err := send(events[:n]); err != nil {
+			if rewriteErr := b.rewrite(slices.Concat(unsent, events[n:])); rewriteErr != nil {
+				return rewriteErr
+			}
+			return err
+		}
+		events = events[n:]
+	}
+
+	if err := os.Remove(b.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to clear buffer: %w", err)
+	}
+
+	return nil
+}
+
+/*
+* replaces the buffer with the given events through a temp
+* file and rename, so a crash never loses what was spooled
+**/
+func (b *DiskBuffer) rewrite(events []model.Event) error {
+	tmp, err := os.CreateTemp(filepath.Dir(b.path), ".buffer-*")
+	if err != nil {
+		return fmt.Errorf("failed to create buffer: %w", err)
+	}
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return fmt.Errorf("failed to marshal buffered event: %w", err)
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write buffer: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write buffer: %w", err)
+	}
+
+	return os.Rename(tmp.Name(), b.path)
+}
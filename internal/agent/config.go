@@ -0,0 +1,151 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+/*
+* durations are written as strings in the config file ("5s", "250ms")
+**/
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string: %w", err)
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	d.Duration = parsed
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+const (
+	PARSER_JSON   = "json"
+	PARSER_LOGFMT = "logfmt"
+	PARSER_REGEX  = "regex"
+)
+
+type ParserConfig struct {
+	Type    string `json:"type"`
+	Pattern string `json:"pattern,omitempty"`
+}
+
+/*
+* one tailed source, paths may be glob patterns.
+* Service is used when the parsed line does not name one
+**/
+type FileConfig struct {
+	Paths   []string     `json:"paths"`
+	Service string       `json:"service"`
+	Parser  ParserConfig `json:"parser"`
+}
+
+type Config struct {
+	Server string       `json:"server"`
+	Files  []FileConfig `json:"files"`
+
+	/*
+	* ingest key, needed when the server runs with auth
+	**/
+	APIKey string `json:"api_key"`
+
+	/*
+	* where read offsets are persisted between restarts
+	**/
+	StatePath string `json:"state_path"`
+
+	/*
+	* events that could not be delivered are spooled here
+	**/
+	BufferPath     string `json:"buffer_path"`
+	MaxBufferBytes int64  `json:"max_buffer_bytes"`
+
+	BatchSize     int      `json:"batch_size"`
+	FlushInterval Duration `json:"flush_interval"`
+	PollInterval  Duration `json:"poll_interval"`
+	MaxRetries    int      `json:"max_retries"`
+}
+
+func LoadConfig(path string) (*Config, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	cfg.applyDefaults()
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+func (c *Config) applyDefaults() {
+	if c.Server == "" {
+		c.Server = "http://localhost:8080"
+	}
+	if c.StatePath == "" {
+		c.StatePath = "./scopion-agent.state"
+	}
+	if c.BufferPath == "" {
+		c.BufferPath = "./scopion-agent.buffer"
+	}
+	if c.MaxBufferBytes <= 0 {
+		c.MaxBufferBytes = 64 * 1024 * 1024
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 100
+	}
+	if c.FlushInterval.Duration <= 0 {
+		c.FlushInterval.Duration = 2 * time.Second
+	}
+	if c.PollInterval.Duration <= 0 {
+		c.PollInterval.Duration = 500 * time.Millisecond
+	}
+	if c.MaxRetries <= 0 {
+		c.MaxRetries = 3
+	}
+	for i := range c.Files {
+		if c.Files[i].Parser.Type == "" {
+			c.Files[i].Parser.Type = PARSER_JSON
+		}
+	}
+}
+
+func (c *Config) Validate() error {
+	if len(c.Files) == 0 {
+		return errors.New("config: at least one file source is required")
+	}
+
+	for i, f := range c.Files {
+		if len(f.Paths) == 0 {
+			return fmt.Errorf("config: files[%d] has no paths", i)
+		}
+		if _, err := NewParser(f.Parser); err != nil {
+			return fmt.Errorf("config: files[%d]: %w", i, err)
+		}
+	}
+
+	return nil
+}
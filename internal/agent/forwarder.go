@@ -0,0 +1,132 @@
+package agent
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	client "github.com/xonoxc/scopion/clients/go"
+	"github.com/xonoxc/scopion/internal/model"
+)
+
+/*
+* anything that can deliver a batch of events. errs holds the error
+* of each event it didn't take, err a failure of the whole batch.
+* runner.ClientSink is the one used against a server
+**/
+type Sender interface {
+	SendBatch(events []model.Event) (errs []error, err error)
+}
+
+/*
+* Forwarder sends events a batch per request, retrying those the
+* server couldn't take for now and falling back to the disk buffer
+* when it stays unreachable.
+* spooled events are always replayed before newer ones. events
+* the server will never take (client.IsRetryable is false) are
+* dropped and counted instead of holding up the rest
+**/
+type Forwarder struct {
+	sender     Sender
+	buffer     *DiskBuffer
+	batchSize  int
+	maxRetries int
+	backoff    time.Duration
+	rejected   atomic.Int64
+}
+
+func NewForwarder(sender Sender, buffer *DiskBuffer, batchSize, maxRetries int) *Forwarder {
+	return &Forwarder{
+		sender:     sender,
+		buffer:     buffer,
+		batchSize:  batchSize,
+		maxRetries: maxRetries,
+		backoff:    200 * time.Millisecond,
+	}
+}
+
+/*
+* events the server rejected for good
+**/
+func (f *Forwarder) Rejected() int64 {
+	return f.rejected.Load()
+}
+
+/*
+* only fails when events could neither be sent nor spooled
+**/
+func (f *Forwarder) Forward(ctx context.Context, events []model.Event) error {
+	if f.buffer.Size() > 0 {
+		if err := f.buffer.Drain(f.batchSize, f.sendBatch); err != nil {
+			log.Printf("agent: server unreachable, buffering %d events: %v", len(events), err)
+			return f.buffer.Append(events)
+		}
+	}
+
+	unsent, err := f.send(ctx, events)
+	if err != nil {
+		log.Printf("agent: server unreachable, buffering %d events: %v", len(unsent), err)
+		return f.buffer.Append(unsent)
+	}
+
+	return nil
+}
+
+/*
+* returns the events still unsent once the retries ran out
+**/
+func (f *Forwarder) send(ctx context.Context, events []model.Event) ([]model.Event, error) {
+	delay := f.backoff
+
+	for attempt := 1; ; attempt++ {
+		unsent, err := f.sendBatch(events)
+		if err == nil || attempt >= f.maxRetries {
+			return unsent, err
+		}
+
+		select {
+		case <-time.After(delay):
+			delay *= 2
+		case <-ctx.Done():
+			return unsent, ctx.Err()
+		}
+		events = unsent
+	}
+}
+
+/*
+* sends events in one batch, dropping those the server rejects
+* for good. returns the ones worth another try and why they failed
+**/
+func (f *Forwarder) sendBatch(events []model.Event) ([]model.Event, error) {
+	if len(events) == 0 {
+		return nil, nil
+	}
+
+	errs, err := f.sender.SendBatch(events)
+
+	var unsent []model.Event
+	var unsentErr error
+	for i, e := range events {
+		eventErr := err
+		if errs != nil {
+			eventErr = errs[i]
+		}
+
+		switch {
+		case eventErr == nil:
+		case !client.IsRetryable(eventErr):
+			f.reject(e, eventErr)
+		default:
+			unsent = append(unsent, e)
+			unsentErr = eventErr
+		}
+	}
+	return unsent, unsentErr
+}
+
+func (f *Forwarder) reject(e model.Event, err error) {
+	f.rejected.Add(1)
+	log.Printf("agent: server rejected %q from %s, dropping it: %v", e.Name, e.Service, err)
+}
@@ -0,0 +1,115 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+/*
+* position in a tailed file.
+* Fingerprint is a hash of the first line so a rotated file
+* reusing the same path is not resumed at the old offset
+**/
+type FileOffset struct {
+	Offset      int64  `json:"offset"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+type Offsets struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]FileOffset
+}
+
+func LoadOffsets(path string) (*Offsets, error) {
+	o := &Offsets{
+		path:    path,
+		entries: map[string]FileOffset{},
+	}
+
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return o, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read offsets: %w", err)
+	}
+
+	if err := json.Unmarshal(raw, &o.entries); err != nil {
+		return nil, fmt.Errorf("failed to parse offsets: %w", err)
+	}
+
+	return o, nil
+}
+
+func (o *Offsets) Get(file string) (FileOffset, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	off, ok := o.entries[file]
+	return off, ok
+}
+
+func (o *Offsets) Set(file string, off FileOffset) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.entries[file] = off
+}
+
+/*
+* first saved offset match accepts, used to pick a
+* file back up after rotation moved it to a new path
+**/
+func (o *Offsets) Find(match func(file string, off FileOffset) bool) (string, FileOffset, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for file, off := range o.entries {
+		if match(file, off) {
+			return file, off, true
+		}
+	}
+	return "", FileOffset{}, false
+}
+
+func (o *Offsets) Delete(file string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	delete(o.entries, file)
+}
+
+/*
+* written to a temp file and renamed so a crash mid write
+* never leaves a corrupt state file behind
+**/
+func (o *Offsets) Save() error {
+	o.mu.Lock()
+	raw, err := json.Marshal(o.entries)
+	o.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal offsets: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(o.path), ".offsets-*")
+	if err != nil {
+		return fmt.Errorf("failed to create offsets file: %w", err)
+	}
+
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write offsets: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write offsets: %w", err)
+	}
+
+	return os.Rename(tmp.Name(), o.path)
+}
@@ -0,0 +1,190 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/xonoxc/scopion/internal/model"
+)
+
+var ErrNoMatch = errors.New("line does not match parser")
+
+/*
+* Parser turns one log line into a set of fields.
+* well known keys are lifted onto the event by toEvent,
+* everything else ends up in Data
+**/
+type Parser interface {
+	Parse(line string) (map[string]any, error)
+}
+
+func NewParser(cfg ParserConfig) (Parser, error) {
+	switch cfg.Type {
+	case PARSER_JSON:
+		return JSONParser{}, nil
+	case PARSER_LOGFMT:
+		return LogfmtParser{}, nil
+	case PARSER_REGEX:
+		return NewRegexParser(cfg.Pattern)
+	default:
+		return nil, fmt.Errorf("unknown parser type %q", cfg.Type)
+	}
+}
+
+type JSONParser struct{}
+
+func (JSONParser) Parse(line string) (map[string]any, error) {
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		return nil, ErrNoMatch
+	}
+	return fields, nil
+}
+
+/*
+* key=value pairs, values may be double quoted.
+* a bare key is recorded as true
+**/
+type LogfmtParser struct{}
+
+func (LogfmtParser) Parse(line string) (map[string]any, error) {
+	fields := map[string]any{}
+
+	i := 0
+	for i < len(line) {
+		for i < len(line) && line[i] == ' ' {
+			i++
+		}
+		if i >= len(line) {
+			break
+		}
+
+		start := i
+		for i < len(line) && line[i] != '=' && line[i] != ' ' {
+			i++
+		}
+		key := line[start:i]
+
+		if i >= len(line) || line[i] == ' ' {
+			fields[key] = true
+			continue
+		}
+
+		// skip '='
+		i++
+
+		if i < len(line) && line[i] == '"' {
+			var sb strings.Builder
+			i++
+			for i < len(line) && line[i] != '"' {
+				if line[i] == '\\' && i+1 < len(line) {
+					i++
+				}
+				sb.WriteByte(line[i])
+				i++
+			}
+			// skip closing quote
+			i++
+			fields[key] = sb.String()
+			continue
+		}
+
+		start = i
+		for i < len(line) && line[i] != ' ' {
+			i++
+		}
+		fields[key] = line[start:i]
+	}
+
+	if len(fields) == 0 {
+		return nil, ErrNoMatch
+	}
+
+	return fields, nil
+}
+
+/*
+* named groups become fields, so (?P<level>\w+) maps to the
+* event level, (?P<trace_id>\S+) to the trace and so on
+**/
+type RegexParser struct {
+	re *regexp.Regexp
+}
+
+func NewRegexParser(pattern string) (*RegexParser, error) {
+	if pattern == "" {
+		return nil, errors.New("regex parser requires a pattern")
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex pattern: %w", err)
+	}
+
+	return &RegexParser{re: re}, nil
+}
+
+func (p *RegexParser) Parse(line string) (map[string]any, error) {
+	match := p.re.FindStringSubmatch(line)
+	if match == nil {
+		return nil, ErrNoMatch
+	}
+
+	fields := map[string]any{}
+	for i, name := range p.re.SubexpNames() {
+		if name == "" || match[i] == "" {
+			continue
+		}
+		fields[name] = match[i]
+	}
+
+	return fields, nil
+}
+
+/*
+* maps parsed fields onto an event. lines a parser can't
+* handle are still shipped, with the raw line as the name
+**/
+func toEvent(fields map[string]any, line, service string) model.Event {
+	e := model.Event{
+		Level:   "info",
+		Service: service,
+		Name:    line,
+	}
+
+	if fields == nil {
+		return e
+	}
+
+	if v := takeString(fields, "level", "severity", "lvl"); v != "" {
+		e.Level = strings.ToLower(v)
+	}
+	if v := takeString(fields, "service"); v != "" {
+		e.Service = v
+	}
+	if v := takeString(fields, "name", "msg", "message"); v != "" {
+		e.Name = v
+	}
+	if v := takeString(fields, "trace_id", "traceId", "trace"); v != "" {
+		e.TraceID = v
+	}
+
+	if len(fields) > 0 {
+		e.Data = fields
+	}
+
+	return e
+}
+
+func takeString(fields map[string]any, keys ...string) string {
+	for _, k := range keys {
+		if v, ok := fields[k].(string); ok && v != "" {
+			delete(fields, k)
+			return v
+		}
+	}
+	return ""
+}
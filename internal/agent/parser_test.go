@@ -0,0 +1,78 @@
+package agent
+
+import "testing"
+
+func TestJSONParser(t *testing.T) {
+	fields, err := JSONParser{}.Parse(`{"level":"ERROR","msg":"db down","trace_id":"t1","attempt":2}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := toEvent(fields, "raw", "api")
+	if e.Level != "error" {
+		t.Errorf("Expected level error, got %s", e.Level)
+	}
+	if e.Name != "db down" {
+		t.Errorf("Expected name 'db down', got %s", e.Name)
+	}
+	if e.TraceID != "t1" {
+		t.Errorf("Expected trace t1, got %s", e.TraceID)
+	}
+	if e.Service != "api" {
+		t.Errorf("Expected fallback service api, got %s", e.Service)
+	}
+	if e.Data["attempt"] != 2.0 {
+		t.Errorf("Expected attempt in data, got %v", e.Data)
+	}
+	if _, exists := e.Data["msg"]; exists {
+		t.Error("Expected msg to be lifted out of data")
+	}
+}
+
+func TestLogfmtParser(t *testing.T) {
+	fields, err := LogfmtParser{}.Parse(`level=warn service=worker msg="slow job" duration=1.5s retry`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := toEvent(fields, "raw", "default")
+	if e.Level != "warn" || e.Service != "worker" || e.Name != "slow job" {
+		t.Errorf("Unexpected event %+v", e)
+	}
+	if e.Data["duration"] != "1.5s" {
+		t.Errorf("Expected duration 1.5s, got %v", e.Data["duration"])
+	}
+	if e.Data["retry"] != true {
+		t.Errorf("Expected bare key to be true, got %v", e.Data["retry"])
+	}
+}
+
+func TestRegexParser(t *testing.T) {
+	p, err := NewRegexParser(`^\[(?P<level>\w+)\] (?P<service>\w+) (?P<trace_id>\S+) (?P<name>.+)$`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields, err := p.Parse("[INFO] billing abc123 invoice sent")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := toEvent(fields, "raw", "default")
+	if e.Level != "info" || e.Service != "billing" || e.TraceID != "abc123" || e.Name != "invoice sent" {
+		t.Errorf("Unexpected event %+v", e)
+	}
+
+	if _, err := p.Parse("no match here"); err != ErrNoMatch {
+		t.Errorf("Expected ErrNoMatch, got %v", err)
+	}
+}
+
+func TestNewParserRejectsUnknownType(t *testing.T) {
+	if _, err := NewParser(ParserConfig{Type: "xml"}); err == nil {
+		t.Error("Expected error for unknown parser type")
+	}
+	if _, err := NewParser(ParserConfig{Type: PARSER_REGEX}); err == nil {
+		t.Error("Expected error for regex parser without pattern")
+	}
+}
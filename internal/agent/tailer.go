@@ -0,0 +1,335 @@
+package agent
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/xonoxc/scopion/internal/model"
+)
+
+const (
+	/*
+	* caps how much a single poll reads from one file so a large
+	* backlog is shipped in several rounds instead of all in memory
+	**/
+	maxLinesPerPoll = 10000
+
+	fingerprintSize = 1024
+)
+
+type tailedFile struct {
+	path        string
+	file        *os.File
+	info        os.FileInfo
+	offset      int64
+	fingerprint string
+}
+
+/*
+* Tailer follows every file matched by one FileConfig.
+* it is poll based: each Poll re-evaluates the globs, notices
+* rotation (path now points at a different file) and truncation
+* (file shrank below our offset), and returns the new complete lines
+**/
+type Tailer struct {
+	cfg     FileConfig
+	parser  Parser
+	offsets *Offsets
+	files   map[string]*tailedFile
+}
+
+func NewTailer(cfg FileConfig, offsets *Offsets) (*Tailer, error) {
+	parser, err := NewParser(cfg.Parser)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tailer{
+		cfg:     cfg,
+		parser:  parser,
+		offsets: offsets,
+		files:   map[string]*tailedFile{},
+	}, nil
+}
+
+func (t *Tailer) Poll() ([]model.Event, error) {
+	for _, pattern := range t.cfg.Paths {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %w", pattern, err)
+		}
+
+		for _, path := range matches {
+			if _, tracked := t.files[path]; tracked {
+				continue
+			}
+			if moved, err := t.follow(path); moved || err != nil {
+				if err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if err := t.open(path); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var events []model.Event
+	for path, tf := range t.files {
+		fileEvents, err := t.poll(tf)
+		if err != nil {
+			return events, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		events = append(events, fileEvents...)
+	}
+
+	return events, nil
+}
+
+func (t *Tailer) Close() {
+	for _, tf := range t.files {
+		tf.file.Close()
+	}
+	t.files = map[string]*tailedFile{}
+}
+
+func (t *Tailer) open(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	if info.IsDir() {
+		f.Close()
+		return nil
+	}
+
+	tf := &tailedFile{
+		path:        path,
+		file:        f,
+		info:        info,
+		fingerprint: fingerprint(f),
+	}
+
+	if saved, ok := t.offsets.Get(path); ok {
+		sameFile := saved.Fingerprint == "" || saved.Fingerprint == tf.fingerprint
+		if sameFile && saved.Offset <= info.Size() {
+			tf.offset = saved.Offset
+		}
+	} else if saved, ok := t.rotatedFrom(tf); ok {
+		tf.offset = saved.Offset
+	}
+
+	t.files[path] = tf
+	return nil
+}
+
+/*
+* a file renamed by rotation (app.log -> app.log.1) to a path the
+* globs also match keeps its handle and offset under the new path
+* instead of being read again from the start
+**/
+func (t *Tailer) follow(path string) (bool, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+
+	for old, tf := range t.files {
+		if !os.SameFile(info, tf.info) {
+			continue
+		}
+		if current, err := os.Stat(old); err == nil && os.SameFile(current, tf.info) {
+			continue
+		}
+
+		delete(t.files, old)
+		t.offsets.Delete(old)
+
+		tf.path = path
+		t.files[path] = tf
+		t.offsets.Set(path, FileOffset{Offset: tf.offset, Fingerprint: tf.fingerprint})
+
+		return true, t.open(old)
+	}
+
+	return false, nil
+}
+
+/*
+* a path with no offset of its own may be a file that was rotated
+* while the agent was down, found by the fingerprint saved under its
+* old path. a tracked file still starting with the same line means
+* the two only share a first line and the offset isn't theirs
+**/
+func (t *Tailer) rotatedFrom(tf *tailedFile) (FileOffset, bool) {
+	if tf.fingerprint == "" {
+		return FileOffset{}, false
+	}
+
+	_, saved, ok := t.offsets.Find(func(file string, off FileOffset) bool {
+		if off.Fingerprint != tf.fingerprint || off.Offset > tf.info.Size() {
+			return false
+		}
+		if other, tracked := t.files[file]; tracked && fingerprint(other.file) == tf.fingerprint {
+			return false
+		}
+		return true
+	})
+	return saved, ok
+}
+
+func (t *Tailer) poll(tf *tailedFile) ([]model.Event, error) {
+	current, statErr := os.Stat(tf.path)
+	removed := errors.Is(statErr, os.ErrNotExist)
+	if statErr != nil && !removed {
+		return nil, statErr
+	}
+	rotated := statErr == nil && !os.SameFile(current, tf.info)
+
+	if !rotated && !removed && truncated(tf, current) {
+		tf.offset = 0
+		tf.fingerprint = ""
+	}
+
+	/*
+	* whatever was appended to the old file before it was
+	* rotated or removed is still read from the open handle
+	**/
+	events, err := t.readLines(tf)
+	if err != nil {
+		return events, err
+	}
+
+	switch {
+	case removed:
+		tf.file.Close()
+		delete(t.files, tf.path)
+		t.offsets.Delete(tf.path)
+		return events, nil
+
+	case rotated:
+		tf.file.Close()
+		delete(t.files, tf.path)
+		t.offsets.Delete(tf.path)
+
+		if err := t.open(tf.path); err != nil {
+			return events, err
+		}
+		if next, ok := t.files[tf.path]; ok {
+			more, err := t.readLines(next)
+			events = append(events, more...)
+			if err != nil {
+				return events, err
+			}
+		}
+		return events, nil
+	}
+
+	return events, nil
+}
+
+/*
+* only complete lines are consumed, a trailing partial line
+* is left in place and picked up on a later poll
+**/
+func (t *Tailer) readLines(tf *tailedFile) ([]model.Event, error) {
+	if _, err := tf.file.Seek(tf.offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var events []model.Event
+	reader := bufio.NewReader(tf.file)
+
+	for range maxLinesPerPoll {
+		text, err := reader.ReadString('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return events, err
+		}
+
+		tf.offset += int64(len(text))
+
+		text = strings.TrimRight(text, "\r\n")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		events = append(events, t.lineEvent(tf.path, text))
+	}
+
+	if tf.fingerprint == "" {
+		tf.fingerprint = fingerprint(tf.file)
+	}
+	t.offsets.Set(tf.path, FileOffset{Offset: tf.offset, Fingerprint: tf.fingerprint})
+
+	return events, nil
+}
+
+func (t *Tailer) lineEvent(path, line string) model.Event {
+	fields, err := t.parser.Parse(line)
+	if err != nil {
+		fields = nil
+	}
+
+	e := toEvent(fields, line, t.cfg.Service)
+	e.Timestamp = time.Now()
+	if e.Data == nil {
+		e.Data = map[string]any{}
+	}
+	e.Data["log_file"] = path
+
+	return e
+}
+
+/*
+* a copytruncate rotation can refill the file past our offset
+* before we poll again, so a changed first line counts as well
+**/
+func truncated(tf *tailedFile, current os.FileInfo) bool {
+	if current.Size() < tf.offset {
+		return true
+	}
+	return tf.fingerprint != "" && fingerprint(tf.file) != tf.fingerprint
+}
+
+/*
+* hash of the first line, or of the first fingerprintSize bytes
+* when the line is longer. empty until a first line is complete
+**/
+func fingerprint(f *os.File) string {
+	buf := make([]byte, fingerprintSize)
+	n, _ := f.ReadAt(buf, 0)
+	buf = buf[:n]
+
+	if idx := strings.IndexByte(string(buf), '\n'); idx >= 0 {
+		buf = buf[:idx]
+	} else if n < fingerprintSize {
+		return ""
+	}
+
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,384 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	client "github.com/xonoxc/scopion/clients/go"
+	"github.com/xonoxc/scopion/internal/model"
+)
+
+func appendLines(t *testing.T, path string, lines ...string) {
+	t.Helper()
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for _, l := range lines {
+		if _, err := f.WriteString(l); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func names(events []model.Event) []string {
+	out := make([]string, len(events))
+	for i, e := range events {
+		out[i] = e.Name
+	}
+	return out
+}
+
+func newTestTailer(t *testing.T, dir string, offsets *Offsets) *Tailer {
+	t.Helper()
+
+	tailer, err := NewTailer(FileConfig{
+		Paths:   []string{filepath.Join(dir, "*.log")},
+		Service: "app",
+		Parser:  ParserConfig{Type: PARSER_JSON},
+	}, offsets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tailer
+}
+
+func TestTailerFollowsAppendsAndPartialLines(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	appendLines(t, path, `{"msg":"one"}`+"\n", `{"msg":"tw`)
+
+	offsets, _ := LoadOffsets(filepath.Join(dir, "state"))
+	tailer := newTestTailer(t, dir, offsets)
+	defer tailer.Close()
+
+	events, err := tailer.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(events); len(got) != 1 || got[0] != "one" {
+		t.Fatalf("Expected [one], got %v", got)
+	}
+
+	appendLines(t, path, `o"}`+"\n")
+
+	events, err = tailer.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(events); len(got) != 1 || got[0] != "two" {
+		t.Fatalf("Expected [two], got %v", got)
+	}
+}
+
+func TestTailerHandlesRotationAndTruncation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	appendLines(t, path, "first\n")
+
+	offsets, _ := LoadOffsets(filepath.Join(dir, "state"))
+	tailer := newTestTailer(t, dir, offsets)
+	defer tailer.Close()
+
+	if _, err := tailer.Poll(); err != nil {
+		t.Fatal(err)
+	}
+
+	// rotate: move the file away and start a fresh one
+	appendLines(t, path, "last before rotate\n")
+	if err := os.Rename(path, filepath.Join(dir, "app.log.1")); err != nil {
+		t.Fatal(err)
+	}
+	appendLines(t, path, "after rotate\n")
+
+	events, err := tailer.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := names(events)
+	if len(got) != 2 || got[0] != "last before rotate" || got[1] != "after rotate" {
+		t.Fatalf("Expected old tail then new file, got %v", got)
+	}
+
+	// truncate in place
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	appendLines(t, path, "x\n")
+
+	events, err = tailer.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(events); len(got) != 1 || got[0] != "x" {
+		t.Fatalf("Expected [x] after truncation, got %v", got)
+	}
+}
+
+func newRotatedTailer(t *testing.T, dir string, offsets *Offsets) *Tailer {
+	t.Helper()
+
+	tailer, err := NewTailer(FileConfig{
+		Paths:   []string{filepath.Join(dir, "app.log*")},
+		Service: "app",
+		Parser:  ParserConfig{Type: PARSER_JSON},
+	}, offsets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tailer
+}
+
+func TestTailerFollowsFileRotatedOntoMatchedPath(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	appendLines(t, path, "one\n", "two\n")
+
+	offsets, _ := LoadOffsets(filepath.Join(dir, "state"))
+	tailer := newRotatedTailer(t, dir, offsets)
+	defer tailer.Close()
+
+	if _, err := tailer.Poll(); err != nil {
+		t.Fatal(err)
+	}
+
+	appendLines(t, path, "last before rotate\n")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendLines(t, path, "after rotate\n")
+
+	events, err := tailer.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := names(events)
+	slices.Sort(got)
+	if !slices.Equal(got, []string{"after rotate", "last before rotate"}) {
+		t.Fatalf("Expected only the new lines once, got %v", got)
+	}
+
+	if _, ok := offsets.Get(path + ".1"); !ok {
+		t.Error("Expected the offset to move to the rotated path")
+	}
+}
+
+func TestTailerResumesFileRotatedWhileStopped(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	statePath := filepath.Join(dir, "state")
+	appendLines(t, path, "one\n", "two\n")
+
+	offsets, _ := LoadOffsets(statePath)
+	tailer := newRotatedTailer(t, dir, offsets)
+	if _, err := tailer.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	tailer.Close()
+	if err := offsets.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	appendLines(t, path, "three\n")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendLines(t, path, "fresh\n")
+
+	reloaded, err := LoadOffsets(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tailer = newRotatedTailer(t, dir, reloaded)
+	defer tailer.Close()
+
+	events, err := tailer.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := names(events)
+	slices.Sort(got)
+	if !slices.Equal(got, []string{"fresh", "three"}) {
+		t.Fatalf("Expected [fresh three] after restart, got %v", got)
+	}
+}
+
+func TestTailerResumesFromSavedOffsets(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	statePath := filepath.Join(dir, "state")
+	appendLines(t, path, "one\n", "two\n")
+
+	offsets, _ := LoadOffsets(statePath)
+	tailer := newTestTailer(t, dir, offsets)
+	if _, err := tailer.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	tailer.Close()
+	if err := offsets.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	appendLines(t, path, "three\n")
+
+	reloaded, err := LoadOffsets(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tailer = newTestTailer(t, dir, reloaded)
+	defer tailer.Close()
+
+	events, err := tailer.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(events); len(got) != 1 || got[0] != "three" {
+		t.Fatalf("Expected only [three] after restart, got %v", got)
+	}
+}
+
+type flakySender struct {
+	down     bool
+	sent     []model.Event
+	requests int
+
+	/*
+	* events with this name are refused with a 400
+	**/
+	reject string
+
+	/*
+	* events with this name are answered 429 this many times
+	**/
+	throttle      string
+	throttleTimes int
+}
+
+func (s *flakySender) SendBatch(events []model.Event) ([]error, error) {
+	s.requests++
+	if s.down {
+		return nil, errors.New("connection refused")
+	}
+
+	var errs []error
+	for i, e := range events {
+		if s.reject != "" && e.Name == s.reject {
+			if errs == nil {
+				errs = make([]error, len(events))
+			}
+			errs[i] = &client.APIError{StatusCode: 400, Message: "invalid event"}
+			continue
+		}
+		if s.throttle != "" && e.Name == s.throttle && s.throttleTimes > 0 {
+			s.throttleTimes--
+			if errs == nil {
+				errs = make([]error, len(events))
+			}
+			errs[i] = &client.APIError{StatusCode: 429, Message: "rate limit exceeded"}
+			continue
+		}
+		s.sent = append(s.sent, e)
+	}
+	return errs, nil
+}
+
+func TestForwarderBuffersWhileServerDown(t *testing.T) {
+	dir := t.TempDir()
+	sender := &flakySender{down: true}
+	buffer := NewDiskBuffer(filepath.Join(dir, "buffer"), 1024*1024)
+	forwarder := NewForwarder(sender, buffer, 100, 1)
+
+	err := forwarder.Forward(context.Background(), []model.Event{{Name: "a"}, {Name: "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buffer.Size() == 0 {
+		t.Fatal("Expected events to be spooled to disk")
+	}
+
+	sender.down = false
+	if err := forwarder.Forward(context.Background(), []model.Event{{Name: "c"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := names(sender.sent); !slices.Equal(got, []string{"a", "b", "c"}) {
+		t.Fatalf("Expected buffered events replayed in order, got %v", got)
+	}
+	if sender.requests != 3 {
+		t.Errorf("Expected a request per batch, got %d", sender.requests)
+	}
+	if buffer.Size() != 0 {
+		t.Error("Expected buffer to be empty after replay")
+	}
+}
+
+func TestForwarderDropsRejectedEvents(t *testing.T) {
+	dir := t.TempDir()
+	sender := &flakySender{reject: "bad"}
+	buffer := NewDiskBuffer(filepath.Join(dir, "buffer"), 1024*1024)
+	forwarder := NewForwarder(sender, buffer, 100, 3)
+
+	err := forwarder.Forward(context.Background(), []model.Event{{Name: "a"}, {Name: "bad"}, {Name: "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sender.requests != 1 {
+		t.Errorf("Expected a rejected event not to be retried, got %d requests", sender.requests)
+	}
+	if got := names(sender.sent); !slices.Equal(got, []string{"a", "b"}) {
+		t.Fatalf("Expected the rest of the batch to be sent, got %v", got)
+	}
+	if buffer.Size() != 0 {
+		t.Error("Expected a rejected event not to be spooled")
+	}
+
+	/*
+	* a rejected event already in the spool must not hold up
+	* the ones behind it
+	**/
+	sender.down = true
+	if err := forwarder.Forward(context.Background(), []model.Event{{Name: "bad"}, {Name: "c"}}); err != nil {
+		t.Fatal(err)
+	}
+	sender.down = false
+	if err := forwarder.Forward(context.Background(), []model.Event{{Name: "d"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := names(sender.sent); !slices.Equal(got, []string{"a", "b", "c", "d"}) {
+		t.Fatalf("Expected spooled events past the rejected one to be replayed, got %v", got)
+	}
+	if buffer.Size() != 0 {
+		t.Error("Expected buffer to be empty after replay")
+	}
+	if forwarder.Rejected() != 2 {
+		t.Errorf("Expected 2 rejected events, got %d", forwarder.Rejected())
+	}
+}
+
+func TestForwarderRetriesOnlyThrottledEvents(t *testing.T) {
+	sender := &flakySender{throttle: "busy", throttleTimes: 1}
+	buffer := NewDiskBuffer(filepath.Join(t.TempDir(), "buffer"), 1024*1024)
+	forwarder := NewForwarder(sender, buffer, 100, 3)
+	forwarder.backoff = time.Millisecond
+
+	err := forwarder.Forward(context.Background(), []model.Event{{Name: "a"}, {Name: "busy"}, {Name: "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := names(sender.sent); !slices.Equal(got, []string{"a", "b", "busy"}) {
+		t.Fatalf("Expected the throttled event to be sent again on its own, got %v", got)
+	}
+	if sender.requests != 2 || buffer.Size() != 0 || forwarder.Rejected() != 0 {
+		t.Errorf("Expected 2 requests and nothing spooled or rejected, got %d requests", sender.requests)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"strconv"
 
 	"github.com/xonoxc/scopion/internal/api/httpx"
+	"github.com/xonoxc/scopion/internal/api/middleware"
 	"github.com/xonoxc/scopion/internal/app/appcontext"
 	"github.com/xonoxc/scopion/internal/model"
 )
@@ -23,7 +24,7 @@ func StatsHandler(as *appcontext.AtomicAppState) http.HandlerFunc {
 
 		s := as.Snapshot().Store
 
-		stats, err := s.GetStats()
+		stats, err := s.GetStats(middleware.ProjectFromRequest(r))
 		if err != nil {
 			http.Error(w, "Failed to fetch stats", http.StatusInternalServerError)
 			return
@@ -49,7 +50,7 @@ func ErrorsByServiceHandler(as *appcontext.AtomicAppState) http.HandlerFunc {
 
 		s := as.Snapshot().Store
 
-		errors, err := s.GetErrorsByService(hours)
+		errors, err := s.GetErrorsByService(middleware.ProjectFromRequest(r), hours)
 		if err != nil {
 			http.Error(w, "Failed to fetch errors by service", http.StatusInternalServerError)
 			return
@@ -67,7 +68,7 @@ func ServicesHandler(as *appcontext.AtomicAppState) http.HandlerFunc {
 
 		s := as.Snapshot().Store
 
-		services, err := s.GetServices()
+		services, err := s.GetServices(middleware.ProjectFromRequest(r))
 		if err != nil {
 			http.Error(w, "Failed to fetch services", http.StatusInternalServerError)
 			return
@@ -93,7 +94,7 @@ func TracesHandler(as *appcontext.AtomicAppState) http.HandlerFunc {
 
 		s := as.Snapshot().Store
 
-		traces, err := s.GetTraces(limit)
+		traces, err := s.GetTraces(middleware.ProjectFromRequest(r), limit)
 		if err != nil {
 			http.Error(w, "Failed to fetch traces", http.StatusInternalServerError)
 			return
@@ -117,7 +118,7 @@ func SearchHandler(as *appcontext.AtomicAppState) http.HandlerFunc {
 
 		s := as.Snapshot().Store
 
-		events, err := s.SearchEvents(query, 50)
+		events, err := s.SearchEvents(middleware.ProjectFromRequest(r), query, 50)
 		if err != nil {
 			http.Error(w, "Failed to search events", http.StatusInternalServerError)
 			return
@@ -158,7 +159,7 @@ func ThroughputHandler(as *appcontext.AtomicAppState) http.HandlerFunc {
 
 		s := as.Snapshot().Store
 
-		throughput, err := s.GetThroughput(hours)
+		throughput, err := s.GetThroughput(middleware.ProjectFromRequest(r), hours)
 		if err != nil {
 			http.Error(w, "Failed to fetch throughput", http.StatusInternalServerError)
 			return
@@ -184,7 +185,7 @@ func EventsHandler(as *appcontext.AtomicAppState) http.HandlerFunc {
 
 		s := as.Snapshot().Store
 
-		events, err := s.Recent(limit)
+		events, err := s.Recent(middleware.ProjectFromRequest(r), limit)
 		if err != nil {
 			http.Error(w, "Failed to fetch events", http.StatusInternalServerError)
 			return
@@ -208,7 +209,7 @@ func TraceEventsHandler(as *appcontext.AtomicAppState) http.HandlerFunc {
 
 		s := as.Snapshot().Store
 
-		events, err := s.GetEventsByTraceID(traceID)
+		events, err := s.GetEventsByTraceID(middleware.ProjectFromRequest(r), traceID)
 		if err != nil {
 			http.Error(w, "Failed to fetch trace events", http.StatusInternalServerError)
 			return
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/xonoxc/scopion/internal/auth"
+	"github.com/xonoxc/scopion/internal/model"
+)
+
+type apiKeyContextKey struct{}
+
+/*
+* finds a key by the hash of its plain value
+**/
+type KeyLookup func(hash string) (*model.APIKey, error)
+
+/*
+* scope a request needs, false when it is public
+**/
+type ScopeFunc func(r *http.Request) (model.APIKeyScope, bool)
+
+/*
+* AuthMiddleware rejects requests without a valid key holding the
+* scope the request needs. the key is read from
+* "Authorization: Bearer <key>", the X-API-Key header or the
+* api_key query parameter (for EventSource and WebSocket clients)
+**/
+func AuthMiddleware(lookup KeyLookup, requiredScope ScopeFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scope, required := requiredScope(r)
+			if !required {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			plain := keyFromRequest(r)
+			if plain == "" {
+				unauthorized(w, "missing api key")
+				return
+			}
+
+			key, err := lookup(auth.HashKey(plain))
+			if errors.Is(err, model.ErrAPIKeyNotFound) {
+				unauthorized(w, "invalid api key")
+				return
+			}
+			if err != nil {
+				http.Error(w, "failed to verify api key", http.StatusInternalServerError)
+				return
+			}
+			if key.Revoked() {
+				unauthorized(w, "api key has been revoked")
+				return
+			}
+			if !key.HasScope(scope) {
+				http.Error(w, "api key lacks the "+string(scope)+" scope", http.StatusForbidden)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), apiKeyContextKey{}, key)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+/*
+* the key that authenticated the request, if any
+**/
+func APIKeyFromContext(ctx context.Context) (*model.APIKey, bool) {
+	key, ok := ctx.Value(apiKeyContextKey{}).(*model.APIKey)
+	return key, ok
+}
+
+func keyFromRequest(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	return r.URL.Query().Get("api_key")
+}
+
+func unauthorized(w http.ResponseWriter, msg string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="scopion"`)
+	http.Error(w, msg, http.StatusUnauthorized)
+}
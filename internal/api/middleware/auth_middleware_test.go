@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/xonoxc/scopion/internal/auth"
+	"github.com/xonoxc/scopion/internal/model"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	ingestKey, ingestPlain, _ := auth.NewAPIKey("ingest", "shop", []model.APIKeyScope{model.SCOPE_INGEST})
+	revokedKey, revokedPlain, _ := auth.NewAPIKey("old", "shop", []model.APIKeyScope{model.SCOPE_INGEST})
+	now := time.Now()
+	revokedKey.RevokedAt = &now
+
+	keys := map[string]*model.APIKey{
+		ingestKey.Hash:  &ingestKey,
+		revokedKey.Hash: &revokedKey,
+	}
+	lookup := func(hash string) (*model.APIKey, error) {
+		if k, ok := keys[hash]; ok {
+			return k, nil
+		}
+		return nil, model.ErrAPIKeyNotFound
+	}
+	scopeFor := func(r *http.Request) (model.APIKeyScope, bool) {
+		if r.URL.Path == "/public" {
+			return "", false
+		}
+		if r.URL.Path == "/ingest" {
+			return model.SCOPE_INGEST, true
+		}
+		return model.SCOPE_READ, true
+	}
+
+	var project string
+	handler := AuthMiddleware(lookup, scopeFor)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key, ok := APIKeyFromContext(r.Context()); ok {
+			project = key.Project
+		}
+	}))
+
+	tests := []struct {
+		name   string
+		target string
+		header string
+		want   int
+	}{
+		{"public", "/public", "", http.StatusOK},
+		{"missing", "/ingest", "", http.StatusUnauthorized},
+		{"unknown", "/ingest", "Bearer sk_nope", http.StatusUnauthorized},
+		{"revoked", "/ingest", "Bearer " + revokedPlain, http.StatusUnauthorized},
+		{"wrong scope", "/api/events", "Bearer " + ingestPlain, http.StatusForbidden},
+		{"bearer", "/ingest", "Bearer " + ingestPlain, http.StatusOK},
+		{"query", "/ingest?api_key=" + ingestPlain, "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", tt.target, nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.want, w.Code)
+		}
+	}
+
+	if project != "shop" {
+		t.Errorf("Expected the key in the request context, got project %q", project)
+	}
+}
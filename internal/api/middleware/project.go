@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/xonoxc/scopion/internal/model"
+)
+
+const PROJECT_HEADER = "X-Scopion-Project"
+
+/*
+* ProjectFromRequest picks the project a request reads or writes.
+* an authenticated key always decides, otherwise the project header
+* or the project query parameter is used, falling back to the default
+**/
+func ProjectFromRequest(r *http.Request) string {
+	if key, ok := APIKeyFromContext(r.Context()); ok {
+		return key.Project
+	}
+	if project := strings.TrimSpace(r.Header.Get(PROJECT_HEADER)); project != "" {
+		return project
+	}
+	if project := strings.TrimSpace(r.URL.Query().Get("project")); project != "" {
+		return project
+	}
+	return model.DEFAULT_PROJECT
+}
@@ -0,0 +1,112 @@
+package app
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/xonoxc/scopion/internal/app/appcontext"
+	"github.com/xonoxc/scopion/internal/ingest"
+	"github.com/xonoxc/scopion/internal/live"
+	"github.com/xonoxc/scopion/internal/model"
+	"github.com/xonoxc/scopion/internal/processor"
+
+	appstorage "github.com/xonoxc/scopion/internal/store"
+)
+
+/*
+* API is everything behind the http routes: the app state, the live
+* broadcaster and the ingest queue and pipeline. the server starts one
+* on its store, benchmarks start one to drive the full ingest path
+**/
+type API struct {
+	State       *appcontext.AtomicAppState
+	Broadcaster *live.Broadcaster
+	Queue       *ingest.Queue
+	Processors  processor.Chain
+	Limiter     *ingest.Limiter
+
+	pipeline ingest.Pipeline
+	config   ServerConfig
+}
+
+/*
+* NewAPI opens the ingest queue on store, the caller closes
+* the API before closing the store
+**/
+func NewAPI(store appstorage.Storage, config ServerConfig) (*API, error) {
+	as := appcontext.NewAtomicAppState(store, appstorage.DUAL_WRITE)
+
+	broadcaster := live.New()
+
+	queue, err := ingest.OpenQueue(config.Ingest, func() appstorage.Storage {
+		return as.Snapshot().Store
+	}, broadcaster)
+	if err != nil {
+		return nil, err
+	}
+
+	processors, err := processor.New(config.Processors)
+	if err != nil {
+		queue.Close()
+		return nil, err
+	}
+
+	limiter := ingest.NewLimiter(config.Limits, func(e model.Event) {
+		if err := queue.Enqueue(e); err != nil {
+			log.Printf("ingest: failed to queue throttle warning: %v", err)
+		}
+	})
+
+	return &API{
+		State:       as,
+		Broadcaster: broadcaster,
+		Queue:       queue,
+		Processors:  processors,
+		Limiter:     limiter,
+		pipeline: ingest.Pipeline{
+			Validation: config.Validation,
+			Processors: processors,
+			Limiter:    limiter,
+		},
+		config: config,
+	}, nil
+}
+
+/*
+* IngestSyslog takes a syslog message through the same steps as
+* /ingest. one breaking a validation rule is logged and dropped
+**/
+func (a *API) IngestSyslog(e model.Event) {
+	src := processor.Source{ReceivedAt: time.Now(), ClientTime: e.Timestamp}
+
+	if violations := a.pipeline.Validation.Normalize(&e, src.ReceivedAt); len(violations) > 0 {
+		log.Printf("syslog: dropping invalid event from %q: %v", e.Service, violations)
+		return
+	}
+	e.Project = model.ProjectOrDefault(e.Project)
+
+	a.Processors.Process(&e, src)
+
+	if !a.Limiter.Allow(e.Project, e.Service, nil).Allowed {
+		return
+	}
+	if err := a.Queue.Enqueue(e); err != nil {
+		a.Limiter.Refund(e.Project, e.Service, nil)
+		log.Printf("syslog: failed to queue event: %v", err)
+	}
+}
+
+/*
+* Register adds the api and ingest routes to mux
+**/
+func (a *API) Register(mux *http.ServeMux) {
+	NewAppRouter(a.State, a.Broadcaster, a.Queue, a.pipeline, a.config).Setup(mux)
+}
+
+/*
+* Close writes out what is queued, see ingest.Queue.Close
+**/
+func (a *API) Close() error {
+	return a.Queue.Close()
+}
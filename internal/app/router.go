@@ -8,18 +8,23 @@ import (
 	"github.com/xonoxc/scopion/internal/app/appcontext"
 	"github.com/xonoxc/scopion/internal/ingest"
 	"github.com/xonoxc/scopion/internal/live"
+	"github.com/xonoxc/scopion/internal/model"
 )
 
 type AppRouter struct {
 	appState    *appcontext.AtomicAppState
 	broadcaster *live.Broadcaster
+	queue       *ingest.Queue
+	pipeline    ingest.Pipeline
 	config      ServerConfig
 }
 
-func NewAppRouter(appState *appcontext.AtomicAppState, broadcaster *live.Broadcaster, config ServerConfig) *AppRouter {
+func NewAppRouter(appState *appcontext.AtomicAppState, broadcaster *live.Broadcaster, queue *ingest.Queue, pipeline ingest.Pipeline, config ServerConfig) *AppRouter {
 	return &AppRouter{
 		appState:    appState,
 		broadcaster: broadcaster,
+		queue:       queue,
+		pipeline:    pipeline,
 		config:      config,
 	}
 }
@@ -34,14 +39,40 @@ type Route struct {
 * Global middlewares here
 ***/
 func (a *AppRouter) globalMiddleware() []func(http.Handler) http.Handler {
-	return []func(http.Handler) http.Handler{
+	mids := []func(http.Handler) http.Handler{
 		middleware.LoggingMiddleware,
 	}
+
+	if a.config.Auth {
+		mids = append(mids, middleware.AuthMiddleware(a.lookupAPIKey, requiredScope))
+	}
+
+	return mids
+}
+
+func (a *AppRouter) lookupAPIKey(hash string) (*model.APIKey, error) {
+	return a.appState.Snapshot().Store.GetAPIKeyByHash(hash)
+}
+
+/*
+* ingest needs an ingest key, every read api a read key.
+* the status endpoint stays open for the dashboard
+**/
+func requiredScope(r *http.Request) (model.APIKeyScope, bool) {
+	switch r.URL.Path {
+	case "/ingest", "/ingest/batch":
+		return model.SCOPE_INGEST, true
+	case "/api/status":
+		return "", false
+	default:
+		return model.SCOPE_READ, true
+	}
 }
 
 func (a *AppRouter) getRoutes() []Route {
 	return []Route{
 		{Path: "/api/live", Handler: live.SSE(a.broadcaster)},
+		{Path: "/api/ws", Handler: live.WebSocket(a.broadcaster)},
 		{Path: "/api/events", Handler: api.EventsHandler(a.appState)},
 		{Path: "/api/trace-events", Handler: api.TraceEventsHandler(a.appState)},
 		{Path: "/api/stats", Handler: api.StatsHandler(a.appState)},
@@ -51,11 +82,13 @@ func (a *AppRouter) getRoutes() []Route {
 		{Path: "/api/traces", Handler: api.TracesHandler(a.appState)},
 		{Path: "/api/search", Handler: api.SearchHandler(a.appState)},
 		{Path: "/api/status", Handler: api.StatusHandler(a.config.IsDemoMode())},
-		{Path: "/ingest", Handler: ingest.Handler(a.appState.Snapshot().Store, a.broadcaster)},
+		{Path: "/api/ingest/status", Handler: ingest.StatusHandler(a.queue, a.pipeline)},
+		{Path: "/ingest", Handler: ingest.Handler(a.queue, a.pipeline)},
+		{Path: "/ingest/batch", Handler: ingest.BatchHandler(a.queue, a.pipeline)},
 	}
 }
 
-func (a *AppRouter) Setup() {
+func (a *AppRouter) Setup(mux *http.ServeMux) {
 	routes := a.getRoutes()
 	globalsMids := a.globalMiddleware()
 
@@ -70,6 +103,6 @@ func (a *AppRouter) Setup() {
 			h = globalsMids[i](h)
 		}
 
-		http.Handle(r.Path, middleware.LoggingMiddleware(h))
+		mux.Handle(r.Path, middleware.LoggingMiddleware(h))
 	}
 }
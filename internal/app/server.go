@@ -14,13 +14,15 @@ import (
 	"github.com/pressly/goose/v3"
 
 	"github.com/xonoxc/scopion/internal/api/middleware"
-	"github.com/xonoxc/scopion/internal/app/appcontext"
 	"github.com/xonoxc/scopion/internal/demo"
-	"github.com/xonoxc/scopion/internal/live"
+	"github.com/xonoxc/scopion/internal/ingest"
+	"github.com/xonoxc/scopion/internal/processor"
+	"github.com/xonoxc/scopion/internal/store/migrations"
 	"github.com/xonoxc/scopion/internal/store/sqlite"
+	"github.com/xonoxc/scopion/internal/syslog"
 	"github.com/xonoxc/scopion/ui"
 
-	appstorage "github.com/xonoxc/scopion/internal/store"
+	migrateable "github.com/xonoxc/scopion/internal/store/migratable"
 )
 
 /*
@@ -40,9 +42,21 @@ const (
 * API server config
 * DEMO_MODE: enables demo mode with sample telemetry data
 * NORMAL_MODE: standard operation mode
+* Syslog: optional syslog listeners, disabled when both addresses are empty
+* Ingest: write-ahead queue events pass through on their way to the store
+* Auth: require api keys for ingest and the read apis
+* Limits: per service and per api key rate limits and daily quotas
+* Validation: bounds on ingested events and the accepted clock skew
+* Processors: enrichment and redaction applied to events before they are stored
  */
 type ServerConfig struct {
-	Mode ServerMode
+	Mode       ServerMode
+	Syslog     syslog.Config
+	Ingest     ingest.QueueConfig
+	Auth       bool
+	Limits     ingest.LimitConfig
+	Validation ingest.ValidationConfig
+	Processors processor.Config
 }
 
 func (s *ServerConfig) IsDemoMode() bool {
@@ -74,6 +88,9 @@ func StartServerWithConfig(ctx context.Context, port string, config ServerConfig
 	if err := goose.Up(db, "migrations"); err != nil {
 		return err
 	}
+	if err := migrations.New("./scopion.db").Migrate(migrateable.SQLITE, migrations.Idempotent()); err != nil {
+		return err
+	}
 
 	store, err := sqlite.New("./scopion.db")
 	if err != nil {
@@ -81,17 +98,28 @@ func StartServerWithConfig(ctx context.Context, port string, config ServerConfig
 	}
 	defer store.Close()
 
-	as := appcontext.NewAtomicAppState(store, appstorage.DUAL_WRITE)
-
-	broadcaster := live.New()
+	api, err := NewAPI(store, config)
+	if err != nil {
+		return err
+	}
+	defer api.Close()
 
 	if config.Mode == DEMO_MODE {
 		log.Println("Demo mode enabled - generating sample telemetry data")
-		demo.Start(store, broadcaster)
+		demo.Start(store, api.Broadcaster)
+	}
+
+	if config.Syslog.Enabled() {
+		receiver := syslog.NewServer(config.Syslog, api.IngestSyslog)
+		if err := receiver.Start(); err != nil {
+			return err
+		}
+		defer receiver.Close()
+
+		log.Printf("Syslog receiver listening (udp %q, tcp %q)", config.Syslog.UDPAddr, config.Syslog.TCPAddr)
 	}
 
-	router := NewAppRouter(as, broadcaster, config)
-	router.Setup()
+	api.Register(http.DefaultServeMux)
 
 	sub, err := fs.Sub(ui.FS, "dist")
 	if err != nil {
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/xonoxc/scopion/internal/model"
+)
+
+const (
+	keyPrefix = "sk_"
+
+	/*
+	* characters of the plain key kept to recognise it
+	**/
+	displayLength = len(keyPrefix) + 8
+)
+
+/*
+* NewAPIKey generates a key for the project. the plain key is
+* returned only here, the APIKey holds just its hash
+**/
+func NewAPIKey(name, project string, scopes []model.APIKeyScope) (model.APIKey, string, error) {
+	if len(scopes) == 0 {
+		return model.APIKey{}, "", fmt.Errorf("at least one scope is required")
+	}
+	for _, scope := range scopes {
+		if !scope.Valid() {
+			return model.APIKey{}, "", fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+	if project == "" {
+		project = model.DEFAULT_PROJECT
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return model.APIKey{}, "", err
+	}
+	plain := keyPrefix + hex.EncodeToString(secret)
+
+	key := model.APIKey{
+		ID:        uuid.NewString(),
+		Name:      name,
+		Project:   project,
+		Prefix:    plain[:displayLength],
+		Hash:      HashKey(plain),
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+	}
+
+	return key, plain, nil
+}
+
+/*
+* keys are random, so a plain sha256 is enough
+* and lets the store look them up by hash
+**/
+func HashKey(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+/*
+* reads scopes like "ingest,read"
+**/
+func ParseScopes(raw []string) ([]model.APIKeyScope, error) {
+	var scopes []model.APIKeyScope
+	for _, v := range raw {
+		for part := range strings.SplitSeq(v, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+
+			scope := model.APIKeyScope(part)
+			if !scope.Valid() {
+				return nil, fmt.Errorf("unknown scope %q, expected ingest or read", part)
+			}
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
+}
@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/xonoxc/scopion/internal/model"
+)
+
+func TestNewAPIKey(t *testing.T) {
+	key, plain, err := NewAPIKey("ci", "", []model.APIKeyScope{model.SCOPE_INGEST})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(plain, key.Prefix) {
+		t.Errorf("Expected prefix %q of %q", key.Prefix, plain)
+	}
+	if key.Hash != HashKey(plain) || strings.Contains(key.Hash, plain) {
+		t.Error("Expected only the hash of the key to be kept")
+	}
+	if key.Project != model.DEFAULT_PROJECT {
+		t.Errorf("Expected default project, got %q", key.Project)
+	}
+	if !key.HasScope(model.SCOPE_INGEST) || key.HasScope(model.SCOPE_READ) {
+		t.Errorf("Unexpected scopes %v", key.Scopes)
+	}
+
+	if _, _, err := NewAPIKey("none", "", nil); err == nil {
+		t.Error("Expected error for a key without scopes")
+	}
+}
+
+func TestParseScopes(t *testing.T) {
+	scopes, err := ParseScopes([]string{"ingest, read"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scopes) != 2 || scopes[1] != model.SCOPE_READ {
+		t.Errorf("Unexpected scopes %v", scopes)
+	}
+
+	if _, err := ParseScopes([]string{"admin"}); err == nil {
+		t.Error("Expected error for unknown scope")
+	}
+}
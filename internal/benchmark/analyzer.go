@@ -40,6 +40,18 @@ func (br *BenchmarkRunner) RunStressTest() ([]BenchmarkResult, error) {
 	return generator.StressTest(nil, 100, 10*time.Minute)
 }
 
+// RunRampTest runs a single benchmark whose target rate follows config.Ramp
+func (br *BenchmarkRunner) RunRampTest() (*BenchmarkResult, error) {
+	log.Printf("Running %s ramp test...", br.config.Ramp.Kind)
+
+	generator, err := NewLoadGenerator(br.config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create load generator: %w", err)
+	}
+
+	return generator.Run(nil)
+}
+
 func (br *BenchmarkRunner) RunDatabaseLimitsTest() (*DatabaseLimitsReport, error) {
 	log.Println("Running database limits test...")
 
@@ -50,6 +62,7 @@ func (br *BenchmarkRunner) RunDatabaseLimitsTest() (*DatabaseLimitsReport, error
 	defer stressTest.Cleanup()
 
 	report := &DatabaseLimitsReport{
+		Backend:   br.config.backend(),
 		StartTime: time.Now(),
 		Tests:     make([]TestResult, 0),
 	}
@@ -112,6 +125,7 @@ func (br *BenchmarkRunner) RunDatabaseLimitsTest() (*DatabaseLimitsReport, error
 
 // DatabaseLimitsReport contains results from database limits testing
 type DatabaseLimitsReport struct {
+	Backend   Backend          `json:"backend"`
 	StartTime time.Time        `json:"start_time"`
 	EndTime   time.Time        `json:"end_time"`
 	Duration  time.Duration    `json:"duration"`
@@ -165,17 +179,21 @@ func (dlr *DatabaseLimitsReport) GenerateAnalysis() {
 	analysis.MaxEventsPerSecond = maxEPS
 
 	// Generate recommendations
+	name := dlr.Backend.DisplayName()
 	if analysis.MaxEventsPerSecond < 1000 {
 		analysis.RecommendedSwitchPoint = "Consider switching to PostgreSQL or MySQL for better performance"
-		analysis.PerformanceDegradation = "High - SQLite cannot handle moderate load efficiently"
+		if !dlr.Backend.isSqlite() {
+			analysis.RecommendedSwitchPoint = fmt.Sprintf("Review %s configuration and hardware before relying on it", name)
+		}
+		analysis.PerformanceDegradation = fmt.Sprintf("High - %s cannot handle moderate load efficiently", name)
 		analysis.RiskAssessment = "High risk for production use under normal load"
 	} else if analysis.MaxEventsPerSecond < 5000 {
-		analysis.RecommendedSwitchPoint = "SQLite acceptable for light to moderate load, monitor closely"
-		analysis.PerformanceDegradation = "Medium - SQLite works but may struggle under peak load"
+		analysis.RecommendedSwitchPoint = fmt.Sprintf("%s acceptable for light to moderate load, monitor closely", name)
+		analysis.PerformanceDegradation = fmt.Sprintf("Medium - %s works but may struggle under peak load", name)
 		analysis.RiskAssessment = "Medium risk, acceptable for development/testing"
 	} else {
-		analysis.RecommendedSwitchPoint = "SQLite performing well, continue monitoring"
-		analysis.PerformanceDegradation = "Low - SQLite handling load effectively"
+		analysis.RecommendedSwitchPoint = fmt.Sprintf("%s performing well, continue monitoring", name)
+		analysis.PerformanceDegradation = fmt.Sprintf("Low - %s handling load effectively", name)
 		analysis.RiskAssessment = "Low risk for current workload"
 	}
 
@@ -197,7 +215,7 @@ func (dlr *DatabaseLimitsReport) SaveReport(filename string) error {
 
 // PrintReport prints a human-readable version of the report
 func (dlr *DatabaseLimitsReport) PrintReport() {
-	fmt.Println("=== SQLite Database Limits Report ===")
+	fmt.Printf("=== %s Database Limits Report ===\n", dlr.Backend.DisplayName())
 	fmt.Printf("Test Duration: %v\n", dlr.Duration)
 	fmt.Printf("Tests Run: %d\n", len(dlr.Tests))
 	fmt.Println()
@@ -447,23 +465,31 @@ func (pa *PerformanceAnalyzer) calculateAverage() BenchmarkResult {
 
 func (pa *PerformanceAnalyzer) analyzePatterns(rec *PerformanceRecommendation) {
 	maxEPS := rec.BestPerformance.EventsPerSecond
+	backend := rec.BestPerformance.Backend
+	name := backend.DisplayName()
 
 	if maxEPS < 100 {
 		rec.Recommendation = "Switch to PostgreSQL immediately - SQLite cannot handle even basic load"
+		if !backend.isSqlite() {
+			rec.Recommendation = fmt.Sprintf("Review %s configuration and hardware - it cannot handle even basic load", name)
+		}
 		rec.Confidence = "High"
 		rec.Reasoning = "Maximum events/second is below acceptable threshold for any real application"
 	} else if maxEPS < 1000 {
 		rec.Recommendation = "Consider switching to PostgreSQL for better performance and concurrency"
+		if !backend.isSqlite() {
+			rec.Recommendation = fmt.Sprintf("Tune %s for better performance and concurrency", name)
+		}
 		rec.Confidence = "Medium"
-		rec.Reasoning = "SQLite shows performance limitations under moderate load"
+		rec.Reasoning = fmt.Sprintf("%s shows performance limitations under moderate load", name)
 	} else if maxEPS < 5000 {
-		rec.Recommendation = "SQLite acceptable for development/testing, monitor production usage"
+		rec.Recommendation = fmt.Sprintf("%s acceptable for development/testing, monitor production usage", name)
 		rec.Confidence = "Medium"
-		rec.Reasoning = "SQLite performing adequately but may not scale well"
+		rec.Reasoning = fmt.Sprintf("%s performing adequately but may not scale well", name)
 	} else {
-		rec.Recommendation = "SQLite performing well for current workload"
+		rec.Recommendation = fmt.Sprintf("%s performing well for current workload", name)
 		rec.Confidence = "High"
-		rec.Reasoning = "Performance metrics indicate SQLite can handle the current load effectively"
+		rec.Reasoning = fmt.Sprintf("Performance metrics indicate %s can handle the current load effectively", name)
 	}
 
 	// Check for memory issues
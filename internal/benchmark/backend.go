@@ -0,0 +1,136 @@
+package benchmark
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/xonoxc/scopion/internal/store"
+	"github.com/xonoxc/scopion/internal/store/dualwrite"
+	"github.com/xonoxc/scopion/internal/store/migrations"
+	"github.com/xonoxc/scopion/internal/store/postgres"
+	"github.com/xonoxc/scopion/internal/store/sqlite"
+
+	migrateable "github.com/xonoxc/scopion/internal/store/migratable"
+)
+
+type Backend string
+
+const (
+	BACKEND_SQLITE   Backend = "sqlite"
+	BACKEND_POSTGRES Backend = "postgres"
+
+	// writes to SQLite first and Postgres second, as the server
+	// does while migrating from one to the other
+	BACKEND_DUAL_WRITE Backend = "dual"
+)
+
+func ParseBackend(s string) (Backend, error) {
+	switch backend := Backend(strings.ToLower(strings.TrimSpace(s))); backend {
+	case BACKEND_SQLITE, BACKEND_POSTGRES, BACKEND_DUAL_WRITE:
+		return backend, nil
+	}
+	return "", fmt.Errorf("unknown backend %q, expected sqlite, postgres or dual", s)
+}
+
+// DisplayName is how reports refer to the backend
+func (b Backend) DisplayName() string {
+	switch b {
+	case BACKEND_POSTGRES:
+		return "PostgreSQL"
+	case BACKEND_DUAL_WRITE:
+		return "SQLite + PostgreSQL dual write"
+	default:
+		return "SQLite"
+	}
+}
+
+func (b Backend) isSqlite() bool {
+	return b == "" || b == BACKEND_SQLITE
+}
+
+// openBackend opens the store a benchmark writes to and
+// creates its schema when the database doesn't have it yet
+func openBackend(config BenchmarkConfig) (store.Storage, error) {
+	switch config.Backend {
+	case "", BACKEND_SQLITE:
+		return openSqlite(config.DatabasePath)
+
+	case BACKEND_POSTGRES:
+		return openPostgres(config.PostgresDSN)
+
+	case BACKEND_DUAL_WRITE:
+		primary, err := openSqlite(config.DatabasePath)
+		if err != nil {
+			return nil, err
+		}
+		secondary, err := openPostgres(config.PostgresDSN)
+		if err != nil {
+			primary.Close()
+			return nil, err
+		}
+		return dualwrite.New(primary, secondary), nil
+	}
+
+	return nil, fmt.Errorf("unknown backend %q", config.Backend)
+}
+
+func openSqlite(path string) (*sqlite.SqliteStore, error) {
+	s, err := sqlite.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create store: %w", err)
+	}
+
+	if err := migrations.MigrateSqlite(path); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	return s, nil
+}
+
+func openPostgres(dsn string) (*postgres.PostgresStore, error) {
+	if dsn == "" {
+		return nil, errors.New("a postgres DSN is required for the postgres and dual backends")
+	}
+
+	s, err := postgres.New(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create postgres store: %w", err)
+	}
+
+	if err := migrations.New(dsn).Migrate(migrateable.POSTGRES, migrations.GetAll()); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("failed to migrate postgres: %w", err)
+	}
+
+	return s, nil
+}
+
+// databaseSize reports the bytes a store takes up,
+// both sides of a dual write added together
+func databaseSize(s store.Storage) (int64, error) {
+	if d, ok := s.(*dualwrite.DualWriteStore); ok {
+		primary, err := databaseSize(d.Primary())
+		if err != nil {
+			return 0, err
+		}
+		secondary, err := databaseSize(d.Secondary())
+		return primary + secondary, err
+	}
+
+	m, ok := s.(migrateable.Migratable)
+	if !ok {
+		return 0, nil
+	}
+
+	var size int64
+	var err error
+	switch m.Dialect() {
+	case migrateable.SQLITE:
+		err = m.DB().QueryRow(`SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()`).Scan(&size)
+	case migrateable.POSTGRES:
+		err = m.DB().QueryRow(`SELECT pg_database_size(current_database())`).Scan(&size)
+	}
+	return size, err
+}
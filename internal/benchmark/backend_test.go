@@ -0,0 +1,96 @@
+package benchmark
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseBackend(t *testing.T) {
+	for input, want := range map[string]Backend{"sqlite": BACKEND_SQLITE, " Postgres": BACKEND_POSTGRES, "dual": BACKEND_DUAL_WRITE} {
+		if got, err := ParseBackend(input); err != nil || got != want {
+			t.Errorf("%q: expected %s, got %s (%v)", input, want, got, err)
+		}
+	}
+	if _, err := ParseBackend("mysql"); err == nil {
+		t.Error("Expected an unknown backend to be rejected")
+	}
+
+	if _, err := openBackend(BenchmarkConfig{Backend: BACKEND_POSTGRES}); err == nil {
+		t.Error("Expected postgres without a DSN to be rejected")
+	}
+}
+
+func TestOpenSqliteBackendMigrates(t *testing.T) {
+	config := BenchmarkConfig{DatabasePath: filepath.Join(t.TempDir(), "bench.db")}
+
+	/*
+	* the second open finds the schema the first one created
+	**/
+	for range 2 {
+		s, err := openBackend(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Append(generateEvent(0)); err != nil {
+			t.Fatal(err)
+		}
+
+		size, err := databaseSize(s)
+		if err != nil || size == 0 {
+			t.Errorf("Expected the database size, got %d (%v)", size, err)
+		}
+		s.Close()
+	}
+}
+
+func TestLoadGeneratorRunsAgainstBackend(t *testing.T) {
+	generator, err := NewLoadGenerator(BenchmarkConfig{
+		DatabasePath: filepath.Join(t.TempDir(), "bench.db"),
+		Duration:     500 * time.Millisecond,
+		Workers:      4,
+		BatchSize:    1,
+		EventRate:    200,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := generator.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Backend != BACKEND_SQLITE || result.ErrorCount != 0 || result.TargetRate != 200 {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if result.TotalEvents < 80 || result.TotalEvents > 120 {
+		t.Errorf("Expected about 100 events at 200/sec, got %d", result.TotalEvents)
+	}
+}
+
+func TestPrintComparison(t *testing.T) {
+	var out bytes.Buffer
+	err := PrintComparison(&out, []BackendResult{
+		{Backend: BACKEND_SQLITE, Result: &BenchmarkResult{EventsPerSecond: 400}},
+		{Backend: BACKEND_POSTGRES, Result: &BenchmarkResult{EventsPerSecond: 1000, TargetRate: 1000}},
+		{Backend: BACKEND_DUAL_WRITE, Error: "connection refused\nmore detail"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Expected a header and a row per backend, got %q", out.String())
+	}
+	if !strings.Contains(lines[1], "1.00x") || !strings.Contains(lines[2], "2.50x") || !strings.Contains(lines[2], "1000 (100.0%)") {
+		t.Errorf("Expected throughput relative to sqlite, got %q", out.String())
+	}
+	if !strings.Contains(lines[3], "failed: connection refused") {
+		t.Errorf("Expected the failed backend's reason, got %q", lines[3])
+	}
+}
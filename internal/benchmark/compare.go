@@ -0,0 +1,63 @@
+package benchmark
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// BackendResult is one backend's side of a comparison, Error is set
+// instead of Result when the benchmark couldn't run against it
+type BackendResult struct {
+	Backend Backend          `json:"backend"`
+	Result  *BenchmarkResult `json:"result,omitempty"`
+	Error   string           `json:"error,omitempty"`
+}
+
+// PrintComparison writes the backends side by side, throughput
+// relative to the first backend that ran
+func PrintComparison(w io.Writer, results []BackendResult) error {
+	var baseline float64
+	for _, r := range results {
+		if r.Result != nil && r.Result.EventsPerSecond > 0 {
+			baseline = r.Result.EventsPerSecond
+			break
+		}
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "BACKEND\tEVENTS/SEC\tRELATIVE\tTARGET\tAVG LATENCY\tMAX LATENCY\tERRORS\tDB SIZE\tFELL BEHIND")
+
+	for _, r := range results {
+		if r.Result == nil {
+			reason, _, _ := strings.Cut(r.Error, "\n")
+			fmt.Fprintf(tw, "%s\tfailed: %s\t\t\t\t\t\t\t\n", r.Backend, reason)
+			continue
+		}
+		res := r.Result
+
+		relative := "-"
+		if baseline > 0 {
+			relative = fmt.Sprintf("%.2fx", res.EventsPerSecond/baseline)
+		}
+
+		target := "unlimited"
+		if res.TargetRate > 0 {
+			target = fmt.Sprintf("%.0f (%.1f%%)", res.TargetRate, res.EventsPerSecond/res.TargetRate*100)
+		}
+
+		behind := "-"
+		if sample, ok := res.Saturation(); ok {
+			behind = fmt.Sprintf("%.0f/sec at %v", sample.Target, sample.Elapsed.Round(time.Second))
+		}
+
+		fmt.Fprintf(tw, "%s\t%.2f\t%s\t%s\t%v\t%v\t%d\t%.2f MB\t%s\n",
+			r.Backend, res.EventsPerSecond, relative, target,
+			res.AvgLatency.Round(time.Microsecond), res.MaxLatency.Round(time.Microsecond),
+			res.ErrorCount, res.DatabaseSizeMB, behind)
+	}
+
+	return tw.Flush()
+}
@@ -11,7 +11,7 @@ import (
 	"time"
 
 	"github.com/xonoxc/scopion/internal/model"
-	"github.com/xonoxc/scopion/internal/store/sqlite"
+	"github.com/xonoxc/scopion/internal/store"
 )
 
 type BenchmarkConfig struct {
@@ -23,9 +23,17 @@ type BenchmarkConfig struct {
 	EnableWAL    bool
 	EnableSync   bool
 	DatabasePath string
+
+	// store written to, SQLite at DatabasePath when empty
+	Backend     Backend
+	PostgresDSN string
+
+	// replaces EventRate with a target that changes over the run
+	Ramp RampProfile
 }
 
 type BenchmarkResult struct {
+	Backend         Backend
 	TotalEvents     int64
 	EventsPerSecond float64
 	AvgLatency      time.Duration
@@ -38,6 +46,12 @@ type BenchmarkResult struct {
 	StartTime       time.Time
 	EndTime         time.Time
 	SystemStats     SystemStats
+
+	// events per second asked for on average, 0 when unlimited
+	TargetRate float64
+
+	// target against achieved rate every second of a ramped run
+	Timeline []RateSample
 }
 
 type SystemStats struct {
@@ -50,24 +64,49 @@ type SystemStats struct {
 
 type LoadGenerator struct {
 	config     BenchmarkConfig
-	store      *sqlite.SqliteStore
+	store      store.Storage
 	results    *BenchmarkResult
 	stopChan   chan struct{}
 	wg         sync.WaitGroup
 	latencies  []time.Duration
 	latencyMux sync.Mutex
+
+	// nil when workers run flat out
+	limiter  *RateLimiter
+	rampDone chan struct{}
+}
+
+// how often a ramp moves the target rate, and how
+// often its target and achieved rates are sampled
+const (
+	rampTick           = 100 * time.Millisecond
+	rampSampleInterval = time.Second
+)
+
+func (c BenchmarkConfig) backend() Backend {
+	if c.Backend == "" {
+		return BACKEND_SQLITE
+	}
+	return c.Backend
 }
 
 func NewLoadGenerator(config BenchmarkConfig) (*LoadGenerator, error) {
-	s, err := sqlite.New(config.DatabasePath)
+	if config.Ramp.Enabled() {
+		if err := config.Ramp.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	s, err := openBackend(config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create store: %w", err)
+		return nil, err
 	}
 
 	return &LoadGenerator{
 		config: config,
 		store:  s,
 		results: &BenchmarkResult{
+			Backend:   config.backend(),
 			StartTime: time.Now(),
 		},
 		stopChan:  make(chan struct{}),
@@ -80,21 +119,38 @@ func (lg *LoadGenerator) Run(ctx context.Context) (*BenchmarkResult, error) {
 		ctx = context.Background()
 	}
 
-	log.Printf("Starting benchmark: %d workers, target %d events/sec, duration %v", lg.config.Workers, lg.config.EventRate, lg.config.Duration)
+	// cancelled once the run is over, releasing workers waiting on the limiter
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	monitor := NewMonitor(lg.config.DatabasePath)
+	if lg.config.Ramp.Enabled() {
+		ramp := lg.config.Ramp
+		log.Printf("Starting benchmark: %d workers, %s ramp from %d to %d events/sec, duration %v",
+			lg.config.Workers, ramp.Kind, ramp.From, ramp.To, lg.config.Duration)
+	} else {
+		log.Printf("Starting benchmark: %d workers, target %d events/sec, duration %v", lg.config.Workers, lg.config.EventRate, lg.config.Duration)
+	}
+
+	lg.results.StartTime = time.Now()
+
+	monitor := NewMonitor(lg.store)
 	go monitor.Start(ctx, lg.results)
 
+	switch {
+	case lg.config.Ramp.Enabled():
+		lg.limiter = NewRateLimiter(lg.config.Ramp.RateAt(0, lg.config.Duration))
+		lg.rampDone = make(chan struct{})
+		go lg.followRamp(ctx)
+	case lg.config.EventRate > 0:
+		lg.limiter = NewRateLimiter(float64(lg.config.EventRate))
+		lg.results.TargetRate = float64(lg.config.EventRate)
+	}
+
 	for i := 0; i < lg.config.Workers; i++ {
 		lg.wg.Add(1)
 		go lg.worker(ctx, i)
 	}
 
-	if lg.config.EventRate > 0 {
-		// TODO: Implement rate limiting
-		_ = NewRateLimiter(lg.config.EventRate)
-	}
-
 	timer := time.NewTimer(lg.config.Duration)
 	defer timer.Stop()
 
@@ -106,8 +162,13 @@ func (lg *LoadGenerator) Run(ctx context.Context) (*BenchmarkResult, error) {
 	}
 
 	close(lg.stopChan)
+	cancel()
 	lg.wg.Wait()
 
+	if lg.rampDone != nil {
+		<-lg.rampDone
+	}
+
 	if monitor != nil {
 		monitor.Stop()
 	}
@@ -121,8 +182,13 @@ func (lg *LoadGenerator) Run(ctx context.Context) (*BenchmarkResult, error) {
 		lg.store.Close()
 	}
 
-	log.Printf("Benchmark completed: %d events, %.2f events/sec",
-		lg.results.TotalEvents, lg.results.EventsPerSecond)
+	if lg.results.TargetRate > 0 {
+		log.Printf("Benchmark completed: %d events, %.2f events/sec of %.2f targeted",
+			lg.results.TotalEvents, lg.results.EventsPerSecond, lg.results.TargetRate)
+	} else {
+		log.Printf("Benchmark completed: %d events, %.2f events/sec",
+			lg.results.TotalEvents, lg.results.EventsPerSecond)
+	}
 
 	return lg.results, nil
 }
@@ -131,20 +197,26 @@ func (lg *LoadGenerator) worker(ctx context.Context, workerID int) {
 	defer lg.wg.Done()
 
 	batch := make([]model.Event, 0, lg.config.BatchSize)
+	defer func() {
+		if len(batch) > 0 {
+			lg.processBatch(batch)
+		}
+	}()
 
 	for {
+		if lg.limiter != nil && lg.limiter.Acquire(ctx) != nil {
+			return
+		}
+
 		select {
 		case <-lg.stopChan:
-			if len(batch) > 0 {
-				lg.processBatch(batch)
-			}
 			return
 		case <-ctx.Done():
 			return
 		default:
 		}
 
-		event := lg.generateEvent(workerID)
+		event := generateEvent(workerID)
 
 		if lg.config.BatchSize > 1 {
 			batch = append(batch, event)
@@ -162,6 +234,60 @@ func (lg *LoadGenerator) worker(ctx context.Context, workerID int) {
 	}
 }
 
+// followRamp moves the limiter along the ramp, recording the target
+// rate it asked for next to the rate the workers achieved
+func (lg *LoadGenerator) followRamp(ctx context.Context) {
+	defer close(lg.rampDone)
+
+	ticker := time.NewTicker(rampTick)
+	defer ticker.Stop()
+
+	start := lg.results.StartTime
+	last, sampleStart := start, start
+	var expected, sampleExpected float64
+	var sampleEvents int64
+
+	sample := func(now time.Time) {
+		window := now.Sub(sampleStart).Seconds()
+		events := atomic.LoadInt64(&lg.results.TotalEvents)
+
+		lg.results.Timeline = append(lg.results.Timeline, RateSample{
+			Elapsed:  now.Sub(start),
+			Target:   sampleExpected / window,
+			Achieved: float64(events-sampleEvents) / window,
+		})
+		sampleStart, sampleExpected, sampleEvents = now, 0, events
+	}
+
+	for {
+		var now time.Time
+		select {
+		case now = <-ticker.C:
+		case <-ctx.Done():
+			now = time.Now()
+		}
+
+		// events asked for since the last tick, at the rate set then
+		due := lg.limiter.Rate() * now.Sub(last).Seconds()
+		expected += due
+		sampleExpected += due
+		last = now
+
+		if ctx.Err() != nil {
+			if now.Sub(sampleStart) >= rampTick {
+				sample(now)
+			}
+			lg.results.TargetRate = expected / now.Sub(start).Seconds()
+			return
+		}
+
+		lg.limiter.SetRate(lg.config.Ramp.RateAt(now.Sub(start), lg.config.Duration))
+		if now.Sub(sampleStart) >= rampSampleInterval {
+			sample(now)
+		}
+	}
+}
+
 func (lg *LoadGenerator) processEvent(event model.Event) {
 	if lg.store == nil {
 		atomic.AddInt64(&lg.results.ErrorCount, 1)
@@ -214,7 +340,7 @@ func (lg *LoadGenerator) processBatch(batch []model.Event) {
 	lg.latencyMux.Unlock()
 }
 
-func (lg *LoadGenerator) generateEvent(workerID int) model.Event {
+func generateEvent(workerID int) model.Event {
 	return model.Event{
 		ID:        fmt.Sprintf("bench-%d-%d-%d", workerID, time.Now().UnixNano(), rand.Int63()),
 		Timestamp: time.Now(),
@@ -283,11 +409,21 @@ func (lg *LoadGenerator) StressTest(ctx context.Context, maxWorkers int, maxDura
 		{Duration: 60 * time.Second, Workers: maxWorkers, BatchSize: 200, EventRate: 10000},
 	}
 
+	// a starting rate scales every phase, keeping the progression
+	scale := 1.0
+	if lg.config.EventRate > 0 {
+		scale = float64(lg.config.EventRate) / float64(configs[0].EventRate)
+	}
+
 	for i, config := range configs {
+		config.EventRate = max(int(float64(config.EventRate)*scale), 1)
+
 		log.Printf("Running stress test phase %d/%d: %d workers, %d events/sec",
 			i+1, len(configs), config.Workers, config.EventRate)
 
 		config.DatabasePath = lg.config.DatabasePath
+		config.Backend = lg.config.Backend
+		config.PostgresDSN = lg.config.PostgresDSN
 		config.Duration = maxDuration / time.Duration(len(configs))
 
 		generator, err := NewLoadGenerator(config)
@@ -0,0 +1,416 @@
+package benchmark
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	client "github.com/xonoxc/scopion/clients/go"
+	"github.com/xonoxc/scopion/internal/app"
+	"github.com/xonoxc/scopion/internal/ingest"
+	"github.com/xonoxc/scopion/internal/store"
+)
+
+// HTTPConfig drives the /ingest endpoint of a server the way clients do,
+// through JSON decoding, the middleware, the ingest queue and the live
+// broadcaster. Duration and EventRate come from the embedded config,
+// its backend is only used for a server started in process
+type HTTPConfig struct {
+	BenchmarkConfig
+
+	// server to send to, one is started in process when empty
+	URL    string
+	APIKey string
+
+	// requests in flight at once
+	Concurrency int
+
+	// opens a connection per request instead of reusing them
+	DisableKeepAlives bool
+
+	// pads events to about this many bytes of JSON, 0 leaves them as they are
+	PayloadBytes int
+
+	// live SSE streams open during the run
+	Subscribers int
+}
+
+// HTTPResult holds the usual results, counting accepted events, next
+// to what only the HTTP path has: rejected requests, latency
+// percentiles and the events live subscribers received
+type HTTPResult struct {
+	BenchmarkResult
+
+	URL          string
+	Concurrency  int
+	KeepAlive    bool
+	PayloadBytes int
+	Subscribers  int
+
+	// 202 Accepted, 429 Too Many Requests and anything else
+	Accepted  int64
+	Throttled int64
+	Failed    int64
+
+	// latencies of every response, rejected ones included
+	P50  time.Duration
+	P90  time.Duration
+	P99  time.Duration
+	P999 time.Duration
+
+	// events all subscribers received together,
+	// and how often a stream was dropped for falling behind
+	LiveEvents  int64
+	LiveDropped int64
+}
+
+// LiveDelivery is the share of accepted events the average subscriber received
+func (r *HTTPResult) LiveDelivery() float64 {
+	if r.Subscribers == 0 || r.Accepted == 0 {
+		return 0
+	}
+	return float64(r.LiveEvents) / float64(r.Accepted*int64(r.Subscribers))
+}
+
+// how long subscribers keep listening once the load stops,
+// for events still on their way through the queue
+const liveSettle = time.Second
+
+type HTTPLoadGenerator struct {
+	config HTTPConfig
+	client *client.Client
+
+	// set when the server runs in process
+	server *inProcessServer
+
+	results   *HTTPResult
+	latencies [][]time.Duration
+	limiter   *RateLimiter
+}
+
+func NewHTTPLoadGenerator(config HTTPConfig) (*HTTPLoadGenerator, error) {
+	if config.Concurrency <= 0 {
+		config.Concurrency = 10
+	}
+
+	hg := &HTTPLoadGenerator{
+		config: config,
+		results: &HTTPResult{
+			URL:          config.URL,
+			Concurrency:  config.Concurrency,
+			KeepAlive:    !config.DisableKeepAlives,
+			PayloadBytes: config.PayloadBytes,
+			Subscribers:  config.Subscribers,
+		},
+		latencies: make([][]time.Duration, config.Concurrency),
+	}
+
+	if config.URL == "" {
+		server, err := startInProcessServer(config.BenchmarkConfig)
+		if err != nil {
+			return nil, err
+		}
+		hg.server = server
+		hg.results.URL = server.url
+		hg.results.Backend = config.backend()
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DisableKeepAlives = config.DisableKeepAlives
+	transport.MaxIdleConnsPerHost = config.Concurrency
+
+	hg.client = &client.Client{
+		BaseURL:    strings.TrimRight(hg.results.URL, "/"),
+		APIKey:     config.APIKey,
+		HTTPClient: &http.Client{Transport: transport},
+	}
+
+	return hg, nil
+}
+
+func (hg *HTTPLoadGenerator) Run(ctx context.Context) (*HTTPResult, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if hg.server != nil {
+		defer hg.server.close()
+	}
+
+	log.Printf("Starting HTTP benchmark against %s: %d concurrent requests, %d subscribers, target %d events/sec, duration %v",
+		hg.results.URL, hg.config.Concurrency, hg.config.Subscribers, hg.config.EventRate, hg.config.Duration)
+
+	// subscribers outlive the load by liveSettle
+	liveCtx, stopLive := context.WithCancel(ctx)
+	defer stopLive()
+
+	var live sync.WaitGroup
+	for i := 0; i < hg.config.Subscribers; i++ {
+		if err := hg.subscribe(liveCtx, &live); err != nil {
+			stopLive()
+			live.Wait()
+			return nil, fmt.Errorf("failed to subscribe to live events: %w", err)
+		}
+	}
+
+	// the server logs every request, which would bury the results. the
+	// middleware still formats each line, it just isn't written anywhere
+	logs := log.Writer()
+	if hg.server != nil {
+		log.SetOutput(io.Discard)
+		defer log.SetOutput(logs)
+	}
+
+	loadCtx, cancel := context.WithTimeout(ctx, hg.config.Duration)
+	defer cancel()
+
+	if hg.config.EventRate > 0 {
+		hg.limiter = NewRateLimiter(float64(hg.config.EventRate))
+		hg.results.TargetRate = float64(hg.config.EventRate)
+	}
+
+	// the database size is only known for a server in process
+	var s store.Storage
+	if hg.server != nil {
+		s = hg.server.store
+	}
+	monitor := NewMonitor(s)
+	go monitor.Start(ctx, &hg.results.BenchmarkResult)
+
+	hg.results.StartTime = time.Now()
+
+	var workers sync.WaitGroup
+	for i := 0; i < hg.config.Concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			hg.worker(loadCtx, i)
+		}()
+	}
+	workers.Wait()
+
+	hg.results.EndTime = time.Now()
+	hg.results.Duration = hg.results.EndTime.Sub(hg.results.StartTime)
+
+	if hg.config.Subscribers > 0 {
+		select {
+		case <-time.After(liveSettle):
+		case <-ctx.Done():
+		}
+	}
+	stopLive()
+	live.Wait()
+
+	monitor.Stop()
+	if hg.server != nil {
+		hg.server.close()
+	}
+	log.SetOutput(logs)
+
+	hg.calculateStats()
+
+	log.Printf("HTTP benchmark completed: %d accepted, %d throttled, %d failed, %.2f events/sec, p99 %v",
+		hg.results.Accepted, hg.results.Throttled, hg.results.Failed, hg.results.EventsPerSecond, hg.results.P99)
+
+	return hg.results, nil
+}
+
+func (hg *HTTPLoadGenerator) worker(ctx context.Context, workerID int) {
+	padding := hg.padding(workerID)
+
+	for {
+		if hg.limiter != nil && hg.limiter.Acquire(ctx) != nil {
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		event := generateEvent(workerID)
+		if padding != "" {
+			event.Data["padding"] = padding
+		}
+
+		// a request in flight when the run ends is still answered,
+		// the server may already have accepted it
+		start := time.Now()
+		err := hg.client.Ingest(context.WithoutCancel(ctx), event)
+		latency := time.Since(start)
+
+		var apiErr *client.APIError
+		switch {
+		case err == nil:
+			atomic.AddInt64(&hg.results.Accepted, 1)
+		case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests:
+			atomic.AddInt64(&hg.results.Throttled, 1)
+		default:
+			atomic.AddInt64(&hg.results.Failed, 1)
+		}
+
+		// each worker owns its slice, merged once the run is over
+		hg.latencies[workerID] = append(hg.latencies[workerID], latency)
+	}
+}
+
+// padding is what brings a worker's events up to PayloadBytes
+func (hg *HTTPLoadGenerator) padding(workerID int) string {
+	if hg.config.PayloadBytes <= 0 {
+		return ""
+	}
+
+	event := generateEvent(workerID)
+	event.Data["padding"] = ""
+	encoded, err := json.Marshal(event)
+	if err != nil {
+		return ""
+	}
+	return strings.Repeat("x", max(hg.config.PayloadBytes-len(encoded), 0))
+}
+
+func (hg *HTTPLoadGenerator) subscribe(ctx context.Context, wg *sync.WaitGroup) error {
+	// a client of its own, so streams don't hold connections the load needs
+	sub := &client.Client{BaseURL: hg.client.BaseURL, APIKey: hg.config.APIKey}
+
+	events, err := sub.SubscribeLive(ctx, client.LiveOptions{
+		Buffer: 1024,
+		OnStatus: func(st client.LiveStatus) {
+			if errors.Is(st.Err, client.ErrLiveDropped) {
+				atomic.AddInt64(&hg.results.LiveDropped, 1)
+			}
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range events {
+			atomic.AddInt64(&hg.results.LiveEvents, 1)
+		}
+	}()
+
+	return nil
+}
+
+func (hg *HTTPLoadGenerator) calculateStats() {
+	res := hg.results
+	res.TotalEvents = res.Accepted
+	res.ErrorCount = res.Throttled + res.Failed
+
+	if res.Duration > 0 {
+		res.EventsPerSecond = float64(res.Accepted) / res.Duration.Seconds()
+	}
+
+	latencies := slices.Concat(hg.latencies...)
+	if len(latencies) == 0 {
+		return
+	}
+	slices.Sort(latencies)
+
+	var total time.Duration
+	for _, latency := range latencies {
+		total += latency
+	}
+
+	res.AvgLatency = total / time.Duration(len(latencies))
+	res.MinLatency = latencies[0]
+	res.MaxLatency = latencies[len(latencies)-1]
+	res.P50 = percentile(latencies, 0.50)
+	res.P90 = percentile(latencies, 0.90)
+	res.P99 = percentile(latencies, 0.99)
+	res.P999 = percentile(latencies, 0.999)
+}
+
+// percentile of latencies sorted in ascending order, nearest rank
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(p*float64(len(sorted))+0.999999) - 1
+	return sorted[min(max(rank, 0), len(sorted)-1)]
+}
+
+// inProcessServer serves the API routes on a loopback port,
+// writing to the backend a benchmark was configured with
+type inProcessServer struct {
+	url      string
+	store    store.Storage
+	api      *app.API
+	server   *http.Server
+	queueDir string
+
+	closeOnce sync.Once
+}
+
+func startInProcessServer(config BenchmarkConfig) (*inProcessServer, error) {
+	s, err := openBackend(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// a queue of its own, nothing left over from the server to replay
+	queueDir, err := os.MkdirTemp("", "scopion-bench-queue-")
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	api, err := app.NewAPI(s, app.ServerConfig{
+		Mode:   app.NORMAL_MODE,
+		Ingest: ingest.QueueConfig{Dir: queueDir},
+	})
+	if err != nil {
+		s.Close()
+		os.RemoveAll(queueDir)
+		return nil, fmt.Errorf("failed to start ingest: %w", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		api.Close()
+		s.Close()
+		os.RemoveAll(queueDir)
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	api.Register(mux)
+
+	ps := &inProcessServer{
+		url:      "http://" + listener.Addr().String(),
+		store:    s,
+		api:      api,
+		server:   &http.Server{Handler: mux},
+		queueDir: queueDir,
+	}
+
+	go func() {
+		if err := ps.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("Benchmark server failed: %v", err)
+		}
+	}()
+
+	return ps, nil
+}
+
+// close writes out the queue before closing the store under it. every
+// request has been answered by then, so connections are cut right away
+func (ps *inProcessServer) close() {
+	ps.closeOnce.Do(func() {
+		ps.server.Close()
+		if err := ps.api.Close(); err != nil {
+			log.Printf("Failed to flush the ingest queue: %v", err)
+		}
+		ps.store.Close()
+		os.RemoveAll(ps.queueDir)
+	})
+}
@@ -0,0 +1,90 @@
+package benchmark
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	latencies := make([]time.Duration, 1000)
+	for i := range latencies {
+		latencies[i] = time.Duration(i+1) * time.Millisecond
+	}
+
+	for p, want := range map[float64]time.Duration{
+		0.50:  500 * time.Millisecond,
+		0.99:  990 * time.Millisecond,
+		0.999: 999 * time.Millisecond,
+		1:     1000 * time.Millisecond,
+	} {
+		if got := percentile(latencies, p); got != want {
+			t.Errorf("p%v: expected %v, got %v", p*100, want, got)
+		}
+	}
+
+	if got := percentile([]time.Duration{time.Second}, 0.5); got != time.Second {
+		t.Errorf("Expected the only latency, got %v", got)
+	}
+}
+
+func TestHTTPLoadGeneratorInProcess(t *testing.T) {
+	generator, err := NewHTTPLoadGenerator(HTTPConfig{
+		BenchmarkConfig: BenchmarkConfig{
+			DatabasePath: filepath.Join(t.TempDir(), "bench.db"),
+			Duration:     500 * time.Millisecond,
+			EventRate:    100,
+		},
+		Concurrency:  8,
+		PayloadBytes: 2048,
+		Subscribers:  2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := generator.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Failed != 0 || result.Throttled != 0 {
+		t.Errorf("Expected every event to be accepted, got %d throttled and %d failed", result.Throttled, result.Failed)
+	}
+
+	/*
+	* fewer get through when the race detector slows the server
+	* down, the rate still caps how many are sent
+	**/
+	if result.Accepted == 0 || result.Accepted > 60 {
+		t.Errorf("Expected up to 50 events at 100/sec, got %d", result.Accepted)
+	}
+	if result.P50 == 0 || result.P50 > result.P99 || result.P99 > result.MaxLatency {
+		t.Errorf("Unexpected percentiles p50 %v p99 %v max %v", result.P50, result.P99, result.MaxLatency)
+	}
+
+	/*
+	* both subscribers see every event the queue stored
+	**/
+	if result.LiveEvents != 2*result.Accepted || result.LiveDropped != 0 {
+		t.Errorf("Expected %d live events, got %d with %d drops", 2*result.Accepted, result.LiveEvents, result.LiveDropped)
+	}
+	if result.DatabaseSizeMB == 0 {
+		t.Error("Expected the size of the in process database")
+	}
+}
+
+func TestHTTPPaddingReachesPayloadSize(t *testing.T) {
+	hg := &HTTPLoadGenerator{config: HTTPConfig{PayloadBytes: 4096}}
+
+	padding := hg.padding(0)
+	if len(padding) < 3500 || len(padding) > 4096 {
+		t.Errorf("Expected about 4KB of padding, got %d bytes", len(padding))
+	}
+
+	hg.config.PayloadBytes = 0
+	if hg.padding(0) != "" {
+		t.Error("Expected no padding without a payload size")
+	}
+}
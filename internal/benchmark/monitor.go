@@ -2,32 +2,33 @@ package benchmark
 
 import (
 	"context"
-	"fmt"
 	"log"
-	"os"
 	"runtime"
-	"sync/atomic"
+	"sync"
 	"time"
 
-	"github.com/xonoxc/scopion/internal/store/sqlite"
+	"github.com/xonoxc/scopion/internal/store"
 )
 
 type Monitor struct {
-	dbPath    string
+	store     store.Storage
 	result    *BenchmarkResult
 	stopChan  chan struct{}
+	done      chan struct{}
 	startTime time.Time
 }
 
-func NewMonitor(dbPath string) *Monitor {
+func NewMonitor(s store.Storage) *Monitor {
 	return &Monitor{
-		dbPath:    dbPath,
+		store:     s,
 		stopChan:  make(chan struct{}),
+		done:      make(chan struct{}),
 		startTime: time.Now(),
 	}
 }
 
 func (m *Monitor) Start(ctx context.Context, result *BenchmarkResult) {
+	defer close(m.done)
 	m.result = result
 
 	ticker := time.NewTicker(5 * time.Second)
@@ -45,8 +46,11 @@ func (m *Monitor) Start(ctx context.Context, result *BenchmarkResult) {
 	}
 }
 
+// Stop waits for Start to return, so the final stats
+// aren't collected while it is still collecting
 func (m *Monitor) Stop() {
 	close(m.stopChan)
+	<-m.done
 	m.collectStats()
 }
 
@@ -60,8 +64,8 @@ func (m *Monitor) collectStats() {
 
 	stats.CPUUsage = float64(runtime.NumGoroutine())
 
-	if info, err := os.Stat(m.dbPath); err == nil {
-		m.result.DatabaseSizeMB = float64(info.Size()) / 1024 / 1024
+	if size, err := databaseSize(m.store); err == nil {
+		m.result.DatabaseSizeMB = float64(size) / 1024 / 1024
 	}
 
 	stats.Connections = runtime.NumGoroutine()
@@ -69,56 +73,91 @@ func (m *Monitor) collectStats() {
 	m.result.SystemStats = stats
 }
 
+// RateLimiter paces events shared by any number of workers. Each Acquire
+// reserves the next slot on a virtual schedule, so sleeping late at high
+// rates is made up by the following events instead of lowering the rate
 type RateLimiter struct {
-	rate       int
-	interval   time.Duration
-	lastTime   time.Time
-	permits    int64
-	maxPermits int64
-}
-
-func NewRateLimiter(rate int) *RateLimiter {
-	return &RateLimiter{
-		rate:       rate,
-		interval:   time.Second / time.Duration(rate),
-		lastTime:   time.Now(),
-		maxPermits: int64(rate),
-		permits:    int64(rate),
+	mu    sync.Mutex
+	start time.Time
+
+	// nanoseconds between events, 0 when unlimited
+	interval float64
+
+	// when the next slot is, in nanoseconds since start
+	next float64
+}
+
+// a schedule further behind than this is moved forward, so a stalled
+// store is not answered with a burst of the events it missed
+const maxRateLag = 100 * time.Millisecond
+
+func NewRateLimiter(rate float64) *RateLimiter {
+	rl := &RateLimiter{start: time.Now()}
+	rl.SetRate(rate)
+	return rl
+}
+
+// SetRate changes the events per second from the next Acquire on,
+// 0 or less removes the limit
+func (rl *RateLimiter) SetRate(rate float64) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.interval = 0
+	if rate > 0 {
+		rl.interval = float64(time.Second) / rate
 	}
 }
 
-func (rl *RateLimiter) Acquire() {
-	for {
-		now := time.Now()
-		timePassed := now.Sub(rl.lastTime)
-
-		newPermits := int64(timePassed / rl.interval)
-		if newPermits > 0 {
-			atomic.AddInt64(&rl.permits, newPermits)
-			if atomic.LoadInt64(&rl.permits) > rl.maxPermits {
-				atomic.StoreInt64(&rl.permits, rl.maxPermits)
-			}
-			rl.lastTime = now
-		}
+func (rl *RateLimiter) Rate() float64 {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
 
-		if atomic.LoadInt64(&rl.permits) > 0 {
-			atomic.AddInt64(&rl.permits, -1)
-			return
-		}
+	if rl.interval == 0 {
+		return 0
+	}
+	return float64(time.Second) / rl.interval
+}
+
+// Acquire waits for the next slot, returning early with the
+// context's error when it is cancelled
+func (rl *RateLimiter) Acquire(ctx context.Context) error {
+	rl.mu.Lock()
+	if rl.interval == 0 {
+		rl.mu.Unlock()
+		return ctx.Err()
+	}
 
-		time.Sleep(rl.interval / 10)
+	now := float64(time.Since(rl.start))
+	slot := max(rl.next, now-float64(maxRateLag))
+	rl.next = slot + rl.interval
+	rl.mu.Unlock()
+
+	wait := time.Duration(slot - now)
+	if wait <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
 type DatabaseStressTest struct {
 	config BenchmarkConfig
-	store  *sqlite.SqliteStore
+	store  store.Storage
 }
 
 func NewDatabaseStressTest(config BenchmarkConfig) (*DatabaseStressTest, error) {
-	s, err := sqlite.New(config.DatabasePath)
+	s, err := openBackend(config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create store: %w", err)
+		return nil, err
 	}
 
 	return &DatabaseStressTest{
@@ -0,0 +1,70 @@
+package benchmark
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func acquireFor(t *testing.T, rl *RateLimiter, workers int, d time.Duration) int64 {
+	t.Helper()
+
+	deadline := time.Now().Add(d)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	var acquired int64
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rl.Acquire(ctx) == nil && time.Now().Before(deadline) {
+				atomic.AddInt64(&acquired, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return acquired
+}
+
+func TestRateLimiterSharesRateAcrossWorkers(t *testing.T) {
+	for _, tc := range []struct {
+		rate     float64
+		duration time.Duration
+	}{
+		{rate: 4, duration: time.Second},
+		{rate: 2000, duration: 500 * time.Millisecond},
+		{rate: 500000, duration: 500 * time.Millisecond},
+	} {
+		got := float64(acquireFor(t, NewRateLimiter(tc.rate), 16, tc.duration))
+		want := tc.rate * tc.duration.Seconds()
+
+		if got < want*0.9 || got > want*1.1+1 {
+			t.Errorf("Expected about %.0f events at %.0f/sec, got %.0f", want, tc.rate, got)
+		}
+	}
+}
+
+func TestRateLimiterSetRate(t *testing.T) {
+	rl := NewRateLimiter(0)
+	if rl.Rate() != 0 {
+		t.Fatalf("Expected no limit, got %.2f", rl.Rate())
+	}
+
+	rl.SetRate(100)
+	if got := acquireFor(t, rl, 4, 300*time.Millisecond); got < 25 || got > 35 {
+		t.Errorf("Expected about 30 events at 100/sec, got %d", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rl.SetRate(0.1)
+	rl.Acquire(context.Background())
+	if err := rl.Acquire(ctx); err == nil {
+		t.Error("Expected a cancelled wait to return its error")
+	}
+}
@@ -0,0 +1,99 @@
+package benchmark
+
+import (
+	"fmt"
+	"time"
+)
+
+type RampKind string
+
+const (
+	// From for the first step up to To for the last, in Steps equal steps
+	RAMP_STEP RampKind = "step"
+
+	// From to To evenly over the whole run
+	RAMP_LINEAR RampKind = "linear"
+
+	// From, with To for the middle of Steps steps
+	RAMP_SPIKE RampKind = "spike"
+)
+
+// RampProfile changes the target rate over a run, the zero value
+// keeps EventRate for the whole of it
+type RampProfile struct {
+	Kind  RampKind
+	From  int
+	To    int
+	Steps int
+}
+
+func ParseRampKind(s string) (RampKind, error) {
+	switch kind := RampKind(s); kind {
+	case RAMP_STEP, RAMP_LINEAR, RAMP_SPIKE:
+		return kind, nil
+	}
+	return "", fmt.Errorf("unknown ramp profile %q, expected step, linear or spike", s)
+}
+
+func (p RampProfile) Enabled() bool {
+	return p.Kind != ""
+}
+
+func (p RampProfile) validate() error {
+	if _, err := ParseRampKind(string(p.Kind)); err != nil {
+		return err
+	}
+	if p.From <= 0 || p.To <= 0 {
+		return fmt.Errorf("ramp rates must be positive, got %d to %d", p.From, p.To)
+	}
+	if p.Kind != RAMP_LINEAR && p.Steps < 2 {
+		return fmt.Errorf("%s ramp needs at least 2 steps, got %d", p.Kind, p.Steps)
+	}
+	return nil
+}
+
+// RateAt is the target events per second elapsed into a run lasting total
+func (p RampProfile) RateAt(elapsed, total time.Duration) float64 {
+	from, to := float64(p.From), float64(p.To)
+	progress := min(max(elapsed.Seconds()/total.Seconds(), 0), 1)
+
+	switch p.Kind {
+	case RAMP_LINEAR:
+		return from + (to-from)*progress
+
+	case RAMP_STEP:
+		step := min(int(progress*float64(p.Steps)), p.Steps-1)
+		return from + (to-from)*float64(step)/float64(p.Steps-1)
+
+	case RAMP_SPIKE:
+		if min(int(progress*float64(p.Steps)), p.Steps-1) == p.Steps/2 {
+			return to
+		}
+		return from
+	}
+
+	return from
+}
+
+// RateSample compares the target and achieved events
+// per second over one stretch of a ramped run
+type RateSample struct {
+	Elapsed  time.Duration
+	Target   float64
+	Achieved float64
+}
+
+// how far short of its target a sample may fall
+// before the store is considered saturated
+const saturationRatio = 0.9
+
+// Saturation returns the first sample that fell well short of its target,
+// the load the store could no longer keep up with
+func (r *BenchmarkResult) Saturation() (RateSample, bool) {
+	for _, sample := range r.Timeline {
+		if sample.Achieved < sample.Target*saturationRatio {
+			return sample, true
+		}
+	}
+	return RateSample{}, false
+}
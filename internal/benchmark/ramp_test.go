@@ -0,0 +1,60 @@
+package benchmark
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRampProfiles(t *testing.T) {
+	total := 10 * time.Second
+
+	for _, tc := range []struct {
+		profile RampProfile
+		rates   map[time.Duration]float64
+	}{
+		{
+			profile: RampProfile{Kind: RAMP_STEP, From: 100, To: 400, Steps: 4},
+			rates:   map[time.Duration]float64{0: 100, 3 * time.Second: 200, 6 * time.Second: 300, 9 * time.Second: 400, total: 400},
+		},
+		{
+			profile: RampProfile{Kind: RAMP_LINEAR, From: 100, To: 300},
+			rates:   map[time.Duration]float64{0: 100, 5 * time.Second: 200, total: 300, 2 * total: 300},
+		},
+		{
+			profile: RampProfile{Kind: RAMP_SPIKE, From: 100, To: 1000, Steps: 5},
+			rates:   map[time.Duration]float64{time.Second: 100, 5 * time.Second: 1000, 7 * time.Second: 100},
+		},
+	} {
+		if err := tc.profile.validate(); err != nil {
+			t.Fatal(err)
+		}
+		for elapsed, want := range tc.rates {
+			if got := tc.profile.RateAt(elapsed, total); got != want {
+				t.Errorf("%s at %v: expected %.0f, got %.0f", tc.profile.Kind, elapsed, want, got)
+			}
+		}
+	}
+
+	for _, p := range []RampProfile{
+		{Kind: "sawtooth", From: 1, To: 2, Steps: 2},
+		{Kind: RAMP_LINEAR, From: 0, To: 100},
+		{Kind: RAMP_STEP, From: 10, To: 100, Steps: 1},
+	} {
+		if err := p.validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", p)
+		}
+	}
+}
+
+func TestSaturation(t *testing.T) {
+	result := BenchmarkResult{Timeline: []RateSample{
+		{Elapsed: time.Second, Target: 100, Achieved: 99},
+		{Elapsed: 2 * time.Second, Target: 200, Achieved: 150},
+		{Elapsed: 3 * time.Second, Target: 300, Achieved: 160},
+	}}
+
+	sample, ok := result.Saturation()
+	if !ok || sample.Elapsed != 2*time.Second {
+		t.Errorf("Expected the second sample to fall behind, got %+v", sample)
+	}
+}
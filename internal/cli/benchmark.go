@@ -0,0 +1,192 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/xonoxc/scopion/internal/benchmark"
+)
+
+/*
+* the backends named by --backend, each needing
+* a postgres dsn when it writes to postgres
+**/
+func benchBackends() ([]benchmark.Backend, error) {
+	if benchPGDSN == "" {
+		benchPGDSN = os.Getenv("SCOPION_POSTGRES_DSN")
+	}
+
+	var backends []benchmark.Backend
+	for _, name := range benchBackend {
+		backend, err := benchmark.ParseBackend(name)
+		if err != nil {
+			return nil, err
+		}
+		if backend != benchmark.BACKEND_SQLITE && benchPGDSN == "" {
+			return nil, fmt.Errorf("the %s backend needs --postgres-dsn or $SCOPION_POSTGRES_DSN", backend)
+		}
+		if !slices.Contains(backends, backend) {
+			backends = append(backends, backend)
+		}
+	}
+
+	if len(backends) == 0 {
+		return nil, fmt.Errorf("no backend to benchmark")
+	}
+	return backends, nil
+}
+
+func benchConfig(backend benchmark.Backend) benchmark.BenchmarkConfig {
+	return benchmark.BenchmarkConfig{
+		DatabasePath: "./scopion.db",
+		Duration:     benchDuration,
+		Workers:      benchWorkers,
+		EventRate:    benchRate,
+		BatchSize:    10,
+		Backend:      backend,
+		PostgresDSN:  benchPGDSN,
+	}
+}
+
+/*
+* runs a benchmark against every backend in turn. run returns the result
+* backends are compared on and what --output saves for it. with several
+* backends a failing one doesn't stop the rest, and the output file holds
+* what each would have saved on its own, keyed by backend
+**/
+func compareBackends(run func(benchmark.BenchmarkConfig) (*benchmark.BenchmarkResult, any, error)) error {
+	backends, err := benchBackends()
+	if err != nil {
+		return err
+	}
+
+	if len(backends) == 1 {
+		_, saved, err := run(benchConfig(backends[0]))
+		if err != nil {
+			return err
+		}
+		if benchOutput != "" {
+			return saveBenchmarkResult(saved, benchOutput)
+		}
+		return nil
+	}
+
+	results := make([]benchmark.BackendResult, 0, len(backends))
+	saved := make(map[benchmark.Backend]any, len(backends))
+
+	for _, backend := range backends {
+		fmt.Printf("\n##### %s #####\n\n", backend.DisplayName())
+
+		result, value, err := run(benchConfig(backend))
+		if err != nil {
+			log.Printf("%s benchmark failed: %v", backend, err)
+			results = append(results, benchmark.BackendResult{Backend: backend, Error: err.Error()})
+			saved[backend] = map[string]any{"error": err.Error(), "result": value}
+			continue
+		}
+
+		results = append(results, benchmark.BackendResult{Backend: backend, Result: result})
+		saved[backend] = value
+	}
+
+	fmt.Println("\n=== Backend Comparison ===")
+	if err := benchmark.PrintComparison(os.Stdout, results); err != nil {
+		return err
+	}
+
+	if benchOutput != "" {
+		return saveBenchmarkResult(saved, benchOutput)
+	}
+	return nil
+}
+
+func runRampTest(config benchmark.BenchmarkConfig) (*benchmark.BenchmarkResult, error) {
+	runner := benchmark.NewBenchmarkRunner(config)
+	result, err := runner.RunRampTest()
+	if err != nil {
+		return nil, fmt.Errorf("ramp test failed: %w", err)
+	}
+
+	fmt.Printf("=== %s Ramp Results ===\n", config.Ramp.Kind)
+	fmt.Printf("Duration: %v\n", result.Duration)
+	fmt.Printf("Total Events: %d\n", result.TotalEvents)
+	fmt.Printf("Events/Second: %.2f\n", result.EventsPerSecond)
+	printTargetRate(result)
+	fmt.Printf("Errors: %d\n", result.ErrorCount)
+
+	fmt.Println("\n  Elapsed      Target    Achieved")
+	for _, sample := range result.Timeline {
+		fmt.Printf("  %7s  %10.2f  %10.2f\n", sample.Elapsed.Round(time.Second), sample.Target, sample.Achieved)
+	}
+
+	if sample, ok := result.Saturation(); ok {
+		fmt.Printf("\nFell behind at %v: %.2f of %.2f events/sec targeted\n", sample.Elapsed.Round(time.Second), sample.Achieved, sample.Target)
+	} else {
+		fmt.Println("\nKept up with the target rate throughout")
+	}
+
+	return result, nil
+}
+
+func printTargetRate(result *benchmark.BenchmarkResult) {
+	if result.TargetRate > 0 {
+		fmt.Printf("Target Rate: %.2f (%.1f%% achieved)\n", result.TargetRate, result.EventsPerSecond/result.TargetRate*100)
+	}
+}
+
+func benchHTTPConfig(config benchmark.BenchmarkConfig) benchmark.HTTPConfig {
+	if benchAPIKey == "" {
+		benchAPIKey = os.Getenv("SCOPION_API_KEY")
+	}
+
+	return benchmark.HTTPConfig{
+		BenchmarkConfig:   config,
+		URL:               benchURL,
+		APIKey:            benchAPIKey,
+		Concurrency:       benchConcurrency,
+		DisableKeepAlives: !benchKeepAlive,
+		PayloadBytes:      benchPayloadBytes,
+		Subscribers:       benchSubscribers,
+	}
+}
+
+func runHTTPBenchmark(config benchmark.HTTPConfig) (*benchmark.HTTPResult, error) {
+	generator, err := benchmark.NewHTTPLoadGenerator(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start HTTP benchmark: %w", err)
+	}
+
+	result, err := generator.Run(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("HTTP benchmark failed: %w", err)
+	}
+
+	server := result.URL
+	if config.URL == "" {
+		server = "in process, " + result.Backend.DisplayName()
+	}
+
+	fmt.Println("=== HTTP Ingest Benchmark Results ===")
+	fmt.Printf("Server: %s\n", server)
+	fmt.Printf("Concurrency: %d (keep-alive %t)\n", result.Concurrency, result.KeepAlive)
+	fmt.Printf("Duration: %v\n", result.Duration)
+	fmt.Printf("Accepted: %d, Throttled: %d, Failed: %d\n", result.Accepted, result.Throttled, result.Failed)
+	fmt.Printf("Events/Second: %.2f\n", result.EventsPerSecond)
+	printTargetRate(&result.BenchmarkResult)
+	fmt.Printf("Latency: p50 %v, p90 %v, p99 %v, p99.9 %v, max %v\n",
+		result.P50, result.P90, result.P99, result.P999, result.MaxLatency)
+	if result.Subscribers > 0 {
+		fmt.Printf("Live: %d subscribers received %.1f%% of accepted events, %d dropped streams\n",
+			result.Subscribers, result.LiveDelivery()*100, result.LiveDropped)
+	}
+	fmt.Printf("Memory Usage: %.2f MB\n", result.MemoryUsageMB)
+	if config.URL == "" {
+		fmt.Printf("Database Size: %.2f MB\n", result.DatabaseSizeMB)
+	}
+
+	return result, nil
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"syscall"
@@ -13,8 +14,18 @@ import (
 
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/spf13/cobra"
+	client "github.com/xonoxc/scopion/clients/go"
+	"github.com/xonoxc/scopion/internal/agent"
 	"github.com/xonoxc/scopion/internal/app"
 	"github.com/xonoxc/scopion/internal/benchmark"
+	"github.com/xonoxc/scopion/internal/ingest"
+	"github.com/xonoxc/scopion/internal/live"
+	"github.com/xonoxc/scopion/internal/model"
+	"github.com/xonoxc/scopion/internal/processor"
+	"github.com/xonoxc/scopion/internal/runner"
+	"github.com/xonoxc/scopion/internal/store/migrations"
+	"github.com/xonoxc/scopion/internal/store/sqlite"
+	"github.com/xonoxc/scopion/internal/syslog"
 )
 
 const scorpionArt = `
@@ -62,10 +73,45 @@ Scopion is a single-binary observability tool that collects telemetry data and p
 var (
 	port          string
 	enableDemo    bool
+	syslogUDP     string
+	syslogTCP     string
+	walDir        string
+	queueSize     int
+	requireAuth   bool
+	limits        ingest.LimitConfig
+	validation    ingest.ValidationConfig
+	processorPath string
+	sampling      ingest.SamplingConfig
+	sampleKeep    []string
 	benchWorkers  int
 	benchDuration time.Duration
 	benchRate     int
 	benchOutput   string
+	benchProfile  string
+	benchPeakRate int
+	benchSteps    int
+	benchBackend  []string
+	benchPGDSN    string
+
+	benchURL          string
+	benchAPIKey       string
+	benchConcurrency  int
+	benchKeepAlive    bool
+	benchPayloadBytes int
+	benchSubscribers  int
+
+	runService string
+	runServer  string
+	runDBPath  string
+	runAPIKey  string
+	runTraceID string
+
+	agentConfigPath string
+
+	keysDBPath string
+	keyName    string
+	keyProject string
+	keyScopes  []string
 )
 
 var startCmd = &cobra.Command{
@@ -73,11 +119,42 @@ var startCmd = &cobra.Command{
 	Short: "Start the Scopion server",
 	Long:  `Start the Scopion server with telemetry collection and web UI.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		var processors processor.Config
+		if processorPath != "" {
+			cfg, err := processor.LoadConfig(processorPath)
+			if err != nil {
+				return err
+			}
+			processors = cfg
+		}
+
+		sampling.Rules = nil
+		for _, raw := range sampleKeep {
+			rule, err := parseSampleRule(raw)
+			if err != nil {
+				return err
+			}
+			sampling.Rules = append(sampling.Rules, rule)
+		}
+
 		fmt.Print(scorpionArt)
 		fmt.Println()
 		ctx := context.Background()
 		return app.StartServerWithConfig(ctx, port, app.ServerConfig{
 			Mode: app.DEMO_MODE,
+			Syslog: syslog.Config{
+				UDPAddr: syslogUDP,
+				TCPAddr: syslogTCP,
+			},
+			Ingest: ingest.QueueConfig{
+				Dir:      walDir,
+				Capacity: queueSize,
+				Sampling: sampling,
+			},
+			Auth:       requireAuth,
+			Limits:     limits,
+			Validation: validation,
+			Processors: processors,
 		})
 	},
 }
@@ -94,7 +171,25 @@ var versionCmd = &cobra.Command{
 var benchmarkCmd = &cobra.Command{
 	Use:   "benchmark",
 	Short: "Run database benchmarks and performance tests",
-	Long:  `Run comprehensive benchmarks to test SQLite database limits and performance.`,
+	Long: `Run comprehensive benchmarks to test database limits and performance.
+
+Every benchmark runs against the backends listed in --backend: sqlite, postgres
+or dual (SQLite and Postgres written together, as during a migration). With
+more than one, they are run in turn and compared side by side.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		/*
+		* subcommands share the bench variables but not their defaults,
+		* which would otherwise come from whichever registered last
+		**/
+		for _, name := range []string{"workers", "duration", "rate"} {
+			if f := cmd.Flags().Lookup(name); f != nil && !f.Changed {
+				if err := f.Value.Set(f.DefValue); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	},
 }
 
 var benchStandardCmd = &cobra.Command{
@@ -102,76 +197,89 @@ var benchStandardCmd = &cobra.Command{
 	Short: "Run standard load benchmark",
 	Long:  `Run a standard load benchmark to test database performance under normal conditions.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		config := benchmark.BenchmarkConfig{
-			DatabasePath: "./scopion.db",
-			Duration:     benchDuration,
-			Workers:      benchWorkers,
-			EventRate:    benchRate,
-			BatchSize:    10,
-		}
-
-		runner := benchmark.NewBenchmarkRunner(config)
-		result, err := runner.RunStandardBenchmark()
-		if err != nil {
-			return fmt.Errorf("benchmark failed: %w", err)
-		}
-
-		fmt.Println("=== Standard Benchmark Results ===")
-		fmt.Printf("Duration: %v\n", result.Duration)
-		fmt.Printf("Total Events: %d\n", result.TotalEvents)
-		fmt.Printf("Events/Second: %.2f\n", result.EventsPerSecond)
-		fmt.Printf("Avg Latency: %v\n", result.AvgLatency)
-		fmt.Printf("Memory Usage: %.2f MB\n", result.MemoryUsageMB)
-		fmt.Printf("Database Size: %.2f MB\n", result.DatabaseSizeMB)
-		fmt.Printf("Errors: %d\n", result.ErrorCount)
-
-		if benchOutput != "" {
-			return saveBenchmarkResult(result, benchOutput)
-		}
+		return compareBackends(func(config benchmark.BenchmarkConfig) (*benchmark.BenchmarkResult, any, error) {
+			runner := benchmark.NewBenchmarkRunner(config)
+			result, err := runner.RunStandardBenchmark()
+			if err != nil {
+				return nil, nil, fmt.Errorf("benchmark failed: %w", err)
+			}
 
-		return nil
+			fmt.Println("=== Standard Benchmark Results ===")
+			fmt.Printf("Duration: %v\n", result.Duration)
+			fmt.Printf("Total Events: %d\n", result.TotalEvents)
+			fmt.Printf("Events/Second: %.2f\n", result.EventsPerSecond)
+			printTargetRate(result)
+			fmt.Printf("Avg Latency: %v\n", result.AvgLatency)
+			fmt.Printf("Memory Usage: %.2f MB\n", result.MemoryUsageMB)
+			fmt.Printf("Database Size: %.2f MB\n", result.DatabaseSizeMB)
+			fmt.Printf("Errors: %d\n", result.ErrorCount)
+
+			return result, result, nil
+		})
 	},
 }
 
 var benchStressCmd = &cobra.Command{
 	Use:   "stress",
 	Short: "Run progressive stress test",
-	Long:  `Run a progressive stress test that gradually increases load to find breaking points.`,
+	Long: `Run a progressive stress test that gradually increases load to find breaking points.
+
+With --profile the load follows a single ramp instead, moving the target rate
+from --rate towards --peak-rate over --steps phases of --duration each:
+
+  step    raises the rate in equal steps, one per phase
+  linear  raises the rate evenly over the whole run
+  spike   holds --rate, jumping to --peak-rate for the middle phase`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		config := benchmark.BenchmarkConfig{
-			DatabasePath: "./scopion.db",
-			Duration:     benchDuration,
-			Workers:      benchWorkers,
-			EventRate:    benchRate,
-			BatchSize:    10,
+		var ramp benchmark.RampProfile
+		if benchProfile != "" {
+			kind, err := benchmark.ParseRampKind(benchProfile)
+			if err != nil {
+				return err
+			}
+			ramp = benchmark.RampProfile{Kind: kind, From: benchRate, To: benchPeakRate, Steps: benchSteps}
 		}
 
-		runner := benchmark.NewBenchmarkRunner(config)
-		results, err := runner.RunStressTest()
-		if err != nil {
-			return fmt.Errorf("stress test failed: %w", err)
-		}
+		return compareBackends(func(config benchmark.BenchmarkConfig) (*benchmark.BenchmarkResult, any, error) {
+			if ramp.Enabled() {
+				config.Ramp = ramp
+				config.Duration = benchDuration * time.Duration(benchSteps)
+				result, err := runRampTest(config)
+				return result, result, err
+			}
 
-		fmt.Println("=== Stress Test Results ===")
-		for i, result := range results {
-			fmt.Printf("\nPhase %d:\n", i+1)
-			fmt.Printf("  Events/Second: %.2f\n", result.EventsPerSecond)
-			fmt.Printf("  Memory Usage: %.2f MB\n", result.MemoryUsageMB)
-			fmt.Printf("  Errors: %d\n", result.ErrorCount)
-		}
+			runner := benchmark.NewBenchmarkRunner(config)
+			results, err := runner.RunStressTest()
+			if err != nil {
+				return nil, nil, fmt.Errorf("stress test failed: %w", err)
+			}
+			if len(results) == 0 {
+				return nil, nil, fmt.Errorf("stress test failed: no phase could run")
+			}
+
+			fmt.Println("=== Stress Test Results ===")
+			for i, result := range results {
+				fmt.Printf("\nPhase %d:\n", i+1)
+				fmt.Printf("  Events/Second: %.2f\n", result.EventsPerSecond)
+				if result.TargetRate > 0 {
+					fmt.Printf("  Target Rate: %.2f (%.1f%% achieved)\n", result.TargetRate, result.EventsPerSecond/result.TargetRate*100)
+				}
+				fmt.Printf("  Memory Usage: %.2f MB\n", result.MemoryUsageMB)
+				fmt.Printf("  Errors: %d\n", result.ErrorCount)
+			}
 
-		analyzer := benchmark.NewPerformanceAnalyzer(results)
-		recommendation := analyzer.Analyze()
+			analyzer := benchmark.NewPerformanceAnalyzer(results)
+			recommendation := analyzer.Analyze()
 
-		fmt.Println("\n=== Performance Analysis ===")
-		fmt.Printf("Best Performance: %.2f events/sec\n", recommendation.BestPerformance.EventsPerSecond)
-		fmt.Printf("Worst Performance: %.2f events/sec\n", recommendation.WorstPerformance.EventsPerSecond)
-		fmt.Printf("Average Performance: %.2f events/sec\n", recommendation.AveragePerformance.EventsPerSecond)
-		fmt.Printf("Recommendation: %s\n", recommendation.Recommendation)
-		fmt.Printf("Confidence: %s\n", recommendation.Confidence)
+			fmt.Println("\n=== Performance Analysis ===")
+			fmt.Printf("Best Performance: %.2f events/sec\n", recommendation.BestPerformance.EventsPerSecond)
+			fmt.Printf("Worst Performance: %.2f events/sec\n", recommendation.WorstPerformance.EventsPerSecond)
+			fmt.Printf("Average Performance: %.2f events/sec\n", recommendation.AveragePerformance.EventsPerSecond)
+			fmt.Printf("Recommendation: %s\n", recommendation.Recommendation)
+			fmt.Printf("Confidence: %s\n", recommendation.Confidence)
 
-		if benchOutput != "" {
 			report := &benchmark.DatabaseLimitsReport{
+				Backend:   config.Backend,
 				StartTime: results[0].StartTime,
 				EndTime:   results[len(results)-1].EndTime,
 				Tests:     make([]benchmark.TestResult, len(results)),
@@ -186,39 +294,68 @@ var benchStressCmd = &cobra.Command{
 				}
 			}
 
-			return report.SaveReport(benchOutput)
-		}
-
-		return nil
+			return &recommendation.BestPerformance, report, nil
+		})
 	},
 }
 
 var benchLimitsCmd = &cobra.Command{
 	Use:   "limits",
 	Short: "Test database limits and find breaking points",
-	Long:  `Run comprehensive tests to find SQLite database limits and provide migration recommendations.`,
+	Long:  `Run comprehensive tests to find database limits and provide migration recommendations.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		config := benchmark.BenchmarkConfig{
-			DatabasePath: "./scopion.db",
-			Duration:     benchDuration,
-			Workers:      benchWorkers,
-			EventRate:    benchRate,
-			BatchSize:    10,
-		}
+		return compareBackends(func(config benchmark.BenchmarkConfig) (*benchmark.BenchmarkResult, any, error) {
+			runner := benchmark.NewBenchmarkRunner(config)
+			report, err := runner.RunDatabaseLimitsTest()
+			if err != nil {
+				return nil, nil, fmt.Errorf("limits test failed: %w", err)
+			}
 
-		runner := benchmark.NewBenchmarkRunner(config)
-		report, err := runner.RunDatabaseLimitsTest()
-		if err != nil {
-			return fmt.Errorf("limits test failed: %w", err)
-		}
+			report.PrintReport()
+
+			/*
+			* backends are compared on their best test
+			**/
+			var best *benchmark.BenchmarkResult
+			for _, test := range report.Tests {
+				if test.Success && test.Result != nil && (best == nil || test.Result.EventsPerSecond > best.EventsPerSecond) {
+					best = test.Result
+				}
+			}
+			if best == nil {
+				return nil, report, fmt.Errorf("limits test failed: no test succeeded")
+			}
 
-		report.PrintReport()
+			return best, report, nil
+		})
+	},
+}
 
-		if benchOutput != "" {
-			return report.SaveReport(benchOutput)
+var benchHTTPCmd = &cobra.Command{
+	Use:   "http",
+	Short: "Benchmark ingest end to end over HTTP",
+	Long: `Send events to the /ingest endpoint the way clients do, through JSON decoding,
+the middleware, the ingest queue and live broadcasting, with SSE subscribers
+listening. Runs against --url, or a server started in process on each --backend.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if benchURL != "" {
+			result, err := runHTTPBenchmark(benchHTTPConfig(benchmark.BenchmarkConfig{Duration: benchDuration, EventRate: benchRate}))
+			if err != nil {
+				return err
+			}
+			if benchOutput != "" {
+				return saveBenchmarkResult(result, benchOutput)
+			}
+			return nil
 		}
 
-		return nil
+		return compareBackends(func(config benchmark.BenchmarkConfig) (*benchmark.BenchmarkResult, any, error) {
+			result, err := runHTTPBenchmark(benchHTTPConfig(config))
+			if err != nil {
+				return nil, nil, err
+			}
+			return &result.BenchmarkResult, result, nil
+		})
 	},
 }
 
@@ -227,15 +364,15 @@ var benchMonitorCmd = &cobra.Command{
 	Short: "Run continuous performance monitoring",
 	Long:  `Start continuous monitoring of database performance with periodic benchmarks.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		config := benchmark.BenchmarkConfig{
-			DatabasePath: "./scopion.db",
-			Duration:     benchDuration,
-			Workers:      benchWorkers,
-			EventRate:    benchRate,
-			BatchSize:    10,
+		backends, err := benchBackends()
+		if err != nil {
+			return err
+		}
+		if len(backends) != 1 {
+			return fmt.Errorf("monitor runs against a single backend, got %d", len(backends))
 		}
 
-		monitor := benchmark.NewContinuousMonitor(config, 1*time.Minute)
+		monitor := benchmark.NewContinuousMonitor(benchConfig(backends[0]), 1*time.Minute)
 
 		fmt.Println("Starting continuous performance monitoring...")
 		fmt.Println("Press Ctrl+C to stop")
@@ -266,7 +403,96 @@ var benchMonitorCmd = &cobra.Command{
 	},
 }
 
-func saveBenchmarkResult(result *benchmark.BenchmarkResult, filename string) error {
+var runCmd = &cobra.Command{
+	Use:   "run [flags] -- command [args...]",
+	Short: "Run a command and ship its output as events",
+	Long: `Run a command, capturing stdout and stderr line by line as events.
+
+Start and exit events carrying the exit code and duration are emitted under a
+single trace ID. Stderr lines are recorded at error level and JSON lines are
+parsed into the event data. Events are sent to a Scopion server, or written
+straight into a local database when --db is set.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+
+		var sink runner.Sink
+		if runDBPath != "" {
+			if err := migrations.MigrateSqlite(runDBPath); err != nil {
+				return fmt.Errorf("failed to prepare database: %w", err)
+			}
+			s, err := sqlite.New(runDBPath)
+			if err != nil {
+				return fmt.Errorf("failed to open store: %w", err)
+			}
+			defer s.Close()
+			sink = runner.NewStoreSink(s)
+		} else {
+			if runAPIKey == "" {
+				runAPIKey = os.Getenv("SCOPION_API_KEY")
+			}
+			sink = runner.NewClientSink(client.NewClientWithKey(runServer, runAPIKey))
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+
+		result, err := runner.Run(ctx, runner.Config{
+			Service: runService,
+			TraceID: runTraceID,
+			Command: args[0],
+			Args:    args[1:],
+			Stdin:   os.Stdin,
+			Stdout:  os.Stdout,
+			Stderr:  os.Stderr,
+		}, sink)
+		if err != nil {
+			return err
+		}
+
+		if result.ExitCode != 0 {
+			code := result.ExitCode
+			if code < 0 {
+				code = 1
+			}
+			os.Exit(code)
+		}
+
+		return nil
+	},
+}
+
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Tail log files and forward them to a Scopion server",
+	Long: `Run as an agent that tails the log files listed in a JSON config file.
+
+Each line is parsed with the configured parser (json, logfmt or regex with
+named groups) and forwarded in batches. Read offsets survive restarts, rotated
+and truncated files are followed, and events are buffered on disk while the
+server is unreachable.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+
+		cfg, err := agent.LoadConfig(agentConfigPath)
+		if err != nil {
+			return err
+		}
+
+		a, err := agent.New(cfg, runner.NewClientSink(client.NewClientWithKey(cfg.Server, cfg.APIKey)))
+		if err != nil {
+			return fmt.Errorf("failed to start agent: %w", err)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+
+		log.Printf("Agent forwarding %d file sources to %s", len(cfg.Files), cfg.Server)
+		return a.Run(ctx)
+	},
+}
+
+func saveBenchmarkResult(result any, filename string) error {
 	file, err := os.Create(filename)
 	if err != nil {
 		return fmt.Errorf("failed to create output file: %w", err)
@@ -278,37 +504,127 @@ func saveBenchmarkResult(result *benchmark.BenchmarkResult, filename string) err
 	return encoder.Encode(result)
 }
 
+/*
+* sampling rules use the live filter syntax, service=api&level=warn
+**/
+func parseSampleRule(raw string) (live.Filter, error) {
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		return live.Filter{}, fmt.Errorf("invalid --sample-keep %q: %w", raw, err)
+	}
+
+	rule, err := live.ParseFilter(values)
+	if err != nil {
+		return live.Filter{}, fmt.Errorf("invalid --sample-keep %q: %w", raw, err)
+	}
+	if rule.SampleRate > 0 {
+		return live.Filter{}, fmt.Errorf("invalid --sample-keep %q: sample is not allowed in rules", raw)
+	}
+
+	return rule, nil
+}
+
 func init() {
 	startCmd.Flags().StringVarP(&port, "port", "p", "8080", "Port to run the server on")
 	startCmd.Flags().BoolVar(&enableDemo, "demo", true, "Enable demo data generation")
+	startCmd.Flags().StringVar(&syslogUDP, "syslog-udp", "", "Address to receive syslog over UDP (e.g. :5514)")
+	startCmd.Flags().StringVar(&syslogTCP, "syslog-tcp", "", "Address to receive syslog over TCP (e.g. :5514)")
+	startCmd.Flags().BoolVar(&requireAuth, "auth", false, "Require API keys for ingest and the read APIs (see scopion keys)")
+	startCmd.Flags().StringVar(&walDir, "wal-dir", ingest.DEFAULT_QUEUE_DIR, "Directory for the ingest write-ahead log")
+	startCmd.Flags().IntVar(&queueSize, "queue-size", 10000, "Events accepted but not yet stored before ingest returns 429")
+	startCmd.Flags().Float64Var(&limits.Service.Rate, "service-rate", 0, "Events per second each service may ingest (0 for unlimited)")
+	startCmd.Flags().IntVar(&limits.Service.Burst, "service-burst", 0, "Events a service may send at once above its rate (defaults to the rate)")
+	startCmd.Flags().Int64Var(&limits.Service.DailyQuota, "service-daily-quota", 0, "Events each service may ingest per UTC day (0 for unlimited)")
+	startCmd.Flags().Float64Var(&limits.Key.Rate, "key-rate", 0, "Events per second each API key may ingest (0 for unlimited)")
+	startCmd.Flags().IntVar(&limits.Key.Burst, "key-burst", 0, "Events an API key may send at once above its rate (defaults to the rate)")
+	startCmd.Flags().Int64Var(&limits.Key.DailyQuota, "key-daily-quota", 0, "Events each API key may ingest per UTC day (0 for unlimited)")
+	startCmd.Flags().Int64Var(&validation.MaxEventBytes, "max-event-bytes", 256<<10, "Largest ingest request body accepted")
+	startCmd.Flags().Int64Var(&validation.MaxBatchBytes, "max-batch-bytes", 4<<20, "Largest batch ingest request body accepted")
+	startCmd.Flags().IntVar(&validation.MaxBatchEvents, "max-batch-events", 1000, "Most events a batch ingest request may hold")
+	startCmd.Flags().IntVar(&validation.MaxAttributes, "max-attributes", 128, "Most keys an event's data may hold, nested objects included")
+	startCmd.Flags().IntVar(&validation.MaxKeyLength, "max-key-length", 128, "Longest key allowed in an event's data")
+	startCmd.Flags().Float64Var(&validation.MinSampleRate, "min-sample-rate", 0.001, "Lowest sample_rate a client may send, bounding how many events one stored event stands for in stats")
+	startCmd.Flags().DurationVar(&validation.TimestampSkew, "timestamp-skew", 0, "Keep client timestamps within this distance of server time (0 always uses server time)")
+	startCmd.Flags().Float64Var(&sampling.SampleRate, "sample-rate", 0, "Fraction of uninteresting traces to store, traces with errors, slow traces and --sample-keep matches are always stored (0 stores everything)")
+	startCmd.Flags().DurationVar(&sampling.Window, "sample-window", 10*time.Second, "How long events of a trace are held before it is sampled")
+	startCmd.Flags().DurationVar(&sampling.LatencyThreshold, "sample-latency", 0, "Always store traces slower than this (0 disables)")
+	startCmd.Flags().StringArrayVar(&sampleKeep, "sample-keep", nil, "Always store traces with an event matching this live filter, e.g. \"service=checkout&level=warn\" (repeatable)")
+	startCmd.Flags().StringVar(&processorPath, "processors", "", "JSON file configuring ingest processors such as redaction rules")
 
 	benchStandardCmd.Flags().IntVarP(&benchWorkers, "workers", "w", 10, "Number of concurrent workers")
 	benchStandardCmd.Flags().DurationVarP(&benchDuration, "duration", "d", 30*time.Second, "Benchmark duration")
 	benchStandardCmd.Flags().IntVarP(&benchRate, "rate", "r", 0, "Target events per second (0 for unlimited)")
 	benchStandardCmd.Flags().StringVarP(&benchOutput, "output", "o", "", "Output file for results (JSON)")
+	benchStandardCmd.Flags().StringSliceVar(&benchBackend, "backend", []string{"sqlite"}, "Backends to benchmark and compare: sqlite, postgres, dual")
+	benchStandardCmd.Flags().StringVar(&benchPGDSN, "postgres-dsn", "", "Postgres to benchmark for the postgres and dual backends (defaults to $SCOPION_POSTGRES_DSN)")
 
 	benchStressCmd.Flags().IntVarP(&benchWorkers, "workers", "w", 10, "Starting number of concurrent workers")
 	benchStressCmd.Flags().DurationVarP(&benchDuration, "duration", "d", 30*time.Second, "Duration per stress phase")
-	benchStressCmd.Flags().IntVarP(&benchRate, "rate", "r", 0, "Starting target events per second")
+	benchStressCmd.Flags().IntVarP(&benchRate, "rate", "r", 0, "Starting target events per second, scaling every phase")
+	benchStressCmd.Flags().StringVar(&benchProfile, "profile", "", "Follow a single ramp instead of the phases: step, linear or spike")
+	benchStressCmd.Flags().IntVar(&benchPeakRate, "peak-rate", 0, "Events per second the ramp ends at, or spikes to")
+	benchStressCmd.Flags().IntVar(&benchSteps, "steps", 5, "Phases of --duration the ramp lasts")
 	benchStressCmd.Flags().StringVarP(&benchOutput, "output", "o", "", "Output file for results (JSON)")
+	benchStressCmd.Flags().StringSliceVar(&benchBackend, "backend", []string{"sqlite"}, "Backends to benchmark and compare: sqlite, postgres, dual")
+	benchStressCmd.Flags().StringVar(&benchPGDSN, "postgres-dsn", "", "Postgres to benchmark for the postgres and dual backends (defaults to $SCOPION_POSTGRES_DSN)")
 
 	benchLimitsCmd.Flags().IntVarP(&benchWorkers, "workers", "w", 10, "Starting number of concurrent workers")
 	benchLimitsCmd.Flags().DurationVarP(&benchDuration, "duration", "d", 30*time.Second, "Duration per test phase")
 	benchLimitsCmd.Flags().IntVarP(&benchRate, "rate", "r", 0, "Starting target events per second")
 	benchLimitsCmd.Flags().StringVarP(&benchOutput, "output", "o", "", "Output file for results (JSON)")
+	benchLimitsCmd.Flags().StringSliceVar(&benchBackend, "backend", []string{"sqlite"}, "Backends to benchmark and compare: sqlite, postgres, dual")
+	benchLimitsCmd.Flags().StringVar(&benchPGDSN, "postgres-dsn", "", "Postgres to benchmark for the postgres and dual backends (defaults to $SCOPION_POSTGRES_DSN)")
 
 	benchMonitorCmd.Flags().IntVarP(&benchWorkers, "workers", "w", 5, "Number of workers for monitoring tests")
 	benchMonitorCmd.Flags().DurationVarP(&benchDuration, "duration", "d", 10*time.Second, "Duration per monitoring test")
 	benchMonitorCmd.Flags().IntVarP(&benchRate, "rate", "r", 100, "Target events per second for monitoring")
+	benchMonitorCmd.Flags().StringSliceVar(&benchBackend, "backend", []string{"sqlite"}, "Backend to monitor: sqlite, postgres or dual")
+	benchMonitorCmd.Flags().StringVar(&benchPGDSN, "postgres-dsn", "", "Postgres to benchmark for the postgres and dual backends (defaults to $SCOPION_POSTGRES_DSN)")
+
+	benchHTTPCmd.Flags().StringVar(&benchURL, "url", "", "Server to benchmark, e.g. http://localhost:8080 (starts one in process when empty)")
+	benchHTTPCmd.Flags().StringVar(&benchAPIKey, "api-key", "", "Ingest API key for servers running with --auth (defaults to $SCOPION_API_KEY)")
+	benchHTTPCmd.Flags().IntVarP(&benchConcurrency, "concurrency", "c", 10, "Requests in flight at once")
+	benchHTTPCmd.Flags().BoolVar(&benchKeepAlive, "keep-alive", true, "Reuse connections between requests")
+	benchHTTPCmd.Flags().IntVar(&benchPayloadBytes, "payload-bytes", 0, "Pad events to about this many bytes of JSON (0 leaves them as they are)")
+	benchHTTPCmd.Flags().IntVar(&benchSubscribers, "subscribers", 0, "Live SSE subscribers listening during the run")
+	benchHTTPCmd.Flags().DurationVarP(&benchDuration, "duration", "d", 30*time.Second, "Benchmark duration")
+	benchHTTPCmd.Flags().IntVarP(&benchRate, "rate", "r", 0, "Target events per second (0 for unlimited)")
+	benchHTTPCmd.Flags().StringVarP(&benchOutput, "output", "o", "", "Output file for results (JSON)")
+	benchHTTPCmd.Flags().StringSliceVar(&benchBackend, "backend", []string{"sqlite"}, "Backends to start the in process server on and compare: sqlite, postgres, dual")
+	benchHTTPCmd.Flags().StringVar(&benchPGDSN, "postgres-dsn", "", "Postgres to benchmark for the postgres and dual backends (defaults to $SCOPION_POSTGRES_DSN)")
+
+	runCmd.Flags().SetInterspersed(false)
+	runCmd.Flags().StringVarP(&runService, "service", "s", "", "Service name to record events under")
+	runCmd.Flags().StringVar(&runServer, "server", "http://localhost:8080", "Scopion server to send events to")
+	runCmd.Flags().StringVar(&runDBPath, "db", "", "Write events straight into this local database instead of a server")
+	runCmd.Flags().StringVar(&runAPIKey, "api-key", "", "Ingest API key for servers running with --auth (defaults to $SCOPION_API_KEY)")
+	runCmd.Flags().StringVar(&runTraceID, "trace-id", "", "Trace ID to use (generated when empty)")
+	runCmd.MarkFlagRequired("service")
+
+	agentCmd.Flags().StringVarP(&agentConfigPath, "config", "c", "./scopion-agent.json", "Path to the agent config file")
+
+	keysCmd.PersistentFlags().StringVar(&keysDBPath, "db", "./scopion.db", "Database the server stores its keys in")
+	keysCreateCmd.Flags().StringVar(&keyName, "name", "", "Name to recognise the key by")
+	keysCreateCmd.Flags().StringVar(&keyProject, "project", model.DEFAULT_PROJECT, "Project the key belongs to")
+	keysCreateCmd.Flags().StringSliceVar(&keyScopes, "scope", []string{string(model.SCOPE_INGEST)}, "Scopes to grant: ingest, read")
+	keysCreateCmd.MarkFlagRequired("name")
+
+	keysCmd.AddCommand(keysCreateCmd)
+	keysCmd.AddCommand(keysListCmd)
+	keysCmd.AddCommand(keysRevokeCmd)
 
 	benchmarkCmd.AddCommand(benchStandardCmd)
 	benchmarkCmd.AddCommand(benchStressCmd)
 	benchmarkCmd.AddCommand(benchLimitsCmd)
 	benchmarkCmd.AddCommand(benchMonitorCmd)
+	benchmarkCmd.AddCommand(benchHTTPCmd)
 
 	rootCmd.AddCommand(startCmd)
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(benchmarkCmd)
+	rootCmd.AddCommand(runCmd)
+	rootCmd.AddCommand(agentCmd)
+	rootCmd.AddCommand(keysCmd)
 }
 
 func Execute() error {
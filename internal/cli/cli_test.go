@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/spf13/cobra"
 	"github.com/xonoxc/scopion/internal/app"
 )
 
@@ -144,3 +145,21 @@ func TestCommandFlags(t *testing.T) {
 		t.Error("Expected 'demo' flag to be defined")
 	}
 }
+
+func TestBenchmarkFlagDefaults(t *testing.T) {
+	for _, tc := range []struct {
+		cmd     *cobra.Command
+		workers int
+		rate    int
+	}{
+		{cmd: benchStandardCmd, workers: 10, rate: 0},
+		{cmd: benchMonitorCmd, workers: 5, rate: 100},
+	} {
+		if err := benchmarkCmd.PersistentPreRunE(tc.cmd, nil); err != nil {
+			t.Fatal(err)
+		}
+		if benchWorkers != tc.workers || benchRate != tc.rate {
+			t.Errorf("%s: expected %d workers at %d/sec, got %d at %d/sec", tc.cmd.Name(), tc.workers, tc.rate, benchWorkers, benchRate)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/xonoxc/scopion/internal/auth"
+	"github.com/xonoxc/scopion/internal/model"
+	"github.com/xonoxc/scopion/internal/store/migrations"
+	"github.com/xonoxc/scopion/internal/store/sqlite"
+
+	migrateable "github.com/xonoxc/scopion/internal/store/migratable"
+)
+
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Manage API keys",
+	Long: `Create, list and revoke the API keys a server started with --auth accepts.
+
+Keys carry the ingest scope (may send events) and/or the read scope (may query
+the API). Only a hash of each key is stored, the key itself is shown once when
+it is created.`,
+}
+
+var keysCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create an API key",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+
+		scopes, err := auth.ParseScopes(keyScopes)
+		if err != nil {
+			return err
+		}
+
+		key, plain, err := auth.NewAPIKey(keyName, keyProject, scopes)
+		if err != nil {
+			return err
+		}
+
+		s, err := openKeyStore()
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		if err := s.CreateAPIKey(key); err != nil {
+			return err
+		}
+
+		fmt.Printf("Created key %s (%s) for project %s\n", key.ID, key.Name, key.Project)
+		fmt.Println("Store it now, it will not be shown again:")
+		fmt.Println()
+		fmt.Println(plain)
+		return nil
+	},
+}
+
+var keysListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List API keys",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+
+		s, err := openKeyStore()
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		keys, err := s.ListAPIKeys()
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tPROJECT\tKEY\tSCOPES\tCREATED\tSTATUS")
+		for _, k := range keys {
+			scopes := make([]string, len(k.Scopes))
+			for i, scope := range k.Scopes {
+				scopes[i] = string(scope)
+			}
+
+			status := "active"
+			if k.Revoked() {
+				status = "revoked " + k.RevokedAt.Format(time.DateTime)
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s...\t%s\t%s\t%s\n",
+				k.ID, k.Name, k.Project, k.Prefix, strings.Join(scopes, ","), k.CreatedAt.Format(time.DateTime), status)
+		}
+		return w.Flush()
+	},
+}
+
+var keysRevokeCmd = &cobra.Command{
+	Use:   "revoke <id>",
+	Short: "Revoke an API key",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+
+		s, err := openKeyStore()
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+
+		if err := s.RevokeAPIKey(args[0]); err != nil {
+			if errors.Is(err, model.ErrAPIKeyNotFound) {
+				return fmt.Errorf("no active key with id %s", args[0])
+			}
+			return err
+		}
+
+		fmt.Printf("Revoked key %s\n", args[0])
+		return nil
+	},
+}
+
+func openKeyStore() (*sqlite.SqliteStore, error) {
+	if err := migrations.New(keysDBPath).Migrate(migrateable.SQLITE, migrations.Idempotent()); err != nil {
+		return nil, fmt.Errorf("failed to prepare key table: %w", err)
+	}
+
+	s, err := sqlite.New(keysDBPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open store: %w", err)
+	}
+	return s, nil
+}
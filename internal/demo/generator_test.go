@@ -7,6 +7,7 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/pressly/goose/v3"
 	"github.com/xonoxc/scopion/internal/live"
+	"github.com/xonoxc/scopion/internal/model"
 	"github.com/xonoxc/scopion/internal/store/sqlite"
 )
 
@@ -57,7 +58,7 @@ func TestEmitWithCustomData(t *testing.T) {
 	// Emit an event - it should have custom data
 	emit(s, b, "api", "GET /users", "trace123", "info")
 
-	events, err := s.Recent(10)
+	events, err := s.Recent(model.DEFAULT_PROJECT, 10)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -94,7 +95,7 @@ func TestHistoricalDataGeneration(t *testing.T) {
 
 	generateHistoricalData(s)
 
-	events, err := s.Recent(300) // Should have generated 200 events
+	events, err := s.Recent(model.DEFAULT_PROJECT, 300) // Should have generated 200 events
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -2,29 +2,275 @@ package ingest
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
-	"github.com/xonoxc/scopion/internal/live"
+	"github.com/xonoxc/scopion/internal/api/httpx"
+	"github.com/xonoxc/scopion/internal/api/middleware"
 	"github.com/xonoxc/scopion/internal/model"
-	"github.com/xonoxc/scopion/internal/store"
+	"github.com/xonoxc/scopion/internal/processor"
 
 	"github.com/google/uuid"
 )
 
-func Handler(store store.Storage, live *live.Broadcaster) http.HandlerFunc {
+/*
+* clients retrying an ingest send the same key (or event id),
+* the store keeps only the first event with that id
+**/
+const IDEMPOTENCY_HEADER = "Idempotency-Key"
+
+/*
+* Pipeline is what an event passes through on its way from the
+* request to the queue. a nil limiter allows everything
+**/
+type Pipeline struct {
+	Validation ValidationConfig
+	Processors processor.Chain
+	Limiter    *Limiter
+}
+
+/*
+* Handler accepts an event into the ingest queue. events are
+* normalized first, any that break a validation rule are answered
+* 400 listing every violation. then the processors run, and a full
+* queue or a rate limit or daily quota running out answers 429
+* with Retry-After, the event is not kept. an event the queue
+* rejects is refunded to the limits it was charged against.
+* the project comes from the api key or project header, never the body
+**/
+func Handler(q *Queue, p Pipeline) http.HandlerFunc {
+	v := p.Validation
+	v.applyDefaults()
+
 	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, v.MaxEventBytes)
+
 		var e model.Event
 		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
-			http.Error(w, err.Error(), 400)
+			writeBodyError(w, err)
 			return
 		}
-		e.ID = uuid.NewString()
-		e.Timestamp = time.Now()
 
-		store.Append(e)
-		live.Publish(e)
+		var violations []Violation
+		if key := r.Header.Get(IDEMPOTENCY_HEADER); key != "" {
+			if e.ID != "" && e.ID != key {
+				violations = append(violations, Violation{Field: "id", Message: "does not match the " + IDEMPOTENCY_HEADER + " header"})
+			}
+			e.ID = key
+		}
+
+		a := admit(q, p, v, e, newSource(r), middleware.ProjectFromRequest(r), violations)
+		if len(a.violations) > 0 {
+			writeViolations(w, a.status, a.violations...)
+			return
+		}
 
+		writeQuotaHeaders(w, a.decision)
+		if a.status != http.StatusAccepted {
+			if a.retryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(a.retryAfter))
+			}
+			http.Error(w, a.message, a.status)
+			return
+		}
 		w.WriteHeader(http.StatusAccepted)
 	}
 }
+
+/*
+* BatchHandler accepts a JSON array of events, each taking the same
+* path as one sent to Handler. once the body is read the request is
+* answered 202, listing the events that weren't accepted with the
+* status Handler would have given them. retries are made safe by
+* the ids in the events, there is no Idempotency-Key for a batch
+**/
+func BatchHandler(q *Queue, p Pipeline) http.HandlerFunc {
+	v := p.Validation
+	v.applyDefaults()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, v.MaxBatchBytes)
+
+		var bodies []json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&bodies); err != nil {
+			writeBodyError(w, err)
+			return
+		}
+		if len(bodies) > v.MaxBatchEvents {
+			writeViolations(w, http.StatusRequestEntityTooLarge, Violation{
+				Field:   "body",
+				Message: fmt.Sprintf("holds more than %d events", v.MaxBatchEvents),
+			})
+			return
+		}
+
+		src := newSource(r)
+		project := middleware.ProjectFromRequest(r)
+
+		result := model.BatchResult{Rejected: []model.BatchRejection{}}
+		var quota Decision
+		for i, body := range bodies {
+			var a admission
+			var e model.Event
+			switch {
+			case int64(len(body)) > v.MaxEventBytes:
+				a = admission{status: http.StatusRequestEntityTooLarge, message: "invalid event", violations: []Violation{{
+					Field:   "body",
+					Message: fmt.Sprintf("is larger than %d bytes", v.MaxEventBytes),
+				}}}
+			case json.Unmarshal(body, &e) != nil:
+				a = admission{status: http.StatusBadRequest, message: "invalid event", violations: []Violation{{
+					Field:   "body",
+					Message: "is not an event object",
+				}}}
+			default:
+				a = admit(q, p, v, e, src, project, nil)
+			}
+
+			if a.decision.QuotaLimit > 0 {
+				quota = a.decision
+			}
+			if a.status == http.StatusAccepted {
+				result.Accepted++
+				continue
+			}
+			result.Rejected = append(result.Rejected, model.BatchRejection{
+				Index:      i,
+				Status:     a.status,
+				Error:      a.message,
+				Violations: batchViolations(a.violations),
+				RetryAfter: a.retryAfter,
+			})
+		}
+
+		writeQuotaHeaders(w, quota)
+		httpx.WriteJSON(w, http.StatusAccepted, result)
+	}
+}
+
+func batchViolations(violations []Violation) []model.Violation {
+	var out []model.Violation
+	for _, v := range violations {
+		out = append(out, model.Violation(v))
+	}
+	return out
+}
+
+/*
+* what became of one event, status is what a request holding
+* only that event is answered with
+**/
+type admission struct {
+	status     int
+	message    string
+	violations []Violation
+
+	/*
+	* of the limiter, zero when the event didn't get that far
+	**/
+	decision Decision
+
+	/*
+	* seconds, set when the event may be sent again later
+	**/
+	retryAfter int
+}
+
+/*
+* takes e from normalizing to the queue, violations found
+* before it are reported along with those of Normalize
+**/
+func admit(q *Queue, p Pipeline, v ValidationConfig, e model.Event, src processor.Source, project string, violations []Violation) admission {
+	src.ClientTime = e.Timestamp
+
+	violations = append(violations, v.Normalize(&e, src.ReceivedAt)...)
+	if len(violations) > 0 {
+		return admission{status: http.StatusBadRequest, message: "invalid event", violations: violations}
+	}
+	if e.ID == "" {
+		e.ID = uuid.NewString()
+	}
+	e.Project = project
+
+	p.Processors.Process(&e, src)
+
+	d := p.Limiter.Allow(e.Project, e.Service, src.Key)
+	if !d.Allowed {
+		return admission{status: http.StatusTooManyRequests, message: d.Reason, decision: d, retryAfter: d.retryAfterSeconds()}
+	}
+
+	if err := q.Enqueue(e); err != nil {
+		p.Limiter.Refund(e.Project, e.Service, src.Key)
+		if d.QuotaLimit > 0 {
+			d.QuotaRemaining++
+		}
+
+		a := admission{message: err.Error(), decision: d}
+		switch {
+		case errors.Is(err, ErrQueueFull):
+			a.status = http.StatusTooManyRequests
+			a.retryAfter = q.RetryAfter()
+		case errors.Is(err, ErrQueueClosed):
+			a.status = http.StatusServiceUnavailable
+		default:
+			a.status = http.StatusInternalServerError
+		}
+		return a
+	}
+
+	return admission{status: http.StatusAccepted, decision: d}
+}
+
+/*
+* the request as processors see it, ClientTime is set per event
+**/
+func newSource(r *http.Request) processor.Source {
+	src := processor.Source{
+		ReceivedAt:   time.Now(),
+		RemoteAddr:   r.RemoteAddr,
+		ForwardedFor: r.Header.Get("X-Forwarded-For"),
+		UserAgent:    r.UserAgent(),
+	}
+	src.Key, _ = middleware.APIKeyFromContext(r.Context())
+	return src
+}
+
+/*
+* answers a body that couldn't be decoded, 413 when it was too large
+**/
+func writeBodyError(w http.ResponseWriter, err error) {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		writeViolations(w, http.StatusRequestEntityTooLarge, Violation{
+			Field:   "body",
+			Message: fmt.Sprintf("is larger than %d bytes", maxErr.Limit),
+		})
+		return
+	}
+	writeViolations(w, http.StatusBadRequest, Violation{Field: "body", Message: err.Error()})
+}
+
+func writeViolations(w http.ResponseWriter, status int, violations ...Violation) {
+	httpx.WriteJSON(w, status, ValidationError{
+		Error:      "invalid event",
+		Violations: violations,
+	})
+}
+
+/*
+* reports the queue depth, whether the store is currently
+* accepting writes, how many events limits have dropped in
+* the caller's project and the counts kept by processors
+**/
+func StatusHandler(q *Queue, p Pipeline) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		httpx.WriteJSON(w, http.StatusOK, struct {
+			QueueStats
+			Throttled  []ThrottleStats              `json:"throttled"`
+			Processors map[string]map[string]uint64 `json:"processors"`
+		}{q.Stats(), p.Limiter.Stats(middleware.ProjectFromRequest(r)), p.Processors.Stats()})
+	}
+}
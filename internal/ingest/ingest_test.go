@@ -9,6 +9,7 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/pressly/goose/v3"
 	"github.com/xonoxc/scopion/internal/live"
+	"github.com/xonoxc/scopion/internal/model"
 	"github.com/xonoxc/scopion/internal/store/sqlite"
 )
 
@@ -29,7 +30,8 @@ func TestHandler(t *testing.T) {
 	s := sqlite.NewWithDB(db)
 	b := live.New()
 
-	handler := Handler(s, b)
+	q := newTestQueue(t, s, b)
+	handler := Handler(q, Pipeline{})
 
 	req := httptest.NewRequest("POST", "/ingest", strings.NewReader(`{"level":"info","service":"test","name":"event"}`))
 	req.Header.Set("Content-Type", "application/json")
@@ -40,8 +42,9 @@ func TestHandler(t *testing.T) {
 	if w.Code != 202 {
 		t.Errorf("Expected status 202, got %d", w.Code)
 	}
+	q.Close()
 
-	events, err := s.Recent(10)
+	events, err := s.Recent(model.DEFAULT_PROJECT, 10)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -67,7 +70,8 @@ func TestHandlerWithCustomData(t *testing.T) {
 	s := sqlite.NewWithDB(db)
 	b := live.New()
 
-	handler := Handler(s, b)
+	q := newTestQueue(t, s, b)
+	handler := Handler(q, Pipeline{})
 
 	customData := `{
 		"level": "info",
@@ -94,8 +98,9 @@ func TestHandlerWithCustomData(t *testing.T) {
 	if w.Code != 202 {
 		t.Errorf("Expected status 202, got %d", w.Code)
 	}
+	q.Close()
 
-	events, err := s.Recent(10)
+	events, err := s.Recent(model.DEFAULT_PROJECT, 10)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,364 @@
+package ingest
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/xonoxc/scopion/internal/model"
+)
+
+const (
+	/*
+	* service internal warnings are recorded under
+	**/
+	INTERNAL_SERVICE = "scopion"
+
+	/*
+	* how often a throttled subject is reported, a service stuck
+	* in a retry loop should not flood the store with warnings
+	**/
+	throttleWarnInterval = time.Minute
+
+	/*
+	* buckets idle for longer are forgotten once there are this many
+	**/
+	maxBuckets = 10000
+)
+
+/*
+* Limit for one service or api key. zero Rate or
+* DailyQuota leaves that part unlimited
+**/
+type Limit struct {
+	/*
+	* sustained events per second, Burst of them may arrive at once
+	**/
+	Rate  float64
+	Burst int
+
+	/*
+	* events per UTC day
+	**/
+	DailyQuota int64
+}
+
+func (l Limit) enabled() bool {
+	return l.Rate > 0 || l.DailyQuota > 0
+}
+
+/*
+* services are limited per project, keys on their own
+**/
+type LimitConfig struct {
+	Service Limit
+	Key     Limit
+}
+
+func (c LimitConfig) Enabled() bool {
+	return c.Service.enabled() || c.Key.enabled()
+}
+
+/*
+* outcome of a limit check, the quota fields describe the
+* tightest daily quota that applied, QuotaLimit 0 when none did
+**/
+type Decision struct {
+	Allowed    bool
+	Reason     string
+	RetryAfter time.Duration
+
+	QuotaLimit     int64
+	QuotaRemaining int64
+	QuotaReset     time.Time
+}
+
+type ThrottleStats struct {
+	Subject string `json:"subject"`
+	Dropped uint64 `json:"dropped"`
+}
+
+type bucket struct {
+	limit Limit
+
+	/*
+	* project the subject belongs to, stats are only shown to it
+	**/
+	project string
+
+	tokens   float64
+	lastFill time.Time
+
+	day  string
+	used int64
+
+	dropped    uint64
+	lastWarned time.Time
+	lastSeen   time.Time
+}
+
+/*
+* Limiter applies token bucket rate limits and daily quotas to
+* ingest. the first time a subject is throttled (and then at most
+* once a minute) warn receives an internal warning event
+**/
+type Limiter struct {
+	cfg  LimitConfig
+	warn func(model.Event)
+	now  func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+func NewLimiter(cfg LimitConfig, warn func(model.Event)) *Limiter {
+	return &Limiter{
+		cfg:     cfg,
+		warn:    warn,
+		now:     time.Now,
+		buckets: map[string]*bucket{},
+	}
+}
+
+/*
+* Allow checks the event against the limits of its service and of
+* the key that sent it (nil when unauthenticated). the event counts
+* against every limit only when all of them allow it
+**/
+func (l *Limiter) Allow(project, service string, key *model.APIKey) Decision {
+	if l == nil || !l.cfg.Enabled() {
+		return Decision{Allowed: true}
+	}
+
+	var warning *model.Event
+	defer func() {
+		if warning != nil && l.warn != nil {
+			l.warn(*warning)
+		}
+	}()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.prune(now)
+
+	subjects := l.subjects(project, service, key, now)
+
+	d := Decision{Allowed: true}
+	for _, s := range subjects {
+		s.b.refill(now)
+		s.b.quota(&d, now)
+
+		reason, wait := s.b.check(now)
+		if reason == "" {
+			continue
+		}
+
+		d.Allowed = false
+		d.Reason = s.name + " " + reason
+		d.RetryAfter = max(d.RetryAfter, wait)
+
+		s.b.dropped++
+		if now.Sub(s.b.lastWarned) >= throttleWarnInterval {
+			s.b.lastWarned = now
+			warning = &model.Event{
+				Project: project,
+				Level:   "warn",
+				Service: INTERNAL_SERVICE,
+				Name:    "ingest throttled",
+				Data: map[string]any{
+					"subject": s.name,
+					"service": service,
+					"reason":  reason,
+					"dropped": s.b.dropped,
+				},
+			}
+		}
+	}
+
+	if !d.Allowed {
+		return d
+	}
+
+	for _, s := range subjects {
+		s.b.tokens--
+		s.b.used++
+	}
+	if d.QuotaLimit > 0 {
+		d.QuotaRemaining--
+	}
+
+	return d
+}
+
+/*
+* Refund gives back what an allowed event took from its limits,
+* for an event that was then not kept after all
+**/
+func (l *Limiter) Refund(project, service string, key *model.APIKey) {
+	if l == nil || !l.cfg.Enabled() {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	for _, s := range l.subjects(project, service, key, now) {
+		s.b.refill(now)
+		s.b.tokens = min(float64(burstOf(s.b.limit)), s.b.tokens+1)
+		s.b.used = max(0, s.b.used-1)
+	}
+}
+
+type subject struct {
+	name string
+	b    *bucket
+}
+
+/*
+* the limits an event counts against, its service and the key that sent it
+**/
+func (l *Limiter) subjects(project, service string, key *model.APIKey, now time.Time) []subject {
+	var subjects []subject
+
+	if l.cfg.Service.enabled() {
+		name := "service " + project + "/" + service
+		subjects = append(subjects, subject{name, l.bucket(name, project, l.cfg.Service, now)})
+	}
+	if key != nil && l.cfg.Key.enabled() {
+		name := "key " + key.Name + " (" + key.ID + ")"
+		subjects = append(subjects, subject{name, l.bucket(name, model.ProjectOrDefault(key.Project), l.cfg.Key, now)})
+	}
+
+	return subjects
+}
+
+/*
+* subjects of project that have had events dropped, most dropped first
+**/
+func (l *Limiter) Stats(project string) []ThrottleStats {
+	stats := []ThrottleStats{}
+	if l == nil {
+		return stats
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for name, b := range l.buckets {
+		if b.dropped > 0 && b.project == project {
+			stats = append(stats, ThrottleStats{Subject: name, Dropped: b.dropped})
+		}
+	}
+	slices.SortFunc(stats, func(a, b ThrottleStats) int {
+		return cmp.Compare(b.Dropped, a.Dropped)
+	})
+
+	return stats
+}
+
+func (l *Limiter) bucket(name, project string, limit Limit, now time.Time) *bucket {
+	b, ok := l.buckets[name]
+	if !ok {
+		b = &bucket{
+			limit:    limit,
+			project:  project,
+			tokens:   float64(burstOf(limit)),
+			lastFill: now,
+		}
+		l.buckets[name] = b
+	}
+	b.lastSeen = now
+	return b
+}
+
+func (l *Limiter) prune(now time.Time) {
+	if len(l.buckets) < maxBuckets {
+		return
+	}
+	for name, b := range l.buckets {
+		if now.Sub(b.lastSeen) > 24*time.Hour {
+			delete(l.buckets, name)
+		}
+	}
+}
+
+func burstOf(limit Limit) int {
+	if limit.Burst > 0 {
+		return limit.Burst
+	}
+	return max(1, int(math.Ceil(limit.Rate)))
+}
+
+func (b *bucket) refill(now time.Time) {
+	if day := now.UTC().Format(time.DateOnly); day != b.day {
+		b.day = day
+		b.used = 0
+	}
+
+	if b.limit.Rate <= 0 {
+		return
+	}
+	elapsed := now.Sub(b.lastFill).Seconds()
+	b.tokens = min(float64(burstOf(b.limit)), b.tokens+elapsed*b.limit.Rate)
+	b.lastFill = now
+}
+
+/*
+* reports why the next event can't be taken and how long until it could
+**/
+func (b *bucket) check(now time.Time) (string, time.Duration) {
+	if b.limit.DailyQuota > 0 && b.used >= b.limit.DailyQuota {
+		return fmt.Sprintf("exceeded its daily quota of %d events", b.limit.DailyQuota), nextDay(now).Sub(now)
+	}
+	if b.limit.Rate > 0 && b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / b.limit.Rate * float64(time.Second))
+		return fmt.Sprintf("exceeded %g events per second", b.limit.Rate), wait
+	}
+	return "", 0
+}
+
+/*
+* keeps the quota with the fewest events left
+**/
+func (b *bucket) quota(d *Decision, now time.Time) {
+	if b.limit.DailyQuota <= 0 {
+		return
+	}
+
+	remaining := max(0, b.limit.DailyQuota-b.used)
+	if d.QuotaLimit == 0 || remaining < d.QuotaRemaining {
+		d.QuotaLimit = b.limit.DailyQuota
+		d.QuotaRemaining = remaining
+		d.QuotaReset = nextDay(now)
+	}
+}
+
+func nextDay(now time.Time) time.Time {
+	return now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+}
+
+/*
+* quota headers go on every ingest response a quota applies to
+**/
+func writeQuotaHeaders(w http.ResponseWriter, d Decision) {
+	if d.QuotaLimit > 0 {
+		w.Header().Set("X-Quota-Limit", strconv.FormatInt(d.QuotaLimit, 10))
+		w.Header().Set("X-Quota-Remaining", strconv.FormatInt(d.QuotaRemaining, 10))
+		w.Header().Set("X-Quota-Reset", strconv.FormatInt(d.QuotaReset.Unix(), 10))
+	}
+}
+
+/*
+* whole seconds until a rejected event may be sent again
+**/
+func (d Decision) retryAfterSeconds() int {
+	return max(1, int(math.Ceil(d.RetryAfter.Seconds())))
+}
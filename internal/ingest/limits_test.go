@@ -0,0 +1,197 @@
+package ingest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xonoxc/scopion/internal/api/middleware"
+	"github.com/xonoxc/scopion/internal/live"
+	"github.com/xonoxc/scopion/internal/model"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func newTestLimiter(cfg LimitConfig) (*Limiter, *fakeClock, *[]model.Event) {
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	warnings := &[]model.Event{}
+
+	l := NewLimiter(cfg, func(e model.Event) { *warnings = append(*warnings, e) })
+	l.now = clock.Now
+
+	return l, clock, warnings
+}
+
+func TestLimiterRateLimitsEachService(t *testing.T) {
+	l, clock, warnings := newTestLimiter(LimitConfig{Service: Limit{Rate: 1, Burst: 2}})
+
+	for i := range 2 {
+		if d := l.Allow("default", "api", nil); !d.Allowed {
+			t.Fatalf("Expected event %d within burst to be allowed", i)
+		}
+	}
+
+	d := l.Allow("default", "api", nil)
+	if d.Allowed || d.RetryAfter != time.Second {
+		t.Errorf("Expected rejection with 1s retry, got %+v", d)
+	}
+	if !l.Allow("default", "worker", nil).Allowed {
+		t.Error("Expected other services to keep their own limit")
+	}
+
+	l.Allow("default", "api", nil)
+	if len(*warnings) != 1 {
+		t.Fatalf("Expected a single throttle warning, got %d", len(*warnings))
+	}
+	if w := (*warnings)[0]; w.Service != INTERNAL_SERVICE || w.Level != "warn" || w.Data["service"] != "api" {
+		t.Errorf("Unexpected warning %+v", w)
+	}
+
+	clock.now = clock.now.Add(time.Second)
+	if !l.Allow("default", "api", nil).Allowed {
+		t.Error("Expected tokens to refill")
+	}
+
+	stats := l.Stats("default")
+	if len(stats) != 1 || stats[0].Subject != "service default/api" || stats[0].Dropped != 2 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+	if stats := l.Stats("other"); len(stats) != 0 {
+		t.Errorf("Expected other projects not to see the subject, got %+v", stats)
+	}
+}
+
+func TestLimiterDailyQuotaResetsAtMidnight(t *testing.T) {
+	l, clock, _ := newTestLimiter(LimitConfig{Key: Limit{DailyQuota: 2}})
+	key := &model.APIKey{ID: "k1", Name: "ci"}
+
+	if !l.Allow("default", "api", nil).Allowed {
+		t.Error("Expected unauthenticated events to skip key limits")
+	}
+
+	l.Allow("default", "api", key)
+	if d := l.Allow("default", "worker", key); !d.Allowed || d.QuotaRemaining != 0 {
+		t.Errorf("Expected last event of the quota, got %+v", d)
+	}
+
+	d := l.Allow("default", "api", key)
+	if d.Allowed || d.RetryAfter != 12*time.Hour {
+		t.Errorf("Expected rejection until midnight, got %+v", d)
+	}
+
+	clock.now = clock.now.Add(12 * time.Hour)
+	if d := l.Allow("default", "api", key); !d.Allowed || d.QuotaRemaining != 1 {
+		t.Errorf("Expected quota to reset, got %+v", d)
+	}
+}
+
+func TestHandlerReturnsQuotaHeaders(t *testing.T) {
+	q := newTestQueue(t, newMemStore(), live.New())
+	l, _, _ := newTestLimiter(LimitConfig{Service: Limit{DailyQuota: 1}})
+	handler := Handler(q, Pipeline{Limiter: l})
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/ingest", strings.NewReader(`{"level":"info","service":"test","name":"event"}`))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send()
+	if w.Code != http.StatusAccepted || w.Header().Get("X-Quota-Limit") != "1" || w.Header().Get("X-Quota-Remaining") != "0" {
+		t.Errorf("Expected 202 with quota headers, got %d %v", w.Code, w.Header())
+	}
+
+	w = send()
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "43200" || w.Header().Get("X-Quota-Reset") == "" {
+		t.Errorf("Unexpected headers %v", w.Header())
+	}
+
+	status := httptest.NewRecorder()
+	StatusHandler(q, Pipeline{Limiter: l}).ServeHTTP(status, httptest.NewRequest("GET", "/api/ingest/status", nil))
+	if !strings.Contains(status.Body.String(), `"throttled":[{"subject":"service default/test","dropped":1}]`) {
+		t.Errorf("Expected dropped events in status, got %s", status.Body.String())
+	}
+
+	other := httptest.NewRequest("GET", "/api/ingest/status", nil)
+	other.Header.Set(middleware.PROJECT_HEADER, "other")
+	status = httptest.NewRecorder()
+	StatusHandler(q, Pipeline{Limiter: l}).ServeHTTP(status, other)
+	if !strings.Contains(status.Body.String(), `"throttled":[]`) {
+		t.Errorf("Expected another project not to see the subject, got %s", status.Body.String())
+	}
+}
+
+func TestHandlerRefundsLimitsWhenQueueRejects(t *testing.T) {
+	s := newMemStore()
+	s.setErr(errors.New("database is locked"))
+
+	q := openTestQueue(t, QueueConfig{Dir: t.TempDir(), Capacity: 1}, s, live.New())
+	l, _, _ := newTestLimiter(LimitConfig{Service: Limit{Rate: 1, Burst: 3, DailyQuota: 3}})
+	handler := Handler(q, Pipeline{Limiter: l})
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/ingest", strings.NewReader(`{"level":"info","service":"test","name":"event"}`))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := send(); w.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d", w.Code)
+	}
+
+	for range 5 {
+		w := send()
+		if w.Code != http.StatusTooManyRequests || w.Header().Get("X-Quota-Remaining") != "2" {
+			t.Fatalf("Expected the full queue to reject without using quota, got %d %v", w.Code, w.Header())
+		}
+	}
+
+	if stats := l.Stats("default"); len(stats) != 0 {
+		t.Errorf("Expected no events dropped by limits, got %+v", stats)
+	}
+
+	s.setErr(nil)
+	for range 2 {
+		waitFor(t, "queue to drain", func() bool { return q.Stats().Pending == 0 })
+		if w := send(); w.Code != http.StatusAccepted {
+			t.Fatalf("Expected the refunded quota to be usable, got %d", w.Code)
+		}
+	}
+}
+
+func TestBatchHandlerAppliesLimitsPerEvent(t *testing.T) {
+	q := newTestQueue(t, newMemStore(), live.New())
+	l, _, _ := newTestLimiter(LimitConfig{Service: Limit{DailyQuota: 1}})
+	handler := BatchHandler(q, Pipeline{Limiter: l})
+
+	body := `[{"service":"test","name":"first"},{"service":"test","name":"second"}]`
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/ingest/batch", strings.NewReader(body)))
+
+	if w.Code != http.StatusAccepted || w.Header().Get("X-Quota-Remaining") != "0" {
+		t.Fatalf("Expected 202 with quota headers, got %d %v", w.Code, w.Header())
+	}
+	var result model.BatchResult
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Accepted != 1 || len(result.Rejected) != 1 {
+		t.Fatalf("Expected the quota to take the first event only, got %+v", result)
+	}
+	if r := result.Rejected[0]; r.Index != 1 || r.Status != http.StatusTooManyRequests || r.RetryAfter != 43200 {
+		t.Errorf("Unexpected rejection %+v", r)
+	}
+}
@@ -0,0 +1,605 @@
+package ingest
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/xonoxc/scopion/internal/live"
+	"github.com/xonoxc/scopion/internal/model"
+	"github.com/xonoxc/scopion/internal/store"
+)
+
+const DEFAULT_QUEUE_DIR = "./scopion-wal"
+
+const (
+	segmentExt = ".wal"
+
+	retryBackoff    = 100 * time.Millisecond
+	maxRetryBackoff = 5 * time.Second
+)
+
+var (
+	ErrQueueFull   = errors.New("ingest queue is full")
+	ErrQueueClosed = errors.New("ingest queue is closed")
+)
+
+/*
+* QueueConfig controls the ingest write-ahead queue,
+* zero values fall back to defaults
+**/
+type QueueConfig struct {
+	/*
+	* directory holding the write-ahead log segments
+	**/
+	Dir string
+
+	/*
+	* events accepted but not yet stored before
+	* ingest is rejected with ErrQueueFull
+	**/
+	Capacity int
+
+	BatchSize     int
+	FlushInterval time.Duration
+	Writers       int
+
+	/*
+	* entries per log segment, a segment is deleted
+	* once all of its entries are stored
+	**/
+	SegmentSize int
+
+	/*
+	* suggested wait for clients rejected by a full queue
+	**/
+	RetryAfter time.Duration
+
+	/*
+	* fsync every entry, survives power loss and
+	* not only process crashes at the cost of throughput
+	**/
+	Sync bool
+
+	/*
+	* tail based sampling between the log and the store,
+	* every event is still published live
+	**/
+	Sampling SamplingConfig
+}
+
+func (c *QueueConfig) applyDefaults() {
+	if c.Dir == "" {
+		c.Dir = DEFAULT_QUEUE_DIR
+	}
+	if c.Capacity <= 0 {
+		c.Capacity = 10000
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 256
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = 50 * time.Millisecond
+	}
+	if c.Writers <= 0 {
+		c.Writers = 2
+	}
+	if c.SegmentSize <= 0 {
+		c.SegmentSize = 4096
+	}
+	if c.RetryAfter <= 0 {
+		c.RetryAfter = time.Second
+	}
+}
+
+type QueueStats struct {
+	Pending     int        `json:"pending"`
+	Capacity    int        `json:"capacity"`
+	Stored      uint64     `json:"stored"`
+	Rejected    uint64     `json:"rejected"`
+	Failures    uint64     `json:"failures"`
+	Healthy     bool       `json:"healthy"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+
+	Sampling *SamplingStats `json:"sampling,omitempty"`
+}
+
+type segment struct {
+	id   uint64
+	file *os.File
+
+	written int
+	pending int
+	sealed  bool
+}
+
+type entry struct {
+	event model.Event
+	seg   *segment
+}
+
+/*
+* Queue decouples ingest from the store. events are appended to a
+* write-ahead log and handed to writer goroutines that store them in
+* batches and then publish them live. a segment of the log is removed
+* once everything in it is stored, whatever is left on disk when the
+* process dies is replayed by the next OpenQueue
+**/
+type Queue struct {
+	cfg     QueueConfig
+	store   func() store.Storage
+	live    *live.Broadcaster
+	sampler *Sampler
+
+	entries chan entry
+	done    chan struct{}
+	wg      sync.WaitGroup
+
+	mu          sync.Mutex
+	closed      bool
+	active      *segment
+	segments    map[uint64]*segment
+	nextSegment uint64
+	pending     int
+
+	stored    uint64
+	rejected  uint64
+	failures  uint64
+	lastErr   error
+	lastErrAt time.Time
+	unhealthy bool
+
+	/*
+	* the sampler's last write of kept traces failed
+	**/
+	samplerUnhealthy bool
+}
+
+/*
+* store is called for every batch so writes follow
+* the current storage when it is switched at runtime
+**/
+func OpenQueue(cfg QueueConfig, store func() store.Storage, live *live.Broadcaster) (*Queue, error) {
+	cfg.applyDefaults()
+
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create queue dir: %w", err)
+	}
+
+	q := &Queue{
+		cfg:      cfg,
+		store:    store,
+		live:     live,
+		entries:  make(chan entry, cfg.Capacity),
+		done:     make(chan struct{}),
+		segments: map[uint64]*segment{},
+	}
+
+	if err := q.replay(); err != nil {
+		return nil, err
+	}
+
+	active, err := q.openSegment()
+	if err != nil {
+		return nil, err
+	}
+	q.active = active
+
+	if cfg.Sampling.Enabled() {
+		q.sampler = newSampler(cfg.Sampling)
+		q.sampler.report = q.recordSamplerWrite
+		q.sampler.start()
+	}
+
+	for range cfg.Writers {
+		q.wg.Add(1)
+		go q.writer()
+	}
+
+	return q, nil
+}
+
+/*
+* Enqueue logs the event and queues it for storage. a missing
+* ID, project or timestamp is filled in. once Enqueue returns nil the
+* event is on disk and will be stored, possibly after a restart
+**/
+func (q *Queue) Enqueue(e model.Event) error {
+	if e.ID == "" {
+		e.ID = uuid.NewString()
+	}
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now()
+	}
+	e.Project = model.ProjectOrDefault(e.Project)
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("encode event: %w", err)
+	}
+	line = append(line, '\n')
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+	if q.pending >= q.cfg.Capacity {
+		q.rejected++
+		return ErrQueueFull
+	}
+
+	seg := q.active
+	if _, err := seg.file.Write(line); err != nil {
+		return fmt.Errorf("write ahead log: %w", err)
+	}
+	if q.cfg.Sync {
+		if err := seg.file.Sync(); err != nil {
+			return fmt.Errorf("sync ahead log: %w", err)
+		}
+	}
+
+	seg.written++
+	seg.pending++
+	q.pending++
+
+	/*
+	* never blocks, the channel holds Capacity entries
+	**/
+	q.entries <- entry{event: e, seg: seg}
+
+	if seg.written >= q.cfg.SegmentSize {
+		if err := q.rotate(); err != nil {
+			log.Printf("ingest: failed to rotate write ahead log: %v", err)
+		}
+	}
+
+	return nil
+}
+
+/*
+* seconds a rejected client should wait, for Retry-After
+**/
+func (q *Queue) RetryAfter() int {
+	return max(1, int((q.cfg.RetryAfter+time.Second-1)/time.Second))
+}
+
+func (q *Queue) Stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	stats := QueueStats{
+		Pending:  q.pending,
+		Capacity: q.cfg.Capacity,
+		Stored:   q.stored,
+		Rejected: q.rejected,
+		Failures: q.failures,
+		Healthy:  !q.unhealthy && !q.samplerUnhealthy,
+	}
+	if q.lastErr != nil {
+		at := q.lastErrAt
+		stats.LastError = q.lastErr.Error()
+		stats.LastErrorAt = &at
+	}
+	if q.sampler != nil {
+		sampling := q.sampler.Stats()
+		stats.Sampling = &sampling
+	}
+
+	return stats
+}
+
+/*
+* Close stops accepting events and writes out what is queued.
+* batches the store still rejects stay in the log for replay
+**/
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return nil
+	}
+	q.closed = true
+	close(q.done)
+	close(q.entries)
+	q.mu.Unlock()
+
+	/*
+	* writers may be inside the sampler retrying a failing store,
+	* it has to give up before they can return
+	**/
+	if q.sampler != nil {
+		q.sampler.stop()
+	}
+	q.wg.Wait()
+
+	if q.sampler != nil {
+		q.sampler.Close()
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var firstErr error
+	for _, seg := range q.segments {
+		if err := seg.file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if seg.pending == 0 {
+			os.Remove(seg.file.Name())
+		}
+	}
+
+	return firstErr
+}
+
+func (q *Queue) writer() {
+	defer q.wg.Done()
+
+	batch := make([]entry, 0, q.cfg.BatchSize)
+	timer := time.NewTimer(q.cfg.FlushInterval)
+	timer.Stop()
+
+	for {
+		first, ok := <-q.entries
+		if !ok {
+			return
+		}
+		batch = append(batch[:0], first)
+
+		timer.Reset(q.cfg.FlushInterval)
+	collect:
+		for len(batch) < q.cfg.BatchSize {
+			select {
+			case e, ok := <-q.entries:
+				if !ok {
+					break collect
+				}
+				batch = append(batch, e)
+			case <-timer.C:
+				break collect
+			}
+		}
+		timer.Stop()
+
+		q.write(batch)
+	}
+}
+
+/*
+* stores the batch, retrying with backoff until it succeeds
+* or the queue is closed. only newly stored events (or ones handed
+* to the sampler) are published, the whole batch is removed from the log
+**/
+func (q *Queue) write(batch []entry) {
+	events := make([]model.Event, len(batch))
+	for i, e := range batch {
+		events[i] = e.event
+	}
+
+	var publish []model.Event
+	backoff := retryBackoff
+	for {
+		var err error
+		if publish, err = q.append(events); err == nil {
+			break
+		}
+
+		q.recordFailure(err)
+		log.Printf("ingest: failed to store %d events: %v", len(events), err)
+
+		select {
+		case <-q.done:
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxRetryBackoff)
+	}
+
+	for _, e := range publish {
+		q.live.TryPublish(e)
+	}
+
+	q.ack(batch)
+}
+
+/*
+* returns the events to publish live, duplicates
+* of events already stored are left out
+**/
+func (q *Queue) append(events []model.Event) ([]model.Event, error) {
+	if q.sampler != nil {
+		return q.sampler.AppendBatch(q.store(), events)
+	}
+	return store.AppendNew(q.store(), events)
+}
+
+func (q *Queue) recordFailure(err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.failures++
+	q.unhealthy = true
+	q.lastErr = err
+	q.lastErrAt = time.Now()
+}
+
+/*
+* sampled events are written apart from the batches,
+* a failing store there also makes the queue unhealthy
+**/
+func (q *Queue) recordSamplerWrite(err error) {
+	if err != nil {
+		q.recordFailure(err)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.samplerUnhealthy = err != nil
+}
+
+func (q *Queue) ack(batch []entry) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.unhealthy = false
+
+	for _, e := range batch {
+		e.seg.pending--
+		q.pending--
+		q.stored++
+	}
+
+	for _, e := range batch {
+		seg := e.seg
+		if seg.pending != 0 {
+			continue
+		}
+
+		switch {
+		case seg.sealed:
+			if _, ok := q.segments[seg.id]; ok {
+				q.removeSegment(seg)
+			}
+		case seg == q.active && seg.written > 0 && !q.closed:
+			/*
+			* everything written so far is stored, start the
+			* active segment over so a restart replays nothing
+			**/
+			if err := seg.file.Truncate(0); err != nil {
+				log.Printf("ingest: failed to truncate write ahead log: %v", err)
+				continue
+			}
+			seg.written = 0
+		}
+	}
+}
+
+func (q *Queue) rotate() error {
+	next, err := q.openSegment()
+	if err != nil {
+		return err
+	}
+
+	old := q.active
+	old.sealed = true
+	q.active = next
+
+	if old.pending == 0 {
+		q.removeSegment(old)
+	}
+	return nil
+}
+
+func (q *Queue) openSegment() (*segment, error) {
+	id := q.nextSegment
+	q.nextSegment++
+
+	path := filepath.Join(q.cfg.Dir, fmt.Sprintf("%020d%s", id, segmentExt))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND|os.O_TRUNC, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open write ahead log: %w", err)
+	}
+
+	seg := &segment{id: id, file: file}
+	q.segments[id] = seg
+	return seg, nil
+}
+
+func (q *Queue) removeSegment(seg *segment) {
+	delete(q.segments, seg.id)
+	seg.file.Close()
+
+	if err := os.Remove(seg.file.Name()); err != nil {
+		log.Printf("ingest: failed to remove write ahead log segment: %v", err)
+	}
+}
+
+/*
+* stores the events of segments left behind by a previous run.
+* entries may already have been stored before the crash, which
+* AppendBatch tolerates. segments are only removed once stored.
+* replayed events are not sampled
+**/
+func (q *Queue) replay() error {
+	paths, err := filepath.Glob(filepath.Join(q.cfg.Dir, "*"+segmentExt))
+	if err != nil {
+		return err
+	}
+	slices.Sort(paths)
+
+	replayed := 0
+	for _, path := range paths {
+		id, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(path), segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		q.nextSegment = max(q.nextSegment, id+1)
+
+		events, err := readSegment(path)
+		if err != nil {
+			return fmt.Errorf("read write ahead log %s: %w", path, err)
+		}
+
+		for batch := range slices.Chunk(events, q.cfg.BatchSize) {
+			if err := q.store().AppendBatch(batch); err != nil {
+				return fmt.Errorf("replay write ahead log %s: %w", path, err)
+			}
+		}
+
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		replayed += len(events)
+	}
+
+	if replayed > 0 {
+		log.Printf("ingest: replayed %d events from the write ahead log", replayed)
+	}
+	return nil
+}
+
+/*
+* reads the events of a segment. a torn last line from a crash
+* mid write, or any line that doesn't decode, is skipped
+**/
+func readSegment(path string) ([]model.Event, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var events []model.Event
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var e model.Event
+		if err := json.Unmarshal(line, &e); err != nil {
+			continue
+		}
+		events = append(events, e)
+	}
+
+	return events, nil
+}
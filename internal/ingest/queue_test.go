@@ -0,0 +1,270 @@
+package ingest
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xonoxc/scopion/internal/api/middleware"
+	"github.com/xonoxc/scopion/internal/live"
+	"github.com/xonoxc/scopion/internal/model"
+	"github.com/xonoxc/scopion/internal/processor"
+	"github.com/xonoxc/scopion/internal/store"
+)
+
+/*
+* in memory store that can be made to fail,
+* only the write path is implemented
+**/
+type memStore struct {
+	store.Storage
+
+	mu     sync.Mutex
+	events map[string]model.Event
+	err    error
+}
+
+func newMemStore() *memStore {
+	return &memStore{events: map[string]model.Event{}}
+}
+
+func (m *memStore) AppendBatch(events []model.Event) error {
+	_, err := m.AppendBatchInserted(events)
+	return err
+}
+
+func (m *memStore) AppendBatchInserted(events []model.Event) ([]model.Event, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	/*
+	* tests use a single project, ids are enough
+	**/
+	var inserted []model.Event
+	for _, e := range events {
+		if _, ok := m.events[e.ID]; !ok {
+			m.events[e.ID] = e
+			inserted = append(inserted, e)
+		}
+	}
+	return inserted, nil
+}
+
+func (m *memStore) setErr(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.err = err
+}
+
+func (m *memStore) count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.events)
+}
+
+func newTestQueue(t *testing.T, s store.Storage, b *live.Broadcaster) *Queue {
+	t.Helper()
+	return openTestQueue(t, QueueConfig{Dir: t.TempDir()}, s, b)
+}
+
+func openTestQueue(t *testing.T, cfg QueueConfig, s store.Storage, b *live.Broadcaster) *Queue {
+	t.Helper()
+
+	cfg.FlushInterval = 5 * time.Millisecond
+	q, err := OpenQueue(cfg, func() store.Storage { return s }, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { q.Close() })
+
+	return q
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestQueueStoresAndPublishes(t *testing.T) {
+	s := newMemStore()
+	b := live.New()
+	sub := b.Subscribe(live.Filter{}, 16)
+	defer b.Unsubscribe(sub)
+
+	q := newTestQueue(t, s, b)
+
+	for range 5 {
+		if err := q.Enqueue(model.Event{Service: "api", Name: "request"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	waitFor(t, "events to be stored", func() bool { return s.count() == 5 })
+
+	select {
+	case m := <-sub.Events():
+		if m.Event.ID == "" || m.Event.Timestamp.IsZero() {
+			t.Errorf("Expected id and timestamp to be filled in, got %+v", m.Event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected stored events to be published")
+	}
+
+	waitFor(t, "queue to drain", func() bool { return q.Stats().Pending == 0 })
+	if stats := q.Stats(); stats.Stored != 5 || !stats.Healthy {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestQueuePublishesDuplicatesOnce(t *testing.T) {
+	s := newMemStore()
+	b := live.New()
+	sub := b.Subscribe(live.Filter{}, 16)
+	defer b.Unsubscribe(sub)
+
+	q := newTestQueue(t, s, b)
+
+	e := model.Event{ID: "retried", Service: "api", Name: "request"}
+	for range 3 {
+		if err := q.Enqueue(e); err != nil {
+			t.Fatal(err)
+		}
+		waitFor(t, "queue to drain", func() bool { return q.Stats().Pending == 0 })
+	}
+
+	if s.count() != 1 {
+		t.Fatalf("Expected 1 stored event, got %d", s.count())
+	}
+
+	<-sub.Events()
+	select {
+	case m := <-sub.Events():
+		t.Errorf("Expected a retried event to be published once, got %+v", m.Event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestHandlerRejectsWhenQueueFull(t *testing.T) {
+	s := newMemStore()
+	s.setErr(errors.New("database is locked"))
+
+	q := openTestQueue(t, QueueConfig{Dir: t.TempDir(), Capacity: 2, RetryAfter: 3 * time.Second}, s, live.New())
+	handler := Handler(q, Pipeline{})
+
+	codes := []int{}
+	for range 3 {
+		req := httptest.NewRequest("POST", "/ingest", strings.NewReader(`{"level":"info","service":"test","name":"event"}`))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		codes = append(codes, w.Code)
+
+		if w.Code == http.StatusTooManyRequests && w.Header().Get("Retry-After") != "3" {
+			t.Errorf("Expected Retry-After 3, got %q", w.Header().Get("Retry-After"))
+		}
+	}
+
+	if codes[0] != 202 || codes[1] != 202 || codes[2] != 429 {
+		t.Errorf("Expected 202, 202, 429, got %v", codes)
+	}
+
+	waitFor(t, "store failure to be reported", func() bool { return !q.Stats().Healthy })
+	if stats := q.Stats(); stats.LastError != "database is locked" || stats.Rejected != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+
+	s.setErr(nil)
+	waitFor(t, "retry after store recovers", func() bool { return s.count() == 2 })
+	waitFor(t, "queue to become healthy", func() bool { return q.Stats().Healthy })
+}
+
+func TestQueueReplaysAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	failing := newMemStore()
+	failing.setErr(errors.New("disk full"))
+
+	q := openTestQueue(t, QueueConfig{Dir: dir, SegmentSize: 2}, failing, live.New())
+	for range 5 {
+		if err := q.Enqueue(model.Event{Service: "api", Name: "request"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	q.Close()
+
+	segments, _ := filepath.Glob(filepath.Join(dir, "*.wal"))
+	if len(segments) == 0 {
+		t.Fatal("Expected unstored events to stay in the write ahead log")
+	}
+
+	s := newMemStore()
+	openTestQueue(t, QueueConfig{Dir: dir}, s, live.New())
+
+	if s.count() != 5 {
+		t.Errorf("Expected 5 replayed events, got %d", s.count())
+	}
+}
+
+func TestHandlerTakesProjectFromHeader(t *testing.T) {
+	s := newMemStore()
+	q := newTestQueue(t, s, live.New())
+
+	body := `{"level":"info","service":"test","name":"event","project":"spoofed"}`
+	req := httptest.NewRequest("POST", "/ingest", strings.NewReader(body))
+	req.Header.Set(middleware.PROJECT_HEADER, "shop")
+	w := httptest.NewRecorder()
+	Handler(q, Pipeline{}).ServeHTTP(w, req)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d", w.Code)
+	}
+
+	waitFor(t, "event to be stored", func() bool { return s.count() == 1 })
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.events {
+		if e.Project != "shop" {
+			t.Errorf("Expected project shop, got %q", e.Project)
+		}
+	}
+}
+
+func TestHandlerRunsProcessors(t *testing.T) {
+	s := newMemStore()
+	q := newTestQueue(t, s, live.New())
+	handler := Handler(q, Pipeline{Processors: processor.Chain{processor.ClientInfo{}, processor.HTTPName{}}})
+
+	req := httptest.NewRequest("POST", "/ingest", strings.NewReader(`{"service":"api","name":"GET /users"}`))
+	req.Header.Set("User-Agent", "scopion-test")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d", w.Code)
+	}
+	waitFor(t, "event to be stored", func() bool { return s.count() == 1 })
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.events {
+		if e.Data["client.ip"] != "192.0.2.1" || e.Data["client.user_agent"] != "scopion-test" || e.Data["http.method"] != "GET" {
+			t.Errorf("Expected enriched event, got %v", e.Data)
+		}
+	}
+}
@@ -0,0 +1,428 @@
+package ingest
+
+import (
+	"hash/fnv"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/xonoxc/scopion/internal/live"
+	"github.com/xonoxc/scopion/internal/model"
+	"github.com/xonoxc/scopion/internal/store"
+)
+
+/*
+* SamplingConfig controls tail based sampling of traces on
+* their way to the store. sampling is off while SampleRate is 0
+**/
+type SamplingConfig struct {
+	/*
+	* fraction of uninteresting traces that are kept
+	**/
+	SampleRate float64
+
+	/*
+	* how long events of a trace are held before it is decided,
+	* measured from its first event
+	**/
+	Window time.Duration
+
+	/*
+	* traces taking longer are kept, 0 disables the check.
+	* latency is the spread of event timestamps, or the largest
+	* duration_ms attribute when that is longer
+	**/
+	LatencyThreshold time.Duration
+
+	/*
+	* traces with an event matching any rule are kept,
+	* rules apply to every project
+	**/
+	Rules []live.Filter
+
+	/*
+	* traces held at once, the oldest is decided early beyond this
+	**/
+	MaxTraces int
+}
+
+func (c SamplingConfig) Enabled() bool {
+	return c.SampleRate > 0 && c.SampleRate < 1
+}
+
+func (c *SamplingConfig) applyDefaults() {
+	if c.Window <= 0 {
+		c.Window = 10 * time.Second
+	}
+	if c.MaxTraces <= 0 {
+		c.MaxTraces = 10000
+	}
+}
+
+type SamplingStats struct {
+	PendingTraces int     `json:"pending_traces"`
+	SampleRate    float64 `json:"sample_rate"`
+
+	/*
+	* traces kept by reason, and dropped by chance
+	**/
+	KeptErrors  uint64 `json:"kept_errors"`
+	KeptLatency uint64 `json:"kept_latency"`
+	KeptRule    uint64 `json:"kept_rule"`
+	KeptSampled uint64 `json:"kept_sampled"`
+	Dropped     uint64 `json:"dropped"`
+
+	DroppedEvents uint64 `json:"dropped_events"`
+}
+
+type pendingTrace struct {
+	key      string
+	deadline time.Time
+	events   []model.Event
+
+	/*
+	* the store of the latest batch, decided events go where
+	* the queue was writing when they arrived
+	**/
+	store store.Storage
+}
+
+type decision struct {
+	keep bool
+
+	/*
+	* rate a trace kept by chance was sampled at
+	**/
+	sampleRate float64
+	expires    time.Time
+}
+
+/*
+* Sampler holds traced events until their trace can be decided as a
+* whole, events without a trace id are stored right away. events it
+* holds are already removed from the write-ahead log, so up to one
+* Window of traces is lost if the process dies
+**/
+type Sampler struct {
+	cfg SamplingConfig
+	now func() time.Time
+
+	mu      sync.Mutex
+	pending map[string]*pendingTrace
+	order   []*pendingTrace
+	decided map[string]decision
+	stats   SamplingStats
+
+	/*
+	* told about every failed write, and with nil once one succeeds
+	**/
+	report func(error)
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+func newSampler(cfg SamplingConfig) *Sampler {
+	cfg.applyDefaults()
+
+	s := &Sampler{
+		cfg:     cfg,
+		now:     time.Now,
+		pending: map[string]*pendingTrace{},
+		decided: map[string]decision{},
+		done:    make(chan struct{}),
+	}
+	s.stats.SampleRate = cfg.SampleRate
+
+	return s
+}
+
+/*
+* starts deciding traces as their window ends
+**/
+func (s *Sampler) start() {
+	s.wg.Add(1)
+	go s.run()
+}
+
+/*
+* AppendBatch stores untraced events and holds the rest. events of
+* a trace decided recently follow that decision straight away. it
+* returns the events to publish live, all but the untraced ones
+* that were already stored
+**/
+func (s *Sampler) AppendBatch(st store.Storage, events []model.Event) ([]model.Event, error) {
+	var direct []model.Event
+	for _, e := range events {
+		if e.TraceID == "" {
+			direct = append(direct, e)
+		}
+	}
+
+	var inserted []model.Event
+	if len(direct) > 0 {
+		var err error
+		if inserted, err = store.AppendNew(st, direct); err != nil {
+			return nil, err
+		}
+	}
+
+	/*
+	* inserted keeps the order of direct, so walking both
+	* finds the untraced events that were skipped
+	**/
+	publish := make([]model.Event, 0, len(events))
+	for _, e := range events {
+		if e.TraceID != "" {
+			publish = append(publish, e)
+			continue
+		}
+		if len(inserted) > 0 && inserted[0].ID == e.ID && inserted[0].Project == e.Project {
+			publish = append(publish, e)
+			inserted = inserted[1:]
+		}
+	}
+
+	var ready []*pendingTrace
+
+	s.mu.Lock()
+	now := s.now()
+	for _, e := range events {
+		if e.TraceID == "" {
+			continue
+		}
+
+		key := model.ProjectOrDefault(e.Project) + "\x00" + e.TraceID
+		if d, ok := s.decided[key]; ok {
+			if d.keep {
+				if d.sampleRate > 0 {
+					e.SampleRate = model.SampleRateOrOne(e.SampleRate) * d.sampleRate
+				}
+				ready = append(ready, &pendingTrace{key: key, events: []model.Event{e}, store: st})
+			} else {
+				s.stats.DroppedEvents++
+			}
+			continue
+		}
+
+		t, ok := s.pending[key]
+		if !ok {
+			t = &pendingTrace{key: key, deadline: now.Add(s.cfg.Window)}
+			s.pending[key] = t
+			s.order = append(s.order, t)
+		}
+		t.events = append(t.events, e)
+		t.store = st
+	}
+
+	for len(s.order) > s.cfg.MaxTraces {
+		ready = append(ready, s.decide(s.order[0], now)...)
+	}
+	s.mu.Unlock()
+
+	s.write(ready)
+	return publish, nil
+}
+
+func (s *Sampler) Stats() SamplingStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := s.stats
+	stats.PendingTraces = len(s.pending)
+	return stats
+}
+
+/*
+* stops retrying failed writes, each is tried once more at most
+**/
+func (s *Sampler) stop() {
+	s.closeOnce.Do(func() { close(s.done) })
+}
+
+/*
+* decides every held trace and writes out the kept ones
+**/
+func (s *Sampler) Close() {
+	s.stop()
+	s.wg.Wait()
+
+	s.mu.Lock()
+	var ready []*pendingTrace
+	now := s.now()
+	for len(s.order) > 0 {
+		ready = append(ready, s.decide(s.order[0], now)...)
+	}
+	s.mu.Unlock()
+
+	s.write(ready)
+}
+
+func (s *Sampler) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(min(time.Second, s.cfg.Window/4))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.write(s.expire())
+		}
+	}
+}
+
+func (s *Sampler) expire() []*pendingTrace {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+
+	var ready []*pendingTrace
+	for len(s.order) > 0 && !now.Before(s.order[0].deadline) {
+		ready = append(ready, s.decide(s.order[0], now)...)
+	}
+
+	for key, d := range s.decided {
+		if now.After(d.expires) {
+			delete(s.decided, key)
+		}
+	}
+
+	return ready
+}
+
+/*
+* removes the oldest trace, which must be t, and returns it when kept.
+* the decision is remembered for a window so late events follow it
+**/
+func (s *Sampler) decide(t *pendingTrace, now time.Time) []*pendingTrace {
+	s.order[0] = nil
+	s.order = s.order[1:]
+	delete(s.pending, t.key)
+
+	d := decision{keep: true, expires: now.Add(s.cfg.Window)}
+	switch {
+	case s.hasError(t):
+		s.stats.KeptErrors++
+	case s.slow(t):
+		s.stats.KeptLatency++
+	case s.matchesRule(t):
+		s.stats.KeptRule++
+	case traceSampled(t.key, s.cfg.SampleRate):
+		s.stats.KeptSampled++
+		d.sampleRate = s.cfg.SampleRate
+		for i := range t.events {
+			t.events[i].SampleRate = model.SampleRateOrOne(t.events[i].SampleRate) * s.cfg.SampleRate
+		}
+	default:
+		d.keep = false
+		s.stats.Dropped++
+		s.stats.DroppedEvents += uint64(len(t.events))
+	}
+
+	s.decided[t.key] = d
+
+	if !d.keep {
+		return nil
+	}
+	return []*pendingTrace{t}
+}
+
+func (s *Sampler) hasError(t *pendingTrace) bool {
+	for _, e := range t.events {
+		if model.IsErrorLevel(e.Level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Sampler) slow(t *pendingTrace) bool {
+	if s.cfg.LatencyThreshold <= 0 {
+		return false
+	}
+
+	first, last := t.events[0].Timestamp, t.events[0].Timestamp
+	var longest time.Duration
+	for _, e := range t.events {
+		first = minTime(first, e.Timestamp)
+		last = maxTime(last, e.Timestamp)
+
+		if ms, ok := e.Data["duration_ms"].(float64); ok {
+			longest = max(longest, time.Duration(ms*float64(time.Millisecond)))
+		}
+	}
+
+	return max(last.Sub(first), longest) > s.cfg.LatencyThreshold
+}
+
+func (s *Sampler) matchesRule(t *pendingTrace) bool {
+	for _, rule := range s.cfg.Rules {
+		for _, e := range t.events {
+			rule.Project = e.Project
+			if rule.Match(e) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+/*
+* writes kept traces, retrying until stored or the sampler is closed
+**/
+func (s *Sampler) write(traces []*pendingTrace) {
+	byStore := map[store.Storage][]model.Event{}
+	for _, t := range traces {
+		byStore[t.store] = append(byStore[t.store], t.events...)
+	}
+
+	for st, events := range byStore {
+		backoff := retryBackoff
+		for {
+			err := st.AppendBatch(events)
+			if s.report != nil {
+				s.report(err)
+			}
+			if err == nil {
+				break
+			}
+			log.Printf("ingest: failed to store %d sampled events: %v", len(events), err)
+
+			select {
+			case <-s.done:
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, maxRetryBackoff)
+		}
+	}
+}
+
+/*
+* the same trace is always kept or dropped for a given rate
+**/
+func traceSampled(key string, rate float64) bool {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+
+	return float64(h.Sum64()%10000) < rate*10000
+}
+
+func minTime(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+	return a
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
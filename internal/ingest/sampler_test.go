@@ -0,0 +1,204 @@
+package ingest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/xonoxc/scopion/internal/live"
+	"github.com/xonoxc/scopion/internal/model"
+	"github.com/xonoxc/scopion/internal/store"
+)
+
+func newTestSampler(t *testing.T, cfg SamplingConfig) (*Sampler, *fakeClock) {
+	t.Helper()
+
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	s := newSampler(cfg)
+	s.now = clock.Now
+	t.Cleanup(s.Close)
+
+	return s, clock
+}
+
+/*
+* moves the clock on and decides what expired, the
+* sampler isn't started so nothing else decides traces
+**/
+func expireAfter(s *Sampler, clock *fakeClock, d time.Duration) {
+	clock.now = clock.now.Add(d)
+	s.write(s.expire())
+}
+
+/*
+* trace ids the sampler keeps, or drops, at rate
+**/
+func traceIDs(rate float64, kept bool, n int) []string {
+	var ids []string
+	for i := 0; len(ids) < n; i++ {
+		id := fmt.Sprintf("trace-%d", i)
+		if traceSampled(model.DEFAULT_PROJECT+"\x00"+id, rate) == kept {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func TestSamplerKeepsInterestingTraces(t *testing.T) {
+	s, clock := newTestSampler(t, SamplingConfig{
+		SampleRate:       0.1,
+		Window:           time.Second,
+		LatencyThreshold: time.Second,
+		Rules:            []live.Filter{{Services: []string{"checkout"}}},
+	})
+	st := newMemStore()
+
+	boring := traceIDs(0.1, false, 4)
+	now := clock.now
+	events := []model.Event{
+		{ID: "untraced", Level: "info"},
+		{ID: "error-1", TraceID: boring[0], Level: "info"},
+		{ID: "error-2", TraceID: boring[0], Level: "error"},
+		{ID: "slow-1", TraceID: boring[1], Timestamp: now},
+		{ID: "slow-2", TraceID: boring[1], Timestamp: now.Add(1500 * time.Millisecond)},
+		{ID: "rule", TraceID: boring[2], Service: "checkout"},
+		{ID: "boring", TraceID: boring[3], Service: "search", Data: map[string]any{"duration_ms": 20.0}},
+	}
+	if _, err := s.AppendBatch(st, events); err != nil {
+		t.Fatal(err)
+	}
+
+	if st.count() != 1 {
+		t.Fatalf("Expected only the untraced event to be stored before the window ends, got %d", st.count())
+	}
+
+	expireAfter(s, clock, time.Second)
+
+	for _, id := range []string{"untraced", "error-1", "error-2", "slow-1", "slow-2", "rule"} {
+		if _, ok := st.events[id]; !ok {
+			t.Errorf("Expected %s to be stored", id)
+		}
+	}
+	if _, ok := st.events["boring"]; ok {
+		t.Error("Expected the uninteresting trace to be dropped")
+	}
+
+	stats := s.Stats()
+	if stats.KeptErrors != 1 || stats.KeptLatency != 1 || stats.KeptRule != 1 || stats.Dropped != 1 || stats.PendingTraces != 0 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestSamplerRecordsRateAndFollowsDecision(t *testing.T) {
+	s, clock := newTestSampler(t, SamplingConfig{SampleRate: 0.25, Window: time.Second})
+	st := newMemStore()
+
+	kept := traceIDs(0.25, true, 1)[0]
+	dropped := traceIDs(0.25, false, 1)[0]
+
+	s.AppendBatch(st, []model.Event{{ID: "a", TraceID: kept}, {ID: "b", TraceID: dropped}})
+	expireAfter(s, clock, time.Second)
+
+	s.AppendBatch(st, []model.Event{{ID: "late-a", TraceID: kept}, {ID: "late-b", TraceID: dropped}})
+
+	if st.count() != 2 {
+		t.Fatalf("Expected the kept trace and its late event, got %d events", st.count())
+	}
+	for _, id := range []string{"a", "late-a"} {
+		if rate := st.events[id].SampleRate; rate != 0.25 {
+			t.Errorf("Expected %s to record sample rate 0.25, got %v", id, rate)
+		}
+	}
+	if stats := s.Stats(); stats.DroppedEvents != 2 {
+		t.Errorf("Expected 2 dropped events, got %+v", stats)
+	}
+}
+
+func TestSamplerDecidesOldestBeyondMaxTraces(t *testing.T) {
+	s, _ := newTestSampler(t, SamplingConfig{SampleRate: 0.5, Window: time.Hour, MaxTraces: 1})
+	st := newMemStore()
+
+	s.AppendBatch(st, []model.Event{{ID: "first", TraceID: "t1", Level: "error"}})
+	s.AppendBatch(st, []model.Event{{ID: "second", TraceID: "t2", Level: "error"}})
+
+	if st.count() != 1 || s.Stats().PendingTraces != 1 {
+		t.Errorf("Expected the oldest trace to be decided early, stored %d", st.count())
+	}
+
+	s.Close()
+	if st.count() != 2 {
+		t.Errorf("Expected close to decide held traces, stored %d", st.count())
+	}
+}
+
+func TestQueuePublishesSampledOutEvents(t *testing.T) {
+	st := newMemStore()
+	b := live.New()
+	sub := b.Subscribe(live.Filter{}, 16)
+	defer b.Unsubscribe(sub)
+
+	dropped := traceIDs(0.01, false, 1)[0]
+	q := openTestQueue(t, QueueConfig{Dir: t.TempDir(), Sampling: SamplingConfig{SampleRate: 0.01, Window: 10 * time.Millisecond}}, st, b)
+
+	if err := q.Enqueue(model.Event{TraceID: dropped, Service: "api", Name: "request"}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case m := <-sub.Events():
+		if m.Event.TraceID != dropped {
+			t.Errorf("Unexpected event %+v", m.Event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected sampled out events to still be published")
+	}
+
+	waitFor(t, "trace to be decided", func() bool {
+		stats := q.Stats()
+		return stats.Sampling != nil && stats.Sampling.Dropped == 1
+	})
+	if st.count() != 0 {
+		t.Errorf("Expected the trace not to be stored, got %d events", st.count())
+	}
+}
+
+func TestQueueClosesWhileSampledWritesFail(t *testing.T) {
+	st := newMemStore()
+	st.setErr(errors.New("database is locked"))
+
+	q, err := OpenQueue(QueueConfig{
+		Dir:           t.TempDir(),
+		FlushInterval: 5 * time.Millisecond,
+		Sampling:      SamplingConfig{SampleRate: 0.5, Window: time.Hour, MaxTraces: 1},
+	}, func() store.Storage { return st }, live.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/*
+	* the second trace pushes the first out early, kept for its
+	* error, and the writer is left retrying the failing store
+	**/
+	for _, trace := range []string{"a", "b"} {
+		if err := q.Enqueue(model.Event{TraceID: trace, Level: "error", Service: "api", Name: "request"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFor(t, "sampled write to fail", func() bool { return !q.Stats().Healthy })
+	if stats := q.Stats(); stats.LastError != "database is locked" {
+		t.Errorf("Expected the sampler failure in the status, got %+v", stats)
+	}
+
+	closed := make(chan error)
+	go func() { closed <- q.Close() }()
+
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected close not to wait on a failing store")
+	}
+}
@@ -0,0 +1,199 @@
+package ingest
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/xonoxc/scopion/internal/model"
+)
+
+/*
+* ValidationConfig bounds what a single ingested event may
+* contain, zero values fall back to defaults
+**/
+type ValidationConfig struct {
+	/*
+	* request body size, larger bodies are rejected with 413
+	**/
+	MaxEventBytes int64
+
+	/*
+	* bounds on a batch request, its body and how many events it
+	* holds. each event in it is still held to MaxEventBytes
+	**/
+	MaxBatchBytes  int64
+	MaxBatchEvents int
+
+	/*
+	* keys in data, nested objects included
+	**/
+	MaxAttributes int
+
+	MaxKeyLength int
+
+	/*
+	* length of service, name and trace_id
+	**/
+	MaxFieldLength int
+
+	/*
+	* lowest sample_rate a client may send. each stored event
+	* stands for 1 / sample_rate in stats, so this caps how much
+	* one event can inflate them
+	**/
+	MinSampleRate float64
+
+	/*
+	* client timestamps within this distance of the server clock
+	* are kept, others are replaced. zero always uses server time
+	**/
+	TimestampSkew time.Duration
+}
+
+func (c *ValidationConfig) applyDefaults() {
+	if c.MaxEventBytes <= 0 {
+		c.MaxEventBytes = 256 << 10
+	}
+	if c.MaxBatchBytes <= 0 {
+		c.MaxBatchBytes = 4 << 20
+	}
+	if c.MaxBatchEvents <= 0 {
+		c.MaxBatchEvents = 1000
+	}
+	if c.MaxAttributes <= 0 {
+		c.MaxAttributes = 128
+	}
+	if c.MaxKeyLength <= 0 {
+		c.MaxKeyLength = 128
+	}
+	if c.MaxFieldLength <= 0 {
+		c.MaxFieldLength = 256
+	}
+	if c.MinSampleRate <= 0 {
+		c.MinSampleRate = 0.001
+	}
+}
+
+/*
+* client supplied ids, from the body or the Idempotency-Key header
+**/
+const maxIDLength = 128
+
+type Violation struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+/*
+* body of a rejected ingest request
+**/
+type ValidationError struct {
+	Error      string      `json:"error"`
+	Violations []Violation `json:"violations"`
+}
+
+/*
+* Normalize canonicalizes the event in place and reports every
+* rule it breaks. an empty level becomes info, an empty id is
+* left for the queue to fill in
+**/
+func (c ValidationConfig) Normalize(e *model.Event, now time.Time) []Violation {
+	c.applyDefaults()
+
+	var violations []Violation
+	violate := func(field, format string, args ...any) {
+		violations = append(violations, Violation{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(e.ID) > maxIDLength {
+		violate("id", "is longer than %d characters", maxIDLength)
+	} else if strings.IndexFunc(e.ID, invalidIDRune) >= 0 {
+		violate("id", "may only contain letters, digits and . _ : -")
+	}
+
+	e.Service = strings.TrimSpace(e.Service)
+	e.Name = strings.TrimSpace(e.Name)
+
+	fields := []struct {
+		name     string
+		value    string
+		required bool
+	}{
+		{"service", e.Service, true},
+		{"name", e.Name, true},
+		{"trace_id", e.TraceID, false},
+	}
+	for _, f := range fields {
+		if f.required && f.value == "" {
+			violate(f.name, "is required")
+		}
+		if len(f.value) > c.MaxFieldLength {
+			violate(f.name, "is longer than %d characters", c.MaxFieldLength)
+		}
+	}
+
+	if e.Level == "" {
+		e.Level = model.LEVEL_INFO
+	} else if level, ok := model.NormalizeLevel(e.Level); ok {
+		e.Level = level
+	} else {
+		violate("level", "%q is not one of %s", e.Level, strings.Join(model.Levels, ", "))
+	}
+
+	if e.SampleRate != 0 && (e.SampleRate < c.MinSampleRate || e.SampleRate > 1) {
+		violate("sample_rate", "must be between %g and 1", c.MinSampleRate)
+	}
+
+	attributes := 0
+	c.checkData("data", e.Data, &attributes, violate)
+	if attributes > c.MaxAttributes {
+		violate("data", "has %d attributes, at most %d are allowed", attributes, c.MaxAttributes)
+	}
+
+	if c.TimestampSkew <= 0 || e.Timestamp.IsZero() || absDuration(now.Sub(e.Timestamp)) > c.TimestampSkew {
+		e.Timestamp = now
+	}
+
+	// data is walked in map order, keep responses stable
+	slices.SortFunc(violations, func(a, b Violation) int {
+		return cmp.Or(cmp.Compare(a.Field, b.Field), cmp.Compare(a.Message, b.Message))
+	})
+	return violations
+}
+
+func (c ValidationConfig) checkData(path string, data map[string]any, attributes *int, violate func(string, string, ...any)) {
+	for key, value := range data {
+		*attributes++
+
+		field := path + "." + key
+		if key == "" {
+			violate(path, "has an empty key")
+		} else if len(key) > c.MaxKeyLength {
+			violate(field, "key is longer than %d characters", c.MaxKeyLength)
+		}
+
+		if nested, ok := value.(map[string]any); ok {
+			c.checkData(field, nested, attributes, violate)
+		}
+	}
+}
+
+func invalidIDRune(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return false
+	case r == '.', r == '_', r == ':', r == '-':
+		return false
+	}
+	return true
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,257 @@
+package ingest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xonoxc/scopion/internal/live"
+	"github.com/xonoxc/scopion/internal/model"
+)
+
+func TestNormalizeCanonicalizesLevels(t *testing.T) {
+	cases := map[string]string{
+		"ERR":     model.LEVEL_ERROR,
+		"Warning": model.LEVEL_WARN,
+		" info ":  model.LEVEL_INFO,
+		"":        model.LEVEL_INFO,
+		"CRIT":    model.LEVEL_FATAL,
+		"verbose": model.LEVEL_TRACE,
+	}
+
+	for level, want := range cases {
+		e := model.Event{Level: level, Service: "api", Name: "request"}
+		if violations := (ValidationConfig{}).Normalize(&e, time.Now()); len(violations) > 0 {
+			t.Errorf("Unexpected violations for %q: %v", level, violations)
+		}
+		if e.Level != want {
+			t.Errorf("Expected %q to become %q, got %q", level, want, e.Level)
+		}
+	}
+}
+
+func TestNormalizeReportsEveryViolation(t *testing.T) {
+	cfg := ValidationConfig{MaxAttributes: 2, MaxKeyLength: 4}
+	e := model.Event{
+		Level: "loud",
+		Name:  "  ",
+		Data: map[string]any{
+			"user":      map[string]any{"id": 1},
+			"very_long": true,
+		},
+	}
+
+	got := cfg.Normalize(&e, time.Now())
+	want := []Violation{
+		{"data", "has 3 attributes, at most 2 are allowed"},
+		{"data.very_long", "key is longer than 4 characters"},
+		{"level", `"loud" is not one of trace, debug, info, warn, error, fatal`},
+		{"name", "is required"},
+		{"service", "is required"},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d violations, got %v", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Expected %v, got %v", want[i], got[i])
+		}
+	}
+}
+
+func TestNormalizeBoundsClientSampleRate(t *testing.T) {
+	cfg := ValidationConfig{MinSampleRate: 0.01}
+
+	for rate, valid := range map[float64]bool{
+		0:     true,
+		0.01:  true,
+		0.5:   true,
+		1:     true,
+		1e-9:  false,
+		0.001: false,
+		-1:    false,
+		2:     false,
+	} {
+		e := model.Event{Service: "api", Name: "request", SampleRate: rate}
+		violations := cfg.Normalize(&e, time.Now())
+		if valid && len(violations) > 0 {
+			t.Errorf("Expected sample_rate %g to be accepted, got %v", rate, violations)
+		}
+		if !valid && (len(violations) != 1 || violations[0] != Violation{"sample_rate", "must be between 0.01 and 1"}) {
+			t.Errorf("Expected sample_rate %g to be rejected, got %v", rate, violations)
+		}
+	}
+
+	e := model.Event{Service: "api", Name: "request", SampleRate: 1e-9}
+	if violations := (ValidationConfig{}).Normalize(&e, time.Now()); len(violations) != 1 {
+		t.Errorf("Expected the default minimum to reject 1e-9, got %v", violations)
+	}
+}
+
+func TestNormalizeHonorsTimestampWithinSkew(t *testing.T) {
+	now := time.Now()
+	cfg := ValidationConfig{TimestampSkew: time.Minute}
+
+	e := model.Event{Service: "api", Name: "request", Timestamp: now.Add(-30 * time.Second)}
+	cfg.Normalize(&e, now)
+	if !e.Timestamp.Equal(now.Add(-30 * time.Second)) {
+		t.Errorf("Expected client timestamp to be kept, got %v", e.Timestamp)
+	}
+
+	e.Timestamp = now.Add(-time.Hour)
+	cfg.Normalize(&e, now)
+	if !e.Timestamp.Equal(now) {
+		t.Errorf("Expected timestamp outside the window to be replaced, got %v", e.Timestamp)
+	}
+
+	e.Timestamp = now.Add(-30 * time.Second)
+	(ValidationConfig{}).Normalize(&e, now)
+	if !e.Timestamp.Equal(now) {
+		t.Errorf("Expected server time without a skew window, got %v", e.Timestamp)
+	}
+}
+
+func TestHandlerRejectsInvalidEvents(t *testing.T) {
+	s := newMemStore()
+	q := newTestQueue(t, s, live.New())
+	handler := Handler(q, Pipeline{Validation: ValidationConfig{MaxEventBytes: 64}})
+
+	send := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("POST", "/ingest", strings.NewReader(body)))
+		return w
+	}
+
+	w := send(`{"level":"ERR","service":"api"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400, got %d", w.Code)
+	}
+	var body ValidationError
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Violations) != 1 || body.Violations[0].Field != "name" {
+		t.Errorf("Unexpected violations %+v", body.Violations)
+	}
+
+	if w := send(`{"service":"api","name":"` + strings.Repeat("x", 64) + `"}`); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413, got %d", w.Code)
+	}
+
+	if w := send(`{"level":"Warning","service":"api","name":"slow"}`); w.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d", w.Code)
+	}
+	waitFor(t, "event to be stored", func() bool { return s.count() == 1 })
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.events {
+		if e.Level != model.LEVEL_WARN {
+			t.Errorf("Expected stored level warn, got %q", e.Level)
+		}
+	}
+}
+
+func TestHandlerKeepsClientIDs(t *testing.T) {
+	s := newMemStore()
+	q := newTestQueue(t, s, live.New())
+	handler := Handler(q, Pipeline{})
+
+	send := func(body, key string) int {
+		req := httptest.NewRequest("POST", "/ingest", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(IDEMPOTENCY_HEADER, key)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	for range 2 {
+		if code := send(`{"service":"api","name":"retry"}`, "req-42"); code != http.StatusAccepted {
+			t.Fatalf("Expected 202, got %d", code)
+		}
+	}
+	if code := send(`{"id":"evt-1","service":"api","name":"retry"}`, ""); code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d", code)
+	}
+
+	if code := send(`{"id":"evt-2","service":"api","name":"retry"}`, "req-43"); code != http.StatusBadRequest {
+		t.Errorf("Expected mismatched id and key to be rejected, got %d", code)
+	}
+	if code := send(`{"id":"no spaces","service":"api","name":"retry"}`, ""); code != http.StatusBadRequest {
+		t.Errorf("Expected invalid id to be rejected, got %d", code)
+	}
+
+	waitFor(t, "queue to drain", func() bool { return q.Stats().Pending == 0 })
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.events) != 2 {
+		t.Fatalf("Expected 2 distinct events, got %d", len(s.events))
+	}
+	for _, id := range []string{"req-42", "evt-1"} {
+		if _, ok := s.events[id]; !ok {
+			t.Errorf("Expected event %s to keep its id", id)
+		}
+	}
+}
+
+func TestBatchHandlerReportsEachEvent(t *testing.T) {
+	s := newMemStore()
+	q := newTestQueue(t, s, live.New())
+	handler := BatchHandler(q, Pipeline{Validation: ValidationConfig{MaxEventBytes: 128, MaxBatchEvents: 4}})
+
+	send := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("POST", "/ingest/batch", strings.NewReader(body)))
+		return w
+	}
+
+	w := send(`[
+		{"id":"a","service":"api","name":"one"},
+		{"service":"api"},
+		"not an event",
+		{"id":"b","service":"api","name":"` + strings.Repeat("x", 128) + `"}
+	]`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d", w.Code)
+	}
+	var result model.BatchResult
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Accepted != 1 || len(result.Rejected) != 3 {
+		t.Fatalf("Expected 1 accepted and 3 rejected, got %+v", result)
+	}
+
+	want := []struct {
+		index, status int
+		field         string
+	}{
+		{1, http.StatusBadRequest, "name"},
+		{2, http.StatusBadRequest, "body"},
+		{3, http.StatusRequestEntityTooLarge, "body"},
+	}
+	for i, r := range result.Rejected {
+		if r.Index != want[i].index || r.Status != want[i].status || len(r.Violations) != 1 || r.Violations[0].Field != want[i].field {
+			t.Errorf("Unexpected rejection %+v, expected %+v", r, want[i])
+		}
+	}
+
+	if w := send(`[{},{},{},{},{}]`); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected a batch over the event limit to be rejected whole, got %d", w.Code)
+	}
+	if w := send(`{"service":"api","name":"one"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected a body that isn't an array to be rejected, got %d", w.Code)
+	}
+
+	waitFor(t, "event to be stored", func() bool { return s.count() == 1 })
+	if _, ok := s.events["a"]; !ok {
+		t.Error("Expected the valid event to keep its id")
+	}
+}
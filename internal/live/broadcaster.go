@@ -1,44 +1,214 @@
 package live
 
-import "github.com/xonoxc/scopion/internal/model"
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/xonoxc/scopion/internal/model"
+)
+
+/*
+* how many recent events are kept for subscribers
+* resuming after a dropped connection
+**/
+const replaySize = 1024
+
+/*
+* an event together with its position in the stream
+**/
+type Message struct {
+	Seq   uint64
+	Event model.Event
+}
+
+/*
+* a single live subscriber, only events matching
+* its filter are delivered on the channel
+**/
+type Subscription struct {
+	ch     chan Message
+	filter Filter
+	buffer int
+
+	/*
+	* resume point requested by the subscriber, with
+	* epoch identifying the broadcaster the seq came from
+	**/
+	resumeEpoch int64
+	resumeSeq   uint64
+
+	start   uint64
+	ready   chan struct{}
+	gap     bool
+	dropped atomic.Bool
+}
+
+func (s *Subscription) Events() <-chan Message {
+	return s.ch
+}
+
+/*
+* true when events were lost between the resume point and
+* the oldest event still held for replay
+**/
+func (s *Subscription) Gap() bool {
+	return s.gap
+}
+
+/*
+* seq after which delivery (including replay) starts
+**/
+func (s *Subscription) Start() uint64 {
+	return s.start
+}
+
+/*
+* true when the channel was closed because the subscriber
+* fell behind, rather than through Unsubscribe
+**/
+func (s *Subscription) Dropped() bool {
+	return s.dropped.Load()
+}
 
 type Broadcaster struct {
-	register   chan chan model.Event
-	unregister chan chan model.Event
+	register   chan *Subscription
+	unregister chan *Subscription
 	publish    chan model.Event
+
+	/*
+	* sequence numbers restart with the process, the epoch tells
+	* a resuming client its ids belong to an earlier run
+	**/
+	epoch int64
 }
 
 func New() *Broadcaster {
 	b := &Broadcaster{
-		register:   make(chan chan model.Event),
-		unregister: make(chan chan model.Event),
+		register:   make(chan *Subscription),
+		unregister: make(chan *Subscription),
 		publish:    make(chan model.Event, 1024),
+		epoch:      time.Now().UnixNano(),
 	}
 	go b.run()
 	return b
 }
 
+func (b *Broadcaster) Epoch() int64 {
+	return b.epoch
+}
+
 func (b *Broadcaster) run() {
-	clients := map[chan model.Event]struct{}{}
+	clients := map[*Subscription]struct{}{}
+	ring := make([]Message, replaySize)
+	var seq uint64
+
 	for {
 		select {
-		case c := <-b.register:
-			clients[c] = struct{}{}
-		case c := <-b.unregister:
-			delete(clients, c)
-			close(c)
+		case s := <-b.register:
+			b.attach(s, ring, seq)
+			clients[s] = struct{}{}
+			close(s.ready)
+		case s := <-b.unregister:
+			if _, ok := clients[s]; ok {
+				delete(clients, s)
+				close(s.ch)
+			}
 		case e := <-b.publish:
-			for c := range clients {
+			seq++
+			m := Message{Seq: seq, Event: e}
+			ring[(seq-1)%replaySize] = m
+
+			for s := range clients {
+				if !s.filter.Match(e) {
+					continue
+				}
 				select {
-				case c <- e:
+				case s.ch <- m:
 				default:
-					delete(clients, c)
+					s.dropped.Store(true)
+					delete(clients, s)
+					close(s.ch)
 				}
 			}
 		}
 	}
 }
 
+/*
+* creates the subscriber channel and queues whatever it missed
+* since its resume point. the ring holds seq n at (n-1) % replaySize
+**/
+func (b *Broadcaster) attach(s *Subscription, ring []Message, newest uint64) {
+	var missed []Message
+	s.start = newest
+
+	if s.resumeEpoch != 0 {
+		oldest := uint64(1)
+		if newest > replaySize {
+			oldest = newest - replaySize + 1
+		}
+
+		from := s.resumeSeq + 1
+		if s.resumeEpoch != b.epoch || from < oldest {
+			s.gap = true
+			from = oldest
+		}
+		s.start = from - 1
+
+		for n := from; n <= newest; n++ {
+			m := ring[(n-1)%replaySize]
+			if s.filter.Match(m.Event) {
+				missed = append(missed, m)
+			}
+		}
+	}
+
+	s.ch = make(chan Message, s.buffer+len(missed))
+	for _, m := range missed {
+		s.ch <- m
+	}
+}
+
+func (b *Broadcaster) Subscribe(filter Filter, buffer int) *Subscription {
+	return b.SubscribeFrom(filter, buffer, 0, 0)
+}
+
+/*
+* like Subscribe, but first replays the retained events after
+* seq when they were published by the broadcaster with this epoch.
+* Gap reports whether anything could not be replayed
+**/
+func (b *Broadcaster) SubscribeFrom(filter Filter, buffer int, epoch int64, seq uint64) *Subscription {
+	s := &Subscription{
+		filter:      filter,
+		buffer:      buffer,
+		resumeEpoch: epoch,
+		resumeSeq:   seq,
+		ready:       make(chan struct{}),
+	}
+	b.register <- s
+	<-s.ready
+	return s
+}
+
+func (b *Broadcaster) Unsubscribe(s *Subscription) {
+	b.unregister <- s
+}
+
 func (b *Broadcaster) Publish(e model.Event) {
 	b.publish <- e
 }
+
+/*
+* like Publish but never blocks, live delivery is best effort
+* so callers that must keep moving drop the event instead.
+* reports whether the event was queued
+**/
+func (b *Broadcaster) TryPublish(e model.Event) bool {
+	select {
+	case b.publish <- e:
+		return true
+	default:
+		return false
+	}
+}
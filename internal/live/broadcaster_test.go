@@ -0,0 +1,190 @@
+package live
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xonoxc/scopion/internal/model"
+)
+
+func receive(t *testing.T, sub *Subscription) Message {
+	t.Helper()
+
+	select {
+	case m, ok := <-sub.Events():
+		if !ok {
+			t.Fatal("Subscription closed unexpectedly")
+		}
+		return m
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for event")
+	}
+	return Message{}
+}
+
+func TestBroadcasterDeliversOnlyMatchingEvents(t *testing.T) {
+	b := New()
+
+	errors := b.Subscribe(Filter{Levels: []string{"error"}}, 4)
+	defer b.Unsubscribe(errors)
+	all := b.Subscribe(Filter{}, 4)
+	defer b.Unsubscribe(all)
+
+	b.Publish(model.Event{ID: "1", Level: "info"})
+	b.Publish(model.Event{ID: "2", Level: "error"})
+
+	for _, want := range []string{"1", "2"} {
+		if m := receive(t, all); m.Event.ID != want {
+			t.Errorf("Expected event %s, got %s", want, m.Event.ID)
+		}
+	}
+
+	if m := receive(t, errors); m.Event.ID != "2" {
+		t.Errorf("Expected only error event, got %s", m.Event.ID)
+	}
+}
+
+func TestBroadcasterReplaysAfterResumePoint(t *testing.T) {
+	b := New()
+
+	first := b.Subscribe(Filter{}, 8)
+	for _, id := range []string{"a", "b", "c"} {
+		b.Publish(model.Event{ID: id})
+	}
+	var last Message
+	for range 3 {
+		last = receive(t, first)
+	}
+	b.Unsubscribe(first)
+
+	b.Publish(model.Event{ID: "d"})
+	b.Publish(model.Event{ID: "e"})
+
+	// resume from "b"
+	resumed := b.SubscribeFrom(Filter{}, 8, b.Epoch(), last.Seq-1)
+	defer b.Unsubscribe(resumed)
+
+	if resumed.Gap() {
+		t.Error("Expected no gap when resuming inside the replay buffer")
+	}
+	for _, want := range []string{"c", "d", "e"} {
+		if m := receive(t, resumed); m.Event.ID != want {
+			t.Errorf("Expected replayed event %s, got %s", want, m.Event.ID)
+		}
+	}
+}
+
+func TestBroadcasterReportsGap(t *testing.T) {
+	b := New()
+
+	for i := range replaySize + 10 {
+		b.Publish(model.Event{ID: string(rune('a' + i%26))})
+	}
+	probe := b.Subscribe(Filter{}, 1)
+	b.Unsubscribe(probe)
+
+	stale := b.SubscribeFrom(Filter{}, 1, b.Epoch(), 1)
+	defer b.Unsubscribe(stale)
+	if !stale.Gap() {
+		t.Error("Expected gap when resume point fell out of the replay buffer")
+	}
+	if m := receive(t, stale); m.Seq != 11 {
+		t.Errorf("Expected replay to start at oldest retained seq 11, got %d", m.Seq)
+	}
+
+	otherRun := b.SubscribeFrom(Filter{}, 1, b.Epoch()-1, 5)
+	defer b.Unsubscribe(otherRun)
+	if !otherRun.Gap() {
+		t.Error("Expected gap when resuming with an id from another run")
+	}
+}
+
+func TestBroadcasterDropsSlowSubscriber(t *testing.T) {
+	b := New()
+
+	slow := b.Subscribe(Filter{}, 1)
+	defer b.Unsubscribe(slow)
+
+	b.Publish(model.Event{ID: "1"})
+	b.Publish(model.Event{ID: "2"})
+	b.Publish(model.Event{ID: "3"})
+
+	// let the broadcaster overflow the buffer before reading
+	time.Sleep(50 * time.Millisecond)
+
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-slow.Events():
+			if !ok {
+				if !slow.Dropped() {
+					t.Error("Expected subscription to be marked dropped")
+				}
+				return
+			}
+		case <-deadline:
+			t.Fatal("Expected slow subscriber to be dropped")
+		}
+	}
+}
+
+func TestSSEResumesWithLastEventID(t *testing.T) {
+	b := New()
+
+	warm := b.Subscribe(Filter{}, 4)
+	b.Publish(model.Event{ID: "one"})
+	b.Publish(model.Event{ID: "two"})
+	first := receive(t, warm)
+	receive(t, warm)
+	b.Unsubscribe(warm)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req := httptest.NewRequest("GET", "/api/live", nil).WithContext(ctx)
+	req.Header.Set("Last-Event-ID", FormatEventID(b.Epoch(), first.Seq))
+	w := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		SSE(b).ServeHTTP(w, req)
+		close(done)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected handler to return once the client disconnected")
+	}
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var ids, data []string
+	scanner := bufio.NewScanner(strings.NewReader(w.Body.String()))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if after, ok := strings.CutPrefix(line, "id: "); ok {
+			ids = append(ids, after)
+		}
+		if after, ok := strings.CutPrefix(line, "data: "); ok {
+			data = append(data, after)
+		}
+	}
+
+	if len(data) != 1 || !strings.Contains(data[0], `"id":"two"`) {
+		t.Errorf("Expected only event two to be replayed, got %v", data)
+	}
+	if len(ids) != 1 || ids[0] != FormatEventID(b.Epoch(), first.Seq+1) {
+		t.Errorf("Unexpected event ids %v", ids)
+	}
+}
@@ -0,0 +1,132 @@
+package live
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/xonoxc/scopion/internal/model"
+)
+
+/*
+* Filter decides which events a subscriber receives.
+* zero value matches everything in the default project
+**/
+type Filter struct {
+	/*
+	* set by the server from the request, subscribers
+	* only ever see events of their own project
+	**/
+	Project string `json:"-"`
+
+	Services []string `json:"services,omitempty"`
+	Levels   []string `json:"levels,omitempty"`
+
+	/*
+	* case insensitive substring of the event name
+	**/
+	Name string `json:"name,omitempty"`
+
+	/*
+	* substring of the trace id
+	**/
+	TraceID string `json:"trace_id,omitempty"`
+
+	/*
+	* fraction of traces to keep, 0 means no sampling.
+	* sampling is by trace id so a kept trace arrives whole
+	**/
+	SampleRate float64 `json:"sample_rate,omitempty"`
+}
+
+/*
+* reads the filter from query parameters:
+* service=api,worker level=error name=login trace_id=abc sample=0.1
+* service and level may also be repeated
+**/
+func ParseFilter(q url.Values) (Filter, error) {
+	f := Filter{
+		Services: splitList(q["service"]),
+		Levels:   splitList(q["level"]),
+		Name:     strings.TrimSpace(q.Get("name")),
+		TraceID:  strings.TrimSpace(q.Get("trace_id")),
+	}
+
+	if raw := q.Get("sample"); raw != "" {
+		rate, err := strconv.ParseFloat(raw, 64)
+		if err != nil || rate <= 0 || rate > 1 {
+			return Filter{}, fmt.Errorf("sample must be a number in (0, 1]")
+		}
+		f.SampleRate = rate
+	}
+
+	return f, nil
+}
+
+/*
+* checks a filter that did not come through ParseFilter,
+* e.g. one decoded from JSON
+**/
+func (f Filter) Validate() error {
+	if f.SampleRate < 0 || f.SampleRate > 1 {
+		return fmt.Errorf("sample_rate must be in [0, 1]")
+	}
+	return nil
+}
+
+func (f Filter) Match(e model.Event) bool {
+	if model.ProjectOrDefault(e.Project) != model.ProjectOrDefault(f.Project) {
+		return false
+	}
+	if len(f.Services) > 0 && !containsFold(f.Services, e.Service) {
+		return false
+	}
+	if len(f.Levels) > 0 && !containsFold(f.Levels, e.Level) {
+		return false
+	}
+	if f.Name != "" && !strings.Contains(strings.ToLower(e.Name), strings.ToLower(f.Name)) {
+		return false
+	}
+	if f.TraceID != "" && !strings.Contains(e.TraceID, f.TraceID) {
+		return false
+	}
+	if f.SampleRate > 0 && f.SampleRate < 1 && !sampled(e, f.SampleRate) {
+		return false
+	}
+	return true
+}
+
+func sampled(e model.Event, rate float64) bool {
+	key := e.TraceID
+	if key == "" {
+		key = e.ID
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(key))
+
+	return float64(h.Sum64()%10000) < rate*10000
+}
+
+func splitList(values []string) []string {
+	var out []string
+	for _, v := range values {
+		for part := range strings.SplitSeq(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				out = append(out, part)
+			}
+		}
+	}
+	return out
+}
+
+func containsFold(list []string, v string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, v) {
+			return true
+		}
+	}
+	return false
+}
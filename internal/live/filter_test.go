@@ -0,0 +1,83 @@
+package live
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/xonoxc/scopion/internal/model"
+)
+
+func TestParseFilter(t *testing.T) {
+	q, _ := url.ParseQuery("service=api,worker&service=auth&level=error&name=Login&trace_id=abc&sample=0.5")
+
+	f, err := ParseFilter(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(f.Services) != 3 || f.Services[2] != "auth" {
+		t.Errorf("Expected 3 services, got %v", f.Services)
+	}
+	if len(f.Levels) != 1 || f.Levels[0] != "error" {
+		t.Errorf("Expected level error, got %v", f.Levels)
+	}
+	if f.Name != "Login" || f.TraceID != "abc" || f.SampleRate != 0.5 {
+		t.Errorf("Unexpected filter %+v", f)
+	}
+
+	for _, bad := range []string{"sample=0", "sample=2", "sample=abc"} {
+		q, _ := url.ParseQuery(bad)
+		if _, err := ParseFilter(q); err == nil {
+			t.Errorf("Expected error for %s", bad)
+		}
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	e := model.Event{Service: "api", Level: "error", Name: "POST /login", TraceID: "trace-abc"}
+
+	tests := []struct {
+		filter Filter
+		want   bool
+	}{
+		{Filter{}, true},
+		{Filter{Services: []string{"worker", "API"}}, true},
+		{Filter{Services: []string{"worker"}}, false},
+		{Filter{Levels: []string{"info"}}, false},
+		{Filter{Name: "login"}, true},
+		{Filter{Name: "logout"}, false},
+		{Filter{TraceID: "abc"}, true},
+		{Filter{TraceID: "xyz"}, false},
+		{Filter{SampleRate: 1}, true},
+		{Filter{Project: "default"}, true},
+		{Filter{Project: "shop"}, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.filter.Match(e); got != tt.want {
+			t.Errorf("%+v.Match() = %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestFilterSamplingKeepsWholeTraces(t *testing.T) {
+	f := Filter{SampleRate: 0.3}
+
+	kept := 0
+	for i := range 1000 {
+		traceID := fmt.Sprintf("trace-%d", i)
+		first := f.Match(model.Event{TraceID: traceID, Name: "a"})
+		second := f.Match(model.Event{TraceID: traceID, Name: "b"})
+		if first != second {
+			t.Fatalf("Expected trace %s to be sampled consistently", traceID)
+		}
+		if first {
+			kept++
+		}
+	}
+
+	if kept < 200 || kept > 400 {
+		t.Errorf("Expected roughly 30%% of traces kept, got %d/1000", kept)
+	}
+}
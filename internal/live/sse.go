@@ -2,26 +2,124 @@ package live
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/xonoxc/scopion/internal/model"
+	"github.com/xonoxc/scopion/internal/api/middleware"
 )
 
+const (
+	heartbeatInterval = 15 * time.Second
+
+	/*
+	* how long browsers wait before reconnecting
+	**/
+	retryInterval = 3 * time.Second
+)
+
+/*
+* SSE streams live events. every event carries an id so a
+* reconnecting client resumes through Last-Event-ID (or the
+* last_event_id query parameter, as EventSource can't set headers).
+*
+* besides plain events the stream may send:
+*   event: gap      events between the resume point and the replay buffer were lost
+*   event: dropped  the client fell behind and is disconnected, it should reconnect
+* and ": heartbeat" comments to keep idle connections alive
+**/
 func SSE(b *Broadcaster) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := ParseFilter(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		filter.Project = middleware.ProjectFromRequest(r)
+
+		rc := http.NewResponseController(w)
+
+		lastID := r.Header.Get("Last-Event-ID")
+		if lastID == "" {
+			lastID = r.URL.Query().Get("last_event_id")
+		}
+		epoch, seq, _ := ParseEventID(lastID)
+
 		w.Header().Set("Content-Type", "text/event-stream")
 		w.Header().Set("Cache-Control", "no-cache")
 
-		ch := make(chan model.Event, 16)
-		b.register <- ch
-		defer func() { b.unregister <- ch }()
+		sub := b.SubscribeFrom(filter, 16, epoch, seq)
+		defer b.Unsubscribe(sub)
+
+		fmt.Fprintf(w, "retry: %d\n\n", retryInterval.Milliseconds())
+		if sub.Gap() {
+			writeControl(w, "gap", map[string]string{"last_event_id": lastID})
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
 
-		for e := range ch {
-			data, _ := json.Marshal(e)
-			w.Write([]byte("data: "))
-			w.Write(data)
-			w.Write([]byte("\n\n"))
-			w.(http.Flusher).Flush()
+			case <-heartbeat.C:
+				w.Write([]byte(": heartbeat\n\n"))
+				if err := rc.Flush(); err != nil {
+					return
+				}
+
+			case m, ok := <-sub.Events():
+				if !ok {
+					if sub.Dropped() {
+						writeControl(w, "dropped", map[string]string{"reason": "client too slow"})
+						rc.Flush()
+					}
+					return
+				}
+
+				data, _ := json.Marshal(m.Event)
+				fmt.Fprintf(w, "id: %s\ndata: %s\n\n", FormatEventID(b.Epoch(), m.Seq), data)
+				if err := rc.Flush(); err != nil {
+					return
+				}
+			}
 		}
 	}
 }
+
+func writeControl(w http.ResponseWriter, event string, payload any) {
+	data, _ := json.Marshal(payload)
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
+
+/*
+* event ids are "<epoch>-<seq>"
+**/
+func FormatEventID(epoch int64, seq uint64) string {
+	return strconv.FormatInt(epoch, 10) + "-" + strconv.FormatUint(seq, 10)
+}
+
+func ParseEventID(id string) (int64, uint64, bool) {
+	epochStr, seqStr, ok := strings.Cut(id, "-")
+	if !ok {
+		return 0, 0, false
+	}
+
+	epoch, err := strconv.ParseInt(epochStr, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	seq, err := strconv.ParseUint(seqStr, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return epoch, seq, true
+}
@@ -0,0 +1,308 @@
+package live
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/xonoxc/scopion/internal/api/middleware"
+	"github.com/xonoxc/scopion/internal/model"
+)
+
+const (
+	/*
+	* events queued per connection before the broadcaster
+	* considers the client too slow
+	**/
+	wsBuffer = 64
+
+	wsWriteTimeout = 10 * time.Second
+	wsPongTimeout  = 3 * heartbeatInterval
+
+	/*
+	* control messages are small, anything bigger is misbehaving
+	**/
+	wsMaxMessageSize = 64 * 1024
+)
+
+const (
+	WS_SUBSCRIBE   = "subscribe"
+	WS_UNSUBSCRIBE = "unsubscribe"
+	WS_PAUSE       = "pause"
+	WS_RESUME      = "resume"
+)
+
+/*
+* message sent by the client to change what it receives
+**/
+type WSCommand struct {
+	Type        string `json:"type"`
+	Filter      Filter `json:"filter"`
+	LastEventID string `json:"last_event_id,omitempty"`
+}
+
+/*
+* message sent to the client, Type is one of
+* event, subscribed, unsubscribed, paused, resumed, gap, dropped or error
+**/
+type WSMessage struct {
+	Type   string       `json:"type"`
+	ID     string       `json:"id,omitempty"`
+	Event  *model.Event `json:"event,omitempty"`
+	Filter *Filter      `json:"filter,omitempty"`
+	Error  string       `json:"error,omitempty"`
+}
+
+var upgrader = websocket.Upgrader{
+	EnableCompression: true,
+}
+
+/*
+* WebSocket streams live events like SSE but lets the client change
+* its filter, unsubscribe, pause and resume without reconnecting.
+* the initial subscription is taken from the same query parameters
+* as SSE. pausing keeps the position, so resume replays what was
+* published meanwhile (with a gap message if it no longer fits).
+* a new subscribe also continues from the current position
+**/
+func WebSocket(b *Broadcaster) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := ParseFilter(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		filter.Project = middleware.ProjectFromRequest(r)
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		conn.EnableWriteCompression(true)
+		conn.SetReadLimit(wsMaxMessageSize)
+
+		epoch, seq, _ := ParseEventID(r.URL.Query().Get("last_event_id"))
+
+		c := &wsConn{
+			conn:        conn,
+			broadcaster: b,
+			filter:      filter,
+			epoch:       epoch,
+			seq:         seq,
+		}
+		c.serve()
+	}
+}
+
+type wsRequest struct {
+	cmd WSCommand
+	err error
+}
+
+type wsConn struct {
+	conn        *websocket.Conn
+	broadcaster *Broadcaster
+
+	sub    *Subscription
+	filter Filter
+	paused bool
+
+	/*
+	* position of the last event delivered, used to resume
+	* after a pause or after being dropped for falling behind
+	**/
+	epoch int64
+	seq   uint64
+}
+
+func (c *wsConn) serve() {
+	requests := make(chan wsRequest)
+	done := make(chan struct{})
+	quit := make(chan struct{})
+	defer close(quit)
+	go c.readLoop(requests, done, quit)
+
+	defer c.unsubscribe()
+	if err := c.subscribe(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+
+		case <-heartbeat.C:
+			deadline := time.Now().Add(wsWriteTimeout)
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+				return
+			}
+
+		case req := <-requests:
+			if req.err != nil {
+				err := c.write(WSMessage{Type: "error", Error: "invalid message: " + req.err.Error()})
+				if err != nil {
+					return
+				}
+				continue
+			}
+			if err := c.handle(req.cmd); err != nil {
+				return
+			}
+
+		case m, ok := <-c.events():
+			if !ok {
+				if !c.sub.Dropped() {
+					return
+				}
+				/*
+				* fell behind, pick up again from the last delivered
+				* event instead of ending the stream
+				**/
+				c.sub = nil
+				if err := c.write(WSMessage{Type: "dropped"}); err != nil {
+					return
+				}
+				if err := c.subscribe(); err != nil {
+					return
+				}
+				continue
+			}
+
+			c.epoch, c.seq = c.broadcaster.Epoch(), m.Seq
+			err := c.write(WSMessage{
+				Type:  "event",
+				ID:    FormatEventID(c.epoch, m.Seq),
+				Event: &m.Event,
+			})
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+/*
+* reads client messages until the connection fails. a message
+* that isn't valid JSON is passed on as an error, not fatal
+**/
+func (c *wsConn) readLoop(requests chan<- wsRequest, done chan<- struct{}, quit <-chan struct{}) {
+	defer close(done)
+
+	c.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	})
+
+	for {
+		var req wsRequest
+		if err := c.conn.ReadJSON(&req.cmd); err != nil {
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			if !errors.As(err, &syntaxErr) && !errors.As(err, &typeErr) {
+				return
+			}
+			req.err = err
+		}
+		c.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+
+		select {
+		case requests <- req:
+		case <-quit:
+			return
+		}
+	}
+}
+
+/*
+* a nil channel blocks forever, so while paused or
+* unsubscribed the serve loop only waits on commands
+**/
+func (c *wsConn) events() <-chan Message {
+	if c.sub == nil {
+		return nil
+	}
+	return c.sub.Events()
+}
+
+func (c *wsConn) handle(cmd WSCommand) error {
+	switch cmd.Type {
+	case WS_SUBSCRIBE:
+		if err := cmd.Filter.Validate(); err != nil {
+			return c.write(WSMessage{Type: "error", Error: err.Error()})
+		}
+		c.unsubscribe()
+		project := c.filter.Project
+		c.filter = cmd.Filter
+		c.filter.Project = project
+		if epoch, seq, ok := ParseEventID(cmd.LastEventID); ok {
+			c.epoch, c.seq = epoch, seq
+		}
+		if c.paused {
+			return c.write(WSMessage{Type: "subscribed", Filter: &c.filter})
+		}
+		return c.subscribe()
+
+	case WS_UNSUBSCRIBE:
+		c.unsubscribe()
+		c.paused = false
+		return c.write(WSMessage{Type: "unsubscribed"})
+
+	case WS_PAUSE:
+		c.unsubscribe()
+		c.paused = true
+		return c.write(WSMessage{Type: "paused"})
+
+	case WS_RESUME:
+		if !c.paused {
+			return nil
+		}
+		c.paused = false
+		if err := c.write(WSMessage{Type: "resumed"}); err != nil {
+			return err
+		}
+		return c.subscribe()
+
+	default:
+		return c.write(WSMessage{Type: "error", Error: "unknown message type " + cmd.Type})
+	}
+}
+
+func (c *wsConn) subscribe() error {
+	resumeFrom := FormatEventID(c.epoch, c.seq)
+	c.sub = c.broadcaster.SubscribeFrom(c.filter, wsBuffer, c.epoch, c.seq)
+	c.epoch, c.seq = c.broadcaster.Epoch(), c.sub.Start()
+
+	if err := c.write(WSMessage{Type: "subscribed", Filter: &c.filter}); err != nil {
+		return err
+	}
+	if c.sub.Gap() {
+		return c.write(WSMessage{Type: "gap", ID: resumeFrom})
+	}
+	return nil
+}
+
+func (c *wsConn) unsubscribe() {
+	if c.sub == nil {
+		return
+	}
+	c.broadcaster.Unsubscribe(c.sub)
+
+	/*
+	* anything already queued is replayed on resume,
+	* as the position only moves when an event is written
+	**/
+	c.sub = nil
+}
+
+func (c *wsConn) write(m WSMessage) error {
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return c.conn.WriteJSON(m)
+}
@@ -0,0 +1,97 @@
+package live
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/xonoxc/scopion/internal/api/middleware"
+	"github.com/xonoxc/scopion/internal/model"
+)
+
+func dialWS(t *testing.T, b *Broadcaster, query string) *websocket.Conn {
+	t.Helper()
+
+	srv := httptest.NewServer(middleware.LoggingMiddleware(WebSocket(b)))
+	t.Cleanup(srv.Close)
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/ws" + query
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+func expectWS(t *testing.T, conn *websocket.Conn, msgType string) WSMessage {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var m WSMessage
+	if err := conn.ReadJSON(&m); err != nil {
+		t.Fatalf("Waiting for %s: %v", msgType, err)
+	}
+	if m.Type != msgType {
+		t.Fatalf("Expected %s message, got %+v", msgType, m)
+	}
+	return m
+}
+
+func TestWebSocketChangesFilterWithoutReconnecting(t *testing.T) {
+	b := New()
+	conn := dialWS(t, b, "?service=api")
+	expectWS(t, conn, "subscribed")
+
+	b.Publish(model.Event{ID: "1", Service: "worker"})
+	b.Publish(model.Event{ID: "2", Service: "api"})
+	if m := expectWS(t, conn, "event"); m.Event.ID != "2" {
+		t.Errorf("Expected api event, got %s", m.Event.ID)
+	}
+
+	conn.WriteJSON(WSCommand{Type: WS_SUBSCRIBE, Filter: Filter{Services: []string{"worker"}}})
+	if m := expectWS(t, conn, "subscribed"); len(m.Filter.Services) != 1 || m.Filter.Services[0] != "worker" {
+		t.Errorf("Unexpected filter %+v", m.Filter)
+	}
+
+	b.Publish(model.Event{ID: "3", Service: "api"})
+	b.Publish(model.Event{ID: "4", Service: "worker"})
+	if m := expectWS(t, conn, "event"); m.Event.ID != "4" {
+		t.Errorf("Expected worker event, got %s", m.Event.ID)
+	}
+
+	conn.WriteJSON(WSCommand{Type: WS_SUBSCRIBE, Filter: Filter{SampleRate: 2}})
+	expectWS(t, conn, "error")
+
+	conn.WriteMessage(websocket.TextMessage, []byte("not json"))
+	expectWS(t, conn, "error")
+}
+
+func TestWebSocketPauseResumeReplaysMissedEvents(t *testing.T) {
+	b := New()
+	conn := dialWS(t, b, "")
+	expectWS(t, conn, "subscribed")
+
+	conn.WriteJSON(WSCommand{Type: WS_PAUSE})
+	expectWS(t, conn, "paused")
+
+	b.Publish(model.Event{ID: "while-paused"})
+
+	conn.WriteJSON(WSCommand{Type: WS_RESUME})
+	expectWS(t, conn, "resumed")
+	expectWS(t, conn, "subscribed")
+
+	m := expectWS(t, conn, "event")
+	if m.Event.ID != "while-paused" {
+		t.Errorf("Expected replayed event, got %s", m.Event.ID)
+	}
+	if _, _, ok := ParseEventID(m.ID); !ok {
+		t.Errorf("Expected event id, got %q", m.ID)
+	}
+
+	conn.WriteJSON(WSCommand{Type: WS_UNSUBSCRIBE})
+	expectWS(t, conn, "unsubscribed")
+}
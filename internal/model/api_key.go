@@ -0,0 +1,47 @@
+package model
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+type APIKeyScope string
+
+/*
+* ingest keys may only write events,
+* read keys may only query them
+**/
+const (
+	SCOPE_INGEST APIKeyScope = "ingest"
+	SCOPE_READ   APIKeyScope = "read"
+)
+
+func (s APIKeyScope) Valid() bool {
+	return s == SCOPE_INGEST || s == SCOPE_READ
+}
+
+/*
+* only the hash of the key is stored, Prefix is the
+* start of the plain key so it can be recognised in lists
+**/
+type APIKey struct {
+	ID        string        `json:"id"`
+	Name      string        `json:"name"`
+	Project   string        `json:"project"`
+	Prefix    string        `json:"prefix"`
+	Hash      string        `json:"-"`
+	Scopes    []APIKeyScope `json:"scopes"`
+	CreatedAt time.Time     `json:"created_at"`
+	RevokedAt *time.Time    `json:"revoked_at,omitempty"`
+}
+
+func (k *APIKey) HasScope(scope APIKeyScope) bool {
+	return slices.Contains(k.Scopes, scope)
+}
+
+func (k *APIKey) Revoked() bool {
+	return k.RevokedAt != nil
+}
@@ -1,6 +1,5 @@
 package model
 
-type ErrorByService struct {
-	Service string `json:"service"`
-	Count   int    `json:"count"`
-}
+import "github.com/xonoxc/scopion/clients/go/types"
+
+type ErrorByService = types.ErrorByService
@@ -1,13 +1,30 @@
 package model
 
-import "time"
-
-type Event struct {
-	ID        string         `json:"id"`
-	Timestamp time.Time      `json:"timestamp"`
-	Level     string         `json:"level"`
-	Service   string         `json:"service"`
-	Name      string         `json:"name"`
-	TraceID   string         `json:"trace_id"`
-	Data      map[string]any `json:"data,omitempty"`
+import "github.com/xonoxc/scopion/clients/go/types"
+
+/*
+* project events belong to when none is given
+**/
+const DEFAULT_PROJECT = "default"
+
+/*
+* defined with the go client so the api can't drift from it
+**/
+type Event = types.Event
+
+func ProjectOrDefault(project string) string {
+	if project == "" {
+		return DEFAULT_PROJECT
+	}
+	return project
+}
+
+/*
+* an unsampled event stands for itself
+**/
+func SampleRateOrOne(rate float64) float64 {
+	if rate <= 0 {
+		return 1
+	}
+	return rate
 }
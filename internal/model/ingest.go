@@ -0,0 +1,9 @@
+package model
+
+import "github.com/xonoxc/scopion/clients/go/types"
+
+type (
+	Violation      = types.Violation
+	BatchResult    = types.BatchResult
+	BatchRejection = types.BatchRejection
+)
@@ -0,0 +1,76 @@
+package model
+
+import (
+	"slices"
+	"strings"
+)
+
+/*
+* canonical event levels, from least to most severe
+**/
+const (
+	LEVEL_TRACE = "trace"
+	LEVEL_DEBUG = "debug"
+	LEVEL_INFO  = "info"
+	LEVEL_WARN  = "warn"
+	LEVEL_ERROR = "error"
+	LEVEL_FATAL = "fatal"
+)
+
+var Levels = []string{LEVEL_TRACE, LEVEL_DEBUG, LEVEL_INFO, LEVEL_WARN, LEVEL_ERROR, LEVEL_FATAL}
+
+/*
+* levels stats, traces and sampling count as errors
+**/
+var ErrorLevels = []string{LEVEL_ERROR, LEVEL_FATAL}
+
+func IsErrorLevel(level string) bool {
+	return slices.Contains(ErrorLevels, level)
+}
+
+/*
+* ErrorLevelSQL is a condition on the level column
+* matching the ErrorLevels, for the stores' queries
+**/
+func ErrorLevelSQL() string {
+	quoted := make([]string, len(ErrorLevels))
+	for i, level := range ErrorLevels {
+		quoted[i] = "'" + level + "'"
+	}
+	return "level IN (" + strings.Join(quoted, ", ") + ")"
+}
+
+/*
+* spellings other loggers use, matched case insensitively
+**/
+var levelAliases = map[string]string{
+	"trace":       LEVEL_TRACE,
+	"trc":         LEVEL_TRACE,
+	"verbose":     LEVEL_TRACE,
+	"debug":       LEVEL_DEBUG,
+	"dbg":         LEVEL_DEBUG,
+	"info":        LEVEL_INFO,
+	"inf":         LEVEL_INFO,
+	"information": LEVEL_INFO,
+	"notice":      LEVEL_INFO,
+	"warn":        LEVEL_WARN,
+	"wrn":         LEVEL_WARN,
+	"warning":     LEVEL_WARN,
+	"error":       LEVEL_ERROR,
+	"err":         LEVEL_ERROR,
+	"fatal":       LEVEL_FATAL,
+	"crit":        LEVEL_FATAL,
+	"critical":    LEVEL_FATAL,
+	"panic":       LEVEL_FATAL,
+	"alert":       LEVEL_FATAL,
+	"emerg":       LEVEL_FATAL,
+	"emergency":   LEVEL_FATAL,
+}
+
+/*
+* maps a level or one of its aliases onto the canonical level
+**/
+func NormalizeLevel(level string) (string, bool) {
+	canonical, ok := levelAliases[strings.ToLower(strings.TrimSpace(level))]
+	return canonical, ok
+}
@@ -1,10 +1,5 @@
 package model
 
-import "time"
+import "github.com/xonoxc/scopion/clients/go/types"
 
-type ServiceInfo struct {
-	Name         string    `json:"name"`
-	ErrorCount   int       `json:"error_count"`
-	LastActivity time.Time `json:"last_activity"`
-	EventCount   int       `json:"event_count"`
-}
+type ServiceInfo = types.ServiceInfo
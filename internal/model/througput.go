@@ -1,6 +1,5 @@
 package model
 
-type ThroughputData struct {
-	Time   string `json:"time"`
-	Events int    `json:"events"`
-}
+import "github.com/xonoxc/scopion/clients/go/types"
+
+type ThroughputData = types.ThroughputData
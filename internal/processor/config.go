@@ -0,0 +1,43 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+/*
+* Config is the processors section of the server config,
+* the zero value runs the built in redaction rules only
+**/
+type Config struct {
+	Enrich EnrichConfig `json:"enrich"`
+	Redact RedactConfig `json:"redact"`
+}
+
+func LoadConfig(path string) (Config, error) {
+	var cfg Config
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read processor config: %w", err)
+	}
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse processor config: %w", err)
+	}
+
+	return cfg, nil
+}
+
+/*
+* New builds the chain events pass through at ingest. enrichment
+* runs first so what it adds is redacted like anything else
+**/
+func New(cfg Config) (Chain, error) {
+	redactor, err := NewRedactor(cfg.Redact)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(NewEnrichers(cfg.Enrich), redactor), nil
+}
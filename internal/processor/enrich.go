@@ -0,0 +1,180 @@
+package processor
+
+import (
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/xonoxc/scopion/internal/model"
+)
+
+/*
+* EnrichConfig picks the enrichment processors, all are off by default.
+* derived attributes never replace ones the client sent, key tags
+* and teams do since they come from the server
+**/
+type EnrichConfig struct {
+	/*
+	* adds received_at, and client_time and clock_skew_ms
+	* when the client sent a timestamp
+	**/
+	ReceiveTime bool `json:"receive_time"`
+
+	/*
+	* adds client.ip and client.user_agent. with TrustProxy the ip
+	* is the first X-Forwarded-For address, only enable it behind a proxy
+	**/
+	Client     bool `json:"client"`
+	TrustProxy bool `json:"trust_proxy"`
+
+	/*
+	* splits names like "GET /users" into http.method and http.route
+	**/
+	HTTPNames bool `json:"http_names"`
+
+	/*
+	* attributes added to every event sent with a key,
+	* looked up by key name or id
+	**/
+	KeyTags map[string]map[string]string `json:"key_tags"`
+
+	/*
+	* owning team of each service, added as team
+	**/
+	ServiceTeams map[string]string `json:"service_teams"`
+}
+
+func NewEnrichers(cfg EnrichConfig) Chain {
+	var chain Chain
+
+	if cfg.ReceiveTime {
+		chain = append(chain, ReceiveTime{})
+	}
+	if cfg.Client {
+		chain = append(chain, ClientInfo{TrustProxy: cfg.TrustProxy})
+	}
+	if cfg.HTTPNames {
+		chain = append(chain, HTTPName{})
+	}
+	if len(cfg.KeyTags) > 0 {
+		chain = append(chain, KeyTags(cfg.KeyTags))
+	}
+	if len(cfg.ServiceTeams) > 0 {
+		chain = append(chain, ServiceTeams(cfg.ServiceTeams))
+	}
+
+	return chain
+}
+
+type ReceiveTime struct{}
+
+func (ReceiveTime) Name() string {
+	return "receive_time"
+}
+
+func (ReceiveTime) Process(e *model.Event, src Source) {
+	if src.ReceivedAt.IsZero() {
+		return
+	}
+
+	setDefault(e, "received_at", src.ReceivedAt.UTC().Format(time.RFC3339Nano))
+	if !src.ClientTime.IsZero() {
+		setDefault(e, "client_time", src.ClientTime.UTC().Format(time.RFC3339Nano))
+		setDefault(e, "clock_skew_ms", src.ReceivedAt.Sub(src.ClientTime).Milliseconds())
+	}
+}
+
+type ClientInfo struct {
+	TrustProxy bool
+}
+
+func (ClientInfo) Name() string {
+	return "client"
+}
+
+func (c ClientInfo) Process(e *model.Event, src Source) {
+	if ip := c.ip(src); ip != "" {
+		setDefault(e, "client.ip", ip)
+	}
+	if src.UserAgent != "" {
+		setDefault(e, "client.user_agent", src.UserAgent)
+	}
+}
+
+func (c ClientInfo) ip(src Source) string {
+	if c.TrustProxy && src.ForwardedFor != "" {
+		first, _, _ := strings.Cut(src.ForwardedFor, ",")
+		return strings.TrimSpace(first)
+	}
+
+	host, _, err := net.SplitHostPort(src.RemoteAddr)
+	if err != nil {
+		return src.RemoteAddr
+	}
+	return host
+}
+
+var httpNamePattern = regexp.MustCompile(`^(GET|HEAD|POST|PUT|PATCH|DELETE|OPTIONS|CONNECT|TRACE) (/\S*)$`)
+
+type HTTPName struct{}
+
+func (HTTPName) Name() string {
+	return "http_names"
+}
+
+func (HTTPName) Process(e *model.Event, _ Source) {
+	match := httpNamePattern.FindStringSubmatch(e.Name)
+	if match == nil {
+		return
+	}
+
+	setDefault(e, "http.method", match[1])
+	setDefault(e, "http.route", match[2])
+}
+
+type KeyTags map[string]map[string]string
+
+func (KeyTags) Name() string {
+	return "key_tags"
+}
+
+func (k KeyTags) Process(e *model.Event, src Source) {
+	if src.Key == nil {
+		return
+	}
+
+	tags, ok := k[src.Key.ID]
+	if !ok {
+		tags = k[src.Key.Name]
+	}
+	for key, value := range tags {
+		set(e, key, value)
+	}
+}
+
+type ServiceTeams map[string]string
+
+func (ServiceTeams) Name() string {
+	return "service_teams"
+}
+
+func (s ServiceTeams) Process(e *model.Event, _ Source) {
+	if team, ok := s[e.Service]; ok {
+		set(e, "team", team)
+	}
+}
+
+func set(e *model.Event, key string, value any) {
+	if e.Data == nil {
+		e.Data = map[string]any{}
+	}
+	e.Data[key] = value
+}
+
+func setDefault(e *model.Event, key string, value any) {
+	if _, ok := e.Data[key]; ok {
+		return
+	}
+	set(e, key, value)
+}
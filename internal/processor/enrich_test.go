@@ -0,0 +1,73 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/xonoxc/scopion/internal/model"
+)
+
+func TestReceiveTime(t *testing.T) {
+	received := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	e := model.Event{}
+	ReceiveTime{}.Process(&e, Source{ReceivedAt: received, ClientTime: received.Add(-1500 * time.Millisecond)})
+
+	if e.Data["received_at"] != "2024-05-01T12:00:00Z" || e.Data["client_time"] != "2024-05-01T11:59:58.5Z" {
+		t.Errorf("Unexpected times %v", e.Data)
+	}
+	if e.Data["clock_skew_ms"] != int64(1500) {
+		t.Errorf("Expected skew of 1500ms, got %v", e.Data["clock_skew_ms"])
+	}
+}
+
+func TestClientInfo(t *testing.T) {
+	src := Source{RemoteAddr: "10.0.0.1:4321", ForwardedFor: "203.0.113.7, 10.0.0.1", UserAgent: "curl/8.0"}
+
+	e := model.Event{}
+	ClientInfo{}.Process(&e, src)
+	if e.Data["client.ip"] != "10.0.0.1" || e.Data["client.user_agent"] != "curl/8.0" {
+		t.Errorf("Unexpected client info %v", e.Data)
+	}
+
+	e = model.Event{}
+	ClientInfo{TrustProxy: true}.Process(&e, src)
+	if e.Data["client.ip"] != "203.0.113.7" {
+		t.Errorf("Expected forwarded address, got %v", e.Data["client.ip"])
+	}
+}
+
+func TestHTTPName(t *testing.T) {
+	e := model.Event{Name: "GET /users/{id}", Data: map[string]any{"http.route": "/users/:id"}}
+	HTTPName{}.Process(&e, Source{})
+
+	if e.Data["http.method"] != "GET" || e.Data["http.route"] != "/users/:id" {
+		t.Errorf("Expected method to be added and client route kept, got %v", e.Data)
+	}
+
+	e = model.Event{Name: "GET users"}
+	HTTPName{}.Process(&e, Source{})
+	if e.Data != nil {
+		t.Errorf("Expected other names to be left alone, got %v", e.Data)
+	}
+}
+
+func TestKeyTagsAndServiceTeams(t *testing.T) {
+	chain := NewEnrichers(EnrichConfig{
+		KeyTags:      map[string]map[string]string{"ci": {"env": "prod", "region": "eu"}},
+		ServiceTeams: map[string]string{"checkout": "payments"},
+	})
+
+	e := model.Event{Service: "checkout", Data: map[string]any{"env": "dev"}}
+	chain.Process(&e, Source{Key: &model.APIKey{ID: "k1", Name: "ci"}})
+
+	if e.Data["env"] != "prod" || e.Data["region"] != "eu" || e.Data["team"] != "payments" {
+		t.Errorf("Unexpected tags %v", e.Data)
+	}
+
+	e = model.Event{Service: "search"}
+	chain.Process(&e, Source{})
+	if e.Data != nil {
+		t.Errorf("Expected no tags without a key or team, got %v", e.Data)
+	}
+}
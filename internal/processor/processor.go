@@ -0,0 +1,66 @@
+package processor
+
+import (
+	"time"
+
+	"github.com/xonoxc/scopion/internal/model"
+)
+
+/*
+* Source describes where an event came from,
+* fields are empty when unknown (syslog has no key or user agent)
+**/
+type Source struct {
+	ReceivedAt time.Time
+
+	/*
+	* timestamp the client sent, before validation replaced it
+	**/
+	ClientTime time.Time
+
+	RemoteAddr   string
+	ForwardedFor string
+	UserAgent    string
+	Key          *model.APIKey
+}
+
+/*
+* Processor rewrites an event at ingest, after validation
+* and before it is queued, stored or broadcast
+**/
+type Processor interface {
+	Name() string
+	Process(e *model.Event, src Source)
+}
+
+/*
+* processors that count what they did, the counts
+* are reported by the ingest status endpoint
+**/
+type Reporter interface {
+	Stats() map[string]uint64
+}
+
+/*
+* Chain runs processors in order, a nil chain does nothing
+**/
+type Chain []Processor
+
+func (c Chain) Process(e *model.Event, src Source) {
+	for _, p := range c {
+		p.Process(e, src)
+	}
+}
+
+/*
+* counts of every reporting processor, keyed by processor name
+**/
+func (c Chain) Stats() map[string]map[string]uint64 {
+	stats := map[string]map[string]uint64{}
+	for _, p := range c {
+		if r, ok := p.(Reporter); ok {
+			stats[p.Name()] = r.Stats()
+		}
+	}
+	return stats
+}
@@ -0,0 +1,312 @@
+package processor
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/xonoxc/scopion/internal/model"
+)
+
+type RedactAction string
+
+const (
+	/*
+	* replaces the value (or match) with [REDACTED:<rule>]
+	**/
+	ACTION_MASK RedactAction = "mask"
+
+	/*
+	* replaces it with a keyed hash, equal values stay
+	* comparable without being readable
+	**/
+	ACTION_HASH RedactAction = "hash"
+
+	/*
+	* removes the whole attribute
+	**/
+	ACTION_DROP RedactAction = "drop"
+)
+
+func (a RedactAction) Valid() bool {
+	switch a {
+	case ACTION_MASK, ACTION_HASH, ACTION_DROP:
+		return true
+	}
+	return false
+}
+
+/*
+* RedactRule matches data attributes by key name, or string values
+* by pattern. key names match case insensitively with - and _ alike
+**/
+type RedactRule struct {
+	Name    string       `json:"name"`
+	Keys    []string     `json:"keys,omitempty"`
+	Pattern string       `json:"pattern,omitempty"`
+	Action  RedactAction `json:"action"`
+}
+
+type RedactConfig struct {
+	/*
+	* the built in email, credit card, jwt, bearer token
+	* and secret key rules run after the configured ones
+	**/
+	DisableBuiltin bool `json:"disable_builtin"`
+
+	/*
+	* key for hashed values, without one hashes of
+	* guessable values like emails can be reversed
+	**/
+	HashKey string `json:"hash_key"`
+
+	Rules []RedactRule `json:"rules"`
+}
+
+var builtinRules = []RedactRule{
+	{
+		Name:   "secret_keys",
+		Keys:   []string{"password", "passwd", "secret", "client_secret", "token", "access_token", "refresh_token", "authorization", "api_key", "apikey", "cookie"},
+		Action: ACTION_MASK,
+	},
+	{
+		Name:    "jwt",
+		Pattern: `\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`,
+		Action:  ACTION_MASK,
+	},
+	{
+		Name:    "bearer_token",
+		Pattern: `(?i)\bbearer\s+[A-Za-z0-9\-._~+/]+=*`,
+		Action:  ACTION_MASK,
+	},
+	{
+		Name:    "email",
+		Pattern: `[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`,
+		Action:  ACTION_MASK,
+	},
+	{
+		Name:    "credit_card",
+		Pattern: `\b\d(?:[ -]?\d){12,18}\b`,
+		Action:  ACTION_MASK,
+	},
+}
+
+/*
+* matches that also have to pass a check, so order
+* ids and timestamps aren't taken for card numbers
+**/
+var builtinChecks = map[string]func(string) bool{
+	"credit_card": luhn,
+}
+
+type redactRule struct {
+	name    string
+	keys    map[string]bool
+	pattern *regexp.Regexp
+	check   func(string) bool
+	action  RedactAction
+	count   atomic.Uint64
+}
+
+/*
+* Redactor scrubs personal data and credentials from event data
+**/
+type Redactor struct {
+	keyRules     []*redactRule
+	patternRules []*redactRule
+	hashKey      []byte
+}
+
+func NewRedactor(cfg RedactConfig) (*Redactor, error) {
+	r := &Redactor{hashKey: []byte(cfg.HashKey)}
+
+	rules := cfg.Rules
+	if !cfg.DisableBuiltin {
+		rules = append(rules[:len(rules):len(rules)], builtinRules...)
+	}
+
+	seen := map[string]bool{}
+	for i, rule := range rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("redact: rules[%d] has no name", i)
+		}
+		if seen[rule.Name] {
+			return nil, fmt.Errorf("redact: rule %q is defined twice", rule.Name)
+		}
+		seen[rule.Name] = true
+
+		if !rule.Action.Valid() {
+			return nil, fmt.Errorf("redact: rule %q: unknown action %q", rule.Name, rule.Action)
+		}
+		if (len(rule.Keys) == 0) == (rule.Pattern == "") {
+			return nil, fmt.Errorf("redact: rule %q needs either keys or a pattern", rule.Name)
+		}
+
+		compiled := &redactRule{name: rule.Name, action: rule.Action}
+		if rule.Pattern != "" {
+			pattern, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("redact: rule %q: %w", rule.Name, err)
+			}
+			compiled.pattern = pattern
+			compiled.check = builtinChecks[rule.Name]
+			r.patternRules = append(r.patternRules, compiled)
+			continue
+		}
+
+		compiled.keys = map[string]bool{}
+		for _, key := range rule.Keys {
+			compiled.keys[normalizeKey(key)] = true
+		}
+		r.keyRules = append(r.keyRules, compiled)
+	}
+
+	return r, nil
+}
+
+func (r *Redactor) Name() string {
+	return "redact"
+}
+
+func (r *Redactor) Process(e *model.Event, _ Source) {
+	r.redactMap(e.Data)
+}
+
+/*
+* redactions per rule since start
+**/
+func (r *Redactor) Stats() map[string]uint64 {
+	stats := map[string]uint64{}
+	for _, rule := range append(r.keyRules[:len(r.keyRules):len(r.keyRules)], r.patternRules...) {
+		stats[rule.name] = rule.count.Load()
+	}
+	return stats
+}
+
+func (r *Redactor) redactMap(data map[string]any) {
+	for key, value := range data {
+		if rule := r.keyRule(key); rule != nil {
+			rule.count.Add(1)
+			if rule.action == ACTION_DROP {
+				delete(data, key)
+			} else {
+				data[key] = r.replace(rule, value)
+			}
+			continue
+		}
+
+		if redacted, keep := r.redactValue(value); keep {
+			data[key] = redacted
+		} else {
+			delete(data, key)
+		}
+	}
+}
+
+/*
+* returns the redacted value, or false when a drop rule matched
+**/
+func (r *Redactor) redactValue(value any) (any, bool) {
+	switch v := value.(type) {
+	case map[string]any:
+		r.redactMap(v)
+		return v, true
+	case []any:
+		kept := v[:0]
+		for _, item := range v {
+			if redacted, keep := r.redactValue(item); keep {
+				kept = append(kept, redacted)
+			}
+		}
+		return kept, true
+	case string:
+		return r.redactString(v)
+	}
+	return value, true
+}
+
+func (r *Redactor) redactString(s string) (string, bool) {
+	for _, rule := range r.patternRules {
+		dropped := false
+		s = rule.pattern.ReplaceAllStringFunc(s, func(match string) string {
+			if rule.check != nil && !rule.check(match) {
+				return match
+			}
+			rule.count.Add(1)
+			if rule.action == ACTION_DROP {
+				dropped = true
+				return match
+			}
+			return r.replace(rule, match).(string)
+		})
+		if dropped {
+			return "", false
+		}
+	}
+	return s, true
+}
+
+func (r *Redactor) keyRule(key string) *redactRule {
+	key = normalizeKey(key)
+	for _, rule := range r.keyRules {
+		if rule.keys[key] {
+			return rule
+		}
+	}
+	return nil
+}
+
+func (r *Redactor) replace(rule *redactRule, value any) any {
+	if rule.action != ACTION_HASH {
+		return "[REDACTED:" + rule.name + "]"
+	}
+
+	raw, ok := value.(string)
+	if !ok {
+		encoded, _ := json.Marshal(value)
+		raw = string(encoded)
+	}
+
+	var sum []byte
+	if len(r.hashKey) > 0 {
+		mac := hmac.New(sha256.New, r.hashKey)
+		mac.Write([]byte(raw))
+		sum = mac.Sum(nil)
+	} else {
+		digest := sha256.Sum256([]byte(raw))
+		sum = digest[:]
+	}
+	return "hash:" + hex.EncodeToString(sum[:12])
+}
+
+func normalizeKey(key string) string {
+	return strings.ReplaceAll(strings.ToLower(key), "-", "_")
+}
+
+/*
+* Luhn checksum over the digits of a card number candidate
+**/
+func luhn(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
@@ -0,0 +1,308 @@
+package runner
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/xonoxc/scopion/internal/model"
+)
+
+const (
+	STREAM_STDOUT = "stdout"
+	STREAM_STDERR = "stderr"
+
+	/*
+	* longest line we keep, anything past this is cut off
+	* and never held in memory
+	**/
+	maxLineSize = 1024 * 1024
+
+	/*
+	* plain text lines become the event name, cut to this to keep
+	* them readable in the dashboard. longer lines are kept whole
+	* under data.message
+	**/
+	maxNameLength = 256
+)
+
+/*
+* Config describes the process to wrap
+* Stdout / Stderr are optional passthrough writers so the
+* wrapped output still shows up where cron expects it
+**/
+type Config struct {
+	Service string
+	TraceID string
+	Command string
+	Args    []string
+
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+type Result struct {
+	TraceID    string
+	ExitCode   int
+	Duration   time.Duration
+	Lines      int
+	SendErrors int
+}
+
+type line struct {
+	stream string
+	text   string
+
+	/*
+	* the line was longer than maxLineSize
+	**/
+	truncated bool
+}
+
+/*
+* Run launches the command and ships its lifecycle and
+* output to the sink as events sharing one trace id.
+* a non zero exit code of the child is not an error,
+* it is reported through Result.ExitCode
+**/
+func Run(ctx context.Context, cfg Config, sink Sink) (*Result, error) {
+	if cfg.Service == "" {
+		return nil, errors.New("service is required")
+	}
+	if cfg.Command == "" {
+		return nil, errors.New("command is required")
+	}
+
+	res := &Result{TraceID: cfg.TraceID}
+	if res.TraceID == "" {
+		res.TraceID = uuid.NewString()
+	}
+
+	send := func(e model.Event) {
+		if err := sink.Send(e); err != nil {
+			res.SendErrors++
+			log.Printf("scopion run: failed to ship event: %v", err)
+		}
+	}
+
+	cmd := exec.CommandContext(ctx, cfg.Command, cfg.Args...)
+	cmd.Stdin = cfg.Stdin
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach stdout: %w", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach stderr: %w", err)
+	}
+
+	start := time.Now()
+	send(newEvent(cfg, res.TraceID, "info", "process.start", map[string]any{
+		"command": cfg.Command,
+		"args":    cfg.Args,
+	}))
+
+	if err := cmd.Start(); err != nil {
+		res.ExitCode = -1
+		res.Duration = time.Since(start)
+		send(exitEvent(cfg, res, err))
+		return res, fmt.Errorf("failed to start command: %w", err)
+	}
+
+	lines := make(chan line, 256)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go readLines(stdout, STREAM_STDOUT, cfg.Stdout, lines, &wg)
+	go readLines(stderr, STREAM_STDERR, cfg.Stderr, lines, &wg)
+	go func() {
+		wg.Wait()
+		close(lines)
+	}()
+
+	/*
+	* single consumer keeps events in the order they were read
+	**/
+	for l := range lines {
+		res.Lines++
+		send(lineEvent(cfg, res.TraceID, l))
+	}
+
+	waitErr := cmd.Wait()
+	res.Duration = time.Since(start)
+
+	if waitErr != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(waitErr, &exitErr) {
+			res.ExitCode = -1
+			send(exitEvent(cfg, res, waitErr))
+			return res, fmt.Errorf("failed to wait for command: %w", waitErr)
+		}
+		res.ExitCode = exitErr.ExitCode()
+	}
+
+	send(exitEvent(cfg, res, nil))
+
+	return res, nil
+}
+
+/*
+* reads r line by line until EOF, copying raw output to
+* passthrough when set. the pipe is always drained fully
+* so the child never blocks on a full buffer
+**/
+func readLines(r io.Reader, stream string, passthrough io.Writer, out chan<- line, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	br := bufio.NewReaderSize(r, maxLineSize)
+
+	/*
+	* set while the rest of an overlong line is
+	* passed through but not kept
+	**/
+	skipping := false
+	for {
+		chunk, err := br.ReadSlice('\n')
+		if passthrough != nil && len(chunk) > 0 {
+			passthrough.Write(chunk)
+		}
+
+		full := errors.Is(err, bufio.ErrBufferFull)
+		if !skipping {
+			text := strings.TrimRight(string(chunk), "\r\n")
+			if full {
+				text = wholeRunes(text)
+			}
+			if strings.TrimSpace(text) != "" {
+				out <- line{stream: stream, text: text, truncated: full}
+			}
+		}
+		skipping = full
+
+		if err != nil && !full {
+			return
+		}
+	}
+}
+
+/*
+* stdout lines are info, stderr lines are error.
+* lines holding a JSON object are parsed into Data and may
+* override the level and name through level / msg / message
+**/
+func lineEvent(cfg Config, traceID string, l line) model.Event {
+	level := "info"
+	if l.stream == STREAM_STDERR {
+		level = "error"
+	}
+
+	name := l.text
+	data := map[string]any{}
+
+	trimmed := strings.TrimSpace(l.text)
+	if strings.HasPrefix(trimmed, "{") {
+		var obj map[string]any
+		if err := json.Unmarshal([]byte(trimmed), &obj); err == nil {
+			data = obj
+			if msg := stringField(obj, "msg", "message"); msg != "" {
+				name = msg
+			} else {
+				name = l.stream
+			}
+			if lvl := stringField(obj, "level", "severity"); lvl != "" {
+				level = strings.ToLower(lvl)
+			}
+		}
+	}
+
+	if _, exists := data["stream"]; !exists {
+		data["stream"] = l.stream
+	}
+	if _, exists := data["message"]; !exists && len(name) > maxNameLength {
+		data["message"] = name
+	}
+	if l.truncated {
+		data["truncated"] = true
+	}
+
+	return newEvent(cfg, traceID, level, truncate(name, maxNameLength), data)
+}
+
+func exitEvent(cfg Config, res *Result, runErr error) model.Event {
+	level := "info"
+	if res.ExitCode != 0 || runErr != nil {
+		level = "error"
+	}
+
+	data := map[string]any{
+		"command":     cfg.Command,
+		"exit_code":   res.ExitCode,
+		"duration_ms": res.Duration.Milliseconds(),
+		"lines":       res.Lines,
+	}
+	if runErr != nil {
+		data["error"] = runErr.Error()
+	}
+
+	return newEvent(cfg, res.TraceID, level, "process.exit", data)
+}
+
+func newEvent(cfg Config, traceID, level, name string, data map[string]any) model.Event {
+	return model.Event{
+		ID:        uuid.NewString(),
+		Timestamp: time.Now(),
+		Level:     level,
+		Service:   cfg.Service,
+		Name:      name,
+		TraceID:   traceID,
+		Data:      data,
+	}
+}
+
+func stringField(obj map[string]any, keys ...string) string {
+	for _, k := range keys {
+		if v, ok := obj[k].(string); ok && v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+/*
+* drops a rune left incomplete at the end of s
+**/
+func wholeRunes(s string) string {
+	i := len(s) - 1
+	for i > 0 && len(s)-i < utf8.UTFMax && !utf8.RuneStart(s[i]) {
+		i--
+	}
+	if i >= 0 && !utf8.FullRuneInString(s[i:]) {
+		return s[:i]
+	}
+	return s
+}
+
+/*
+* cuts s to at most n bytes, backing up to a rune
+* boundary so multibyte characters aren't split
+**/
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
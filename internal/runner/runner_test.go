@@ -0,0 +1,178 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/xonoxc/scopion/internal/model"
+)
+
+type recordingSink struct {
+	mu     sync.Mutex
+	events []model.Event
+}
+
+func (s *recordingSink) Send(e model.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, e)
+	return nil
+}
+
+func TestRunCapturesOutput(t *testing.T) {
+	sink := &recordingSink{}
+
+	script := `echo hello; echo '{"msg":"structured","level":"WARN","rows":3}'; echo oops >&2; exit 3`
+	res, err := Run(context.Background(), Config{
+		Service: "cron",
+		Command: "sh",
+		Args:    []string{"-c", script},
+	}, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.ExitCode != 3 {
+		t.Errorf("Expected exit code 3, got %d", res.ExitCode)
+	}
+	if res.Lines != 3 {
+		t.Errorf("Expected 3 lines, got %d", res.Lines)
+	}
+	if len(sink.events) != 5 {
+		t.Fatalf("Expected 5 events, got %d", len(sink.events))
+	}
+
+	for _, e := range sink.events {
+		if e.TraceID != res.TraceID {
+			t.Errorf("Expected trace ID %s, got %s", res.TraceID, e.TraceID)
+		}
+		if e.Service != "cron" {
+			t.Errorf("Expected service cron, got %s", e.Service)
+		}
+	}
+
+	if sink.events[0].Name != "process.start" {
+		t.Errorf("Expected first event process.start, got %s", sink.events[0].Name)
+	}
+
+	last := sink.events[len(sink.events)-1]
+	if last.Name != "process.exit" || last.Level != "error" {
+		t.Errorf("Expected error level process.exit, got %s %s", last.Level, last.Name)
+	}
+	if last.Data["exit_code"] != 3 {
+		t.Errorf("Expected exit_code 3, got %v", last.Data["exit_code"])
+	}
+
+	byName := map[string]model.Event{}
+	for _, e := range sink.events[1:4] {
+		byName[e.Name] = e
+	}
+
+	if e, ok := byName["hello"]; !ok || e.Level != "info" || e.Data["stream"] != STREAM_STDOUT {
+		t.Errorf("Expected info stdout event for plain line, got %+v", e)
+	}
+	if e, ok := byName["structured"]; !ok || e.Level != "warn" || e.Data["rows"] != 3.0 {
+		t.Errorf("Expected parsed JSON line, got %+v", e)
+	}
+	if e, ok := byName["oops"]; !ok || e.Level != "error" || e.Data["stream"] != STREAM_STDERR {
+		t.Errorf("Expected error stderr event, got %+v", e)
+	}
+}
+
+func TestRunUsesGivenTraceID(t *testing.T) {
+	sink := &recordingSink{}
+
+	res, err := Run(context.Background(), Config{
+		Service: "cron",
+		TraceID: "nightly-1",
+		Command: "true",
+	}, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.TraceID != "nightly-1" {
+		t.Errorf("Expected trace ID nightly-1, got %s", res.TraceID)
+	}
+	if res.ExitCode != 0 {
+		t.Errorf("Expected exit code 0, got %d", res.ExitCode)
+	}
+
+	last := sink.events[len(sink.events)-1]
+	if last.Level != "info" {
+		t.Errorf("Expected info level exit event, got %s", last.Level)
+	}
+}
+
+func TestRunStartFailure(t *testing.T) {
+	sink := &recordingSink{}
+
+	_, err := Run(context.Background(), Config{
+		Service: "cron",
+		Command: "/does/not/exist",
+	}, sink)
+	if err == nil {
+		t.Fatal("Expected error for missing command")
+	}
+
+	if len(sink.events) != 2 {
+		t.Fatalf("Expected start and exit events, got %d", len(sink.events))
+	}
+	if sink.events[1].Data["error"] == nil {
+		t.Error("Expected exit event to carry the start error")
+	}
+}
+
+func TestTruncateKeepsRunesWhole(t *testing.T) {
+	s := "héllo"
+
+	for n, want := range map[int]string{1: "h", 2: "h", 3: "hé", 10: "héllo", 0: ""} {
+		if got := truncate(s, n); got != want {
+			t.Errorf("truncate(%q, %d): expected %q, got %q", s, n, want, got)
+		}
+	}
+}
+
+func TestReadLinesCapsLongLines(t *testing.T) {
+	long := strings.Repeat("x", maxLineSize-1) + "é" + strings.Repeat("y", 100)
+	input := long + "\nnext\n"
+
+	var passthrough bytes.Buffer
+	out := make(chan line, 4)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	readLines(strings.NewReader(input), STREAM_STDOUT, &passthrough, out, &wg)
+	close(out)
+
+	var lines []line
+	for l := range out {
+		lines = append(lines, l)
+	}
+
+	if len(lines) != 2 || lines[1].text != "next" {
+		t.Fatalf("Expected the long line and the next one, got %d lines", len(lines))
+	}
+	if first := lines[0]; len(first.text) != maxLineSize-1 || !first.truncated {
+		t.Errorf("Expected the long line cut before the split rune, got %d bytes", len(first.text))
+	}
+	if passthrough.String() != input {
+		t.Error("Expected the whole output to be passed through")
+	}
+}
+
+func TestLineEventKeepsWholePlainLine(t *testing.T) {
+	text := strings.Repeat("a", maxNameLength+50)
+
+	e := lineEvent(Config{Service: "cron"}, "trace", line{stream: STREAM_STDOUT, text: text})
+	if len(e.Name) != maxNameLength || e.Data["message"] != text {
+		t.Errorf("Expected a cut name and the whole line in data, got %d bytes and %v", len(e.Name), e.Data)
+	}
+
+	e = lineEvent(Config{Service: "cron"}, "trace", line{stream: STREAM_STDOUT, text: "short"})
+	if _, ok := e.Data["message"]; ok {
+		t.Errorf("Expected no message for a line that fits the name, got %v", e.Data)
+	}
+}
@@ -0,0 +1,54 @@
+package runner
+
+import (
+	"context"
+
+	client "github.com/xonoxc/scopion/clients/go"
+	"github.com/xonoxc/scopion/internal/model"
+	"github.com/xonoxc/scopion/internal/store"
+)
+
+/*
+* Sink is where the runner ships the events it produces
+* either a remote scopion server or a local store
+**/
+type Sink interface {
+	Send(e model.Event) error
+}
+
+/*
+* ships events to a running scopion server through the go client
+**/
+type ClientSink struct {
+	client *client.Client
+}
+
+func NewClientSink(c *client.Client) *ClientSink {
+	return &ClientSink{client: c}
+}
+
+func (s *ClientSink) Send(e model.Event) error {
+	return s.client.Ingest(context.Background(), e)
+}
+
+/*
+* sends events in one request, see client.IngestBatch
+**/
+func (s *ClientSink) SendBatch(events []model.Event) ([]error, error) {
+	return s.client.IngestBatch(context.Background(), events)
+}
+
+/*
+* writes events straight into a local store
+**/
+type StoreSink struct {
+	store store.Storage
+}
+
+func NewStoreSink(s store.Storage) *StoreSink {
+	return &StoreSink{store: s}
+}
+
+func (s *StoreSink) Send(e model.Event) error {
+	return s.store.Append(e)
+}
@@ -32,8 +32,8 @@ func (d *DualWriteStore) Secondary() store.Storage {
 	return d.secondary
 }
 
-func (d *DualWriteStore) GetStats() (*model.Stats, error) {
-	return d.primary.GetStats()
+func (d *DualWriteStore) GetStats(project string) (*model.Stats, error) {
+	return d.primary.GetStats(project)
 }
 
 func (d *DualWriteStore) Append(event model.Event) error {
@@ -48,32 +48,89 @@ func (d *DualWriteStore) Append(event model.Event) error {
 	return nil
 }
 
-func (d *DualWriteStore) Recent(n int) ([]model.Event, error) {
-	return d.primary.Recent(n)
+func (d *DualWriteStore) AppendBatch(events []model.Event) error {
+	_, err := d.AppendBatchInserted(events)
+	return err
 }
 
-func (d *DualWriteStore) GetServices() ([]model.ServiceInfo, error) {
-	return d.primary.GetServices()
+/*
+* reports what the primary store inserted, it answers the reads
+**/
+func (d *DualWriteStore) AppendBatchInserted(events []model.Event) ([]model.Event, error) {
+	inserted, err := store.AppendNew(d.primary, events)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.secondary.AppendBatch(events); err != nil {
+		log.Printf("warning: failed to write batch to secondary store: %v", err)
+	}
+
+	return inserted, nil
+}
+
+func (d *DualWriteStore) Recent(project string, n int) ([]model.Event, error) {
+	return d.primary.Recent(project, n)
+}
+
+func (d *DualWriteStore) GetServices(project string) ([]model.ServiceInfo, error) {
+	return d.primary.GetServices(project)
+}
+
+func (d *DualWriteStore) GetErrorsByService(project string, hours int) ([]model.ErrorByService, error) {
+	return d.primary.GetErrorsByService(project, hours)
+}
+
+func (d *DualWriteStore) GetTraces(project string, limit int) ([]model.TraceInfo, error) {
+	return d.primary.GetTraces(project, limit)
+}
+
+func (d *DualWriteStore) GetEventsByTraceID(project string, traceID string) ([]model.Event, error) {
+	return d.primary.GetEventsByTraceID(project, traceID)
 }
 
-func (d *DualWriteStore) GetErrorsByService(hours int) ([]model.ErrorByService, error) {
-	return d.primary.GetErrorsByService(hours)
+func (d *DualWriteStore) SearchEvents(project string, query string, limit int) ([]model.Event, error) {
+	return d.primary.SearchEvents(project, query, limit)
 }
 
-func (d *DualWriteStore) GetTraces(limit int) ([]model.TraceInfo, error) {
-	return d.primary.GetTraces(limit)
+func (d *DualWriteStore) GetThroughput(project string, hours int) ([]model.ThroughputData, error) {
+	return d.primary.GetThroughput(project, hours)
 }
 
-func (d *DualWriteStore) GetEventsByTraceID(traceID string) ([]model.Event, error) {
-	return d.primary.GetEventsByTraceID(traceID)
+/*
+* keys are written to both stores so they keep
+* working once the secondary is promoted
+**/
+func (d *DualWriteStore) CreateAPIKey(key model.APIKey) error {
+	if err := d.primary.CreateAPIKey(key); err != nil {
+		return err
+	}
+
+	if err := d.secondary.CreateAPIKey(key); err != nil {
+		log.Printf("warning: failed to write api key to secondary store: %v", err)
+	}
+
+	return nil
 }
 
-func (d *DualWriteStore) SearchEvents(query string, limit int) ([]model.Event, error) {
-	return d.primary.SearchEvents(query, limit)
+func (d *DualWriteStore) ListAPIKeys() ([]model.APIKey, error) {
+	return d.primary.ListAPIKeys()
 }
 
-func (d *DualWriteStore) GetThroughput(hours int) ([]model.ThroughputData, error) {
-	return d.primary.GetThroughput(hours)
+func (d *DualWriteStore) GetAPIKeyByHash(hash string) (*model.APIKey, error) {
+	return d.primary.GetAPIKeyByHash(hash)
+}
+
+func (d *DualWriteStore) RevokeAPIKey(id string) error {
+	if err := d.primary.RevokeAPIKey(id); err != nil {
+		return err
+	}
+
+	if err := d.secondary.RevokeAPIKey(id); err != nil {
+		log.Printf("warning: failed to revoke api key in secondary store: %v", err)
+	}
+
+	return nil
 }
 
 func (d *DualWriteStore) Close() error {
@@ -9,42 +9,93 @@ import (
 *this will help to switch between different storage services
 *****/
 
+/*
+* every query is scoped to a single project
+**/
 type Storage interface {
+	/*
+	* an event whose id is already stored in its project is a no-op, not
+	* an error, so clients retrying with the same id don't create duplicates
+	**/
 	Append(event model.Event) error
 
-	Recent(n int) ([]model.Event, error)
+	/*
+	* writes the events in a single transaction. events whose id is
+	* already stored in their project are skipped, so a batch can be
+	* retried safely
+	**/
+	AppendBatch(events []model.Event) error
+
+	/*
+	* the latest n events, oldest first
+	**/
+	Recent(project string, n int) ([]model.Event, error)
 
 	/*
 		stats related methods
 	*/
-	GetStats() (*model.Stats, error)
+	GetStats(project string) (*model.Stats, error)
 
 	/*
 		services related methods
 	*/
-	GetServices() ([]model.ServiceInfo, error)
+	GetServices(project string) ([]model.ServiceInfo, error)
 
-	GetErrorsByService(hours int) ([]model.ErrorByService, error)
+	GetErrorsByService(project string, hours int) ([]model.ErrorByService, error)
 
 	/*
 		trace related methods
 	*/
-	GetTraces(limit int) ([]model.TraceInfo, error)
+	GetTraces(project string, limit int) ([]model.TraceInfo, error)
 
-	GetEventsByTraceID(traceID string) ([]model.Event, error)
+	GetEventsByTraceID(project string, traceID string) ([]model.Event, error)
 
 	/*
 		search related methods
 	*/
-	SearchEvents(query string, limit int) ([]model.Event, error)
+	SearchEvents(project string, query string, limit int) ([]model.Event, error)
 
 	/*
 		throughput related methods
 	*/
-	GetThroughput(hours int) ([]model.ThroughputData, error)
+	GetThroughput(project string, hours int) ([]model.ThroughputData, error)
+
+	/*
+		api key related methods, keys are looked up by hash.
+		missing keys are reported as model.ErrAPIKeyNotFound
+	*/
+	CreateAPIKey(key model.APIKey) error
+
+	ListAPIKeys() ([]model.APIKey, error)
+
+	GetAPIKeyByHash(hash string) (*model.APIKey, error)
+
+	RevokeAPIKey(id string) error
 
 	/*
 	*closing the storage service
 	 */
 	Close() error
 }
+
+/*
+* InsertReporter is a Storage that can tell which events of a batch
+* were new, the rest were skipped as already stored
+**/
+type InsertReporter interface {
+	AppendBatchInserted(events []model.Event) ([]model.Event, error)
+}
+
+/*
+* AppendNew stores events and returns those that weren't stored
+* before, in their order. all of them when s can't tell
+**/
+func AppendNew(s Storage, events []model.Event) ([]model.Event, error) {
+	if r, ok := s.(InsertReporter); ok {
+		return r.AppendBatchInserted(events)
+	}
+	if err := s.AppendBatch(events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
@@ -0,0 +1,41 @@
+package migrations
+
+import "database/sql"
+
+type CreateAPIKeysTable struct{}
+
+func (m *CreateAPIKeysTable) ID() string {
+	return "03_create_api_keys_table"
+}
+
+func (m *CreateAPIKeysTable) UpPostgres(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS api_keys (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		project TEXT NOT NULL,
+		prefix TEXT NOT NULL,
+		hash TEXT NOT NULL UNIQUE,
+		scopes TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL,
+		revoked_at TIMESTAMPTZ
+	);
+	`)
+	return err
+}
+
+func (m *CreateAPIKeysTable) UpSqlite(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS api_keys (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		project TEXT NOT NULL,
+		prefix TEXT NOT NULL,
+		hash TEXT NOT NULL UNIQUE,
+		scopes TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		revoked_at DATETIME
+	);
+	`)
+	return err
+}
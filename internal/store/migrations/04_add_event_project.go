@@ -0,0 +1,66 @@
+package migrations
+
+import "database/sql"
+
+type AddEventProjectColumn struct{}
+
+func (m *AddEventProjectColumn) ID() string {
+	return "04_add_event_project_column"
+}
+
+func (m *AddEventProjectColumn) UpPostgres(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		ALTER TABLE events
+		ADD COLUMN IF NOT EXISTS project TEXT NOT NULL DEFAULT 'default';
+
+		CREATE INDEX IF NOT EXISTS idx_events_project_timestamp
+		ON events (project, timestamp);
+	`)
+	return err
+}
+
+func (m *AddEventProjectColumn) UpSqlite(tx *sql.Tx) error {
+	/*
+	* SQLite has no ADD COLUMN IF NOT EXISTS
+	**/
+	exists, err := sqliteColumnExists(tx, "events", "project")
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		_, err := tx.Exec(`
+			ALTER TABLE events
+			ADD COLUMN project TEXT NOT NULL DEFAULT 'default';
+		`)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_events_project_timestamp
+		ON events (project, timestamp);
+	`)
+	return err
+}
+
+func sqliteColumnExists(tx *sql.Tx, table, column string) (bool, error) {
+	rows, err := tx.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+
+	return false, rows.Err()
+}
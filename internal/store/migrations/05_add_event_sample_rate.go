@@ -0,0 +1,34 @@
+package migrations
+
+import "database/sql"
+
+/*
+* events kept by sampling record the rate they were kept at,
+* so stats can count each one for 1 / sample_rate events
+**/
+type AddEventSampleRateColumn struct{}
+
+func (m *AddEventSampleRateColumn) ID() string {
+	return "05_add_event_sample_rate_column"
+}
+
+func (m *AddEventSampleRateColumn) UpPostgres(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		ALTER TABLE events
+		ADD COLUMN IF NOT EXISTS sample_rate DOUBLE PRECISION NOT NULL DEFAULT 1;
+	`)
+	return err
+}
+
+func (m *AddEventSampleRateColumn) UpSqlite(tx *sql.Tx) error {
+	exists, err := sqliteColumnExists(tx, "events", "sample_rate")
+	if err != nil || exists {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		ALTER TABLE events
+		ADD COLUMN sample_rate REAL NOT NULL DEFAULT 1;
+	`)
+	return err
+}
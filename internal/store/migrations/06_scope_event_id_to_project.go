@@ -0,0 +1,91 @@
+package migrations
+
+import (
+	"database/sql"
+	"slices"
+)
+
+/*
+* clients pick event ids, so an id only has to be unique within its
+* project. otherwise an event would be dropped as a duplicate of
+* another project's event with the same id
+**/
+type ScopeEventIDToProject struct{}
+
+func (m *ScopeEventIDToProject) ID() string {
+	return "06_scope_event_id_to_project"
+}
+
+func (m *ScopeEventIDToProject) UpPostgres(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		DO $$
+		BEGIN
+			IF NOT EXISTS (
+				SELECT 1 FROM pg_constraint
+				WHERE conrelid = 'events'::regclass AND contype = 'p' AND array_length(conkey, 1) = 2
+			) THEN
+				ALTER TABLE events DROP CONSTRAINT IF EXISTS events_pkey;
+				ALTER TABLE events ADD PRIMARY KEY (project, id);
+			END IF;
+		END $$;
+	`)
+	return err
+}
+
+func (m *ScopeEventIDToProject) UpSqlite(tx *sql.Tx) error {
+	key, err := sqlitePrimaryKey(tx, "events")
+	if err != nil || slices.Equal(key, []string{"project", "id"}) {
+		return err
+	}
+
+	/*
+	* SQLite can't change a primary key, the table is rebuilt
+	**/
+	_, err = tx.Exec(`
+		CREATE TABLE events_scoped (
+			id TEXT NOT NULL,
+			project TEXT NOT NULL DEFAULT 'default',
+			timestamp DATETIME NOT NULL,
+			level TEXT NOT NULL,
+			service TEXT NOT NULL,
+			name TEXT NOT NULL,
+			trace_id TEXT NOT NULL,
+			data TEXT,
+			sample_rate REAL NOT NULL DEFAULT 1,
+			PRIMARY KEY (project, id)
+		);
+
+		INSERT INTO events_scoped (id, project, timestamp, level, service, name, trace_id, data, sample_rate)
+		SELECT id, project, timestamp, level, service, name, trace_id, data, sample_rate FROM events;
+
+		DROP TABLE events;
+
+		ALTER TABLE events_scoped RENAME TO events;
+
+		CREATE INDEX IF NOT EXISTS idx_events_project_timestamp
+		ON events (project, timestamp);
+	`)
+	return err
+}
+
+/*
+* columns of the table's primary key, in key order
+**/
+func sqlitePrimaryKey(tx *sql.Tx, table string) ([]string, error) {
+	rows, err := tx.Query("SELECT name FROM pragma_table_info(?) WHERE pk > 0 ORDER BY pk", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var key []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		key = append(key, name)
+	}
+
+	return key, rows.Err()
+}
@@ -26,5 +26,22 @@ func GetAll() []Migration {
 	return []Migration{
 		&CreateEventsTable{},
 		&AddEventDataColumn{},
+		&CreateAPIKeysTable{},
+		&AddEventProjectColumn{},
+		&AddEventSampleRateColumn{},
+		&ScopeEventIDToProject{},
+	}
+}
+
+/*
+* migrations that are safe to run on every start,
+* used where the rest of the schema comes from goose
+***/
+func Idempotent() []Migration {
+	return []Migration{
+		&CreateAPIKeysTable{},
+		&AddEventProjectColumn{},
+		&AddEventSampleRateColumn{},
+		&ScopeEventIDToProject{},
 	}
 }
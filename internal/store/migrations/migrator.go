@@ -2,6 +2,7 @@ package migrations
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 
 	migrateable "github.com/xonoxc/scopion/internal/store/migratable"
@@ -29,6 +30,33 @@ func (m *Migrator) Migrate(dialect migrateable.DatabaseName, migrations []Migrat
 	})
 }
 
+/*
+* MigrateSqlite creates the schema of a new SQLite database, or brings
+* one that already has it up to date. only the migrations that are safe
+* to repeat run then, the rest would fail on existing columns
+**/
+func MigrateSqlite(path string) error {
+	conn, err := connByDialect(migrateable.SQLITE, path)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var table string
+	err = conn.QueryRow(`SELECT name FROM sqlite_master WHERE type = 'table' AND name = 'events'`).Scan(&table)
+	all := GetAll()
+	switch {
+	case err == nil:
+		all = Idempotent()
+	case !errors.Is(err, sql.ErrNoRows):
+		return fmt.Errorf("migration inspect err: %w", err)
+	}
+
+	return withTransaction(conn, func(tx *sql.Tx) error {
+		return runMigrations(migrateable.SQLITE, tx, all)
+	})
+}
+
 func connByDialect(dialect migrateable.DatabaseName, dsn string) (*sql.DB, error) {
 	driver, err := driverFor(dialect)
 	if err != nil {
@@ -81,5 +109,12 @@ func driverFor(d migrateable.DatabaseName) (string, error) {
 	if !d.Valid() {
 		return "", fmt.Errorf("unsupported dialect: %s", d)
 	}
+
+	/*
+	* the postgres store registers pgx, not a driver named after the dialect
+	**/
+	if d == migrateable.POSTGRES {
+		return "pgx", nil
+	}
 	return string(d), nil
 }
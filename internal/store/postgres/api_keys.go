@@ -0,0 +1,110 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/xonoxc/scopion/internal/model"
+)
+
+func (p *PostgresStore) CreateAPIKey(key model.APIKey) error {
+	_, err := p.db.Exec(
+		"INSERT INTO api_keys (id, name, project, prefix, hash, scopes, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+		key.ID, key.Name, key.Project, key.Prefix, key.Hash, joinScopes(key.Scopes), key.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("insert api key: %w", err)
+	}
+	return nil
+}
+
+func (p *PostgresStore) ListAPIKeys() ([]model.APIKey, error) {
+	rows, err := p.db.Query(
+		"SELECT id, name, project, prefix, hash, scopes, created_at, revoked_at FROM api_keys ORDER BY created_at ASC",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query api keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []model.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+
+	return keys, rows.Err()
+}
+
+func (p *PostgresStore) GetAPIKeyByHash(hash string) (*model.APIKey, error) {
+	row := p.db.QueryRow(
+		"SELECT id, name, project, prefix, hash, scopes, created_at, revoked_at FROM api_keys WHERE hash = $1",
+		hash,
+	)
+
+	key, err := scanAPIKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, model.ErrAPIKeyNotFound
+	}
+	return key, err
+}
+
+func (p *PostgresStore) RevokeAPIKey(id string) error {
+	res, err := p.db.Exec(
+		"UPDATE api_keys SET revoked_at = $1 WHERE id = $2 AND revoked_at IS NULL",
+		time.Now(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("revoke api key: %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return model.ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+func scanAPIKey(row interface{ Scan(...any) error }) (*model.APIKey, error) {
+	var key model.APIKey
+	var scopes string
+	var revokedAt sql.NullTime
+
+	err := row.Scan(&key.ID, &key.Name, &key.Project, &key.Prefix, &key.Hash, &scopes, &key.CreatedAt, &revokedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("scan api key: %w", err)
+	}
+
+	key.Scopes = splitScopes(scopes)
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+
+	return &key, nil
+}
+
+func joinScopes(scopes []model.APIKeyScope) string {
+	parts := make([]string, len(scopes))
+	for i, scope := range scopes {
+		parts[i] = string(scope)
+	}
+	return strings.Join(parts, ",")
+}
+
+func splitScopes(raw string) []model.APIKeyScope {
+	var scopes []model.APIKeyScope
+	for part := range strings.SplitSeq(raw, ",") {
+		if part != "" {
+			scopes = append(scopes, model.APIKeyScope(part))
+		}
+	}
+	return scopes
+}
@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"math"
+	"slices"
 	"time"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
@@ -11,6 +13,11 @@ import (
 	migrateable "github.com/xonoxc/scopion/internal/store/migratable"
 )
 
+/*
+* matches the levels counted as errors
+**/
+var isError = model.ErrorLevelSQL()
+
 type PostgresStore struct {
 	db *sql.DB
 }
@@ -50,23 +57,22 @@ func NewWithDB(db *sql.DB) *PostgresStore {
 func (p *PostgresStore) Append(e model.Event) error {
 	var data any
 
-	if e.Data == nil {
+	if e.Data != nil {
 		jsonData, err := json.Marshal(e.Data)
 		if err != nil {
 			return fmt.Errorf("marshal event data: %w", err)
 		}
 		data = jsonData
-	} else {
-		data = nil
 	}
 
 	_, err := p.db.Exec(
 		`
 		INSERT INTO events
-		(id , timestamp , level , service , name, trace_id , data)
-		VALUES ($1 , $2 , $3 , $4, $5 , $6 ,  $7)
+		(id , project , timestamp , level , service , name, trace_id , data , sample_rate)
+		VALUES ($1 , $2 , $3 , $4, $5 , $6 ,  $7 , $8 , $9)
+		ON CONFLICT (project, id) DO NOTHING
 		`,
-		e.ID, e.Timestamp, e.Level, e.Service, e.Name, e.TraceID, data,
+		e.ID, model.ProjectOrDefault(e.Project), e.Timestamp, e.Level, e.Service, e.Name, e.TraceID, data, model.SampleRateOrOne(e.SampleRate),
 	)
 	if err != nil {
 		return fmt.Errorf("insert event %w:", err)
@@ -75,41 +81,105 @@ func (p *PostgresStore) Append(e model.Event) error {
 	return nil
 }
 
-func (p *PostgresStore) GetStats() (*model.Stats, error) {
+func (p *PostgresStore) AppendBatch(events []model.Event) error {
+	_, err := p.AppendBatchInserted(events)
+	return err
+}
+
+/*
+* AppendBatch, also returning the events that weren't stored before
+**/
+func (p *PostgresStore) AppendBatchInserted(events []model.Event) ([]model.Event, error) {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("begin batch: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(
+		`
+		INSERT INTO events
+		(id , project , timestamp , level , service , name, trace_id , data , sample_rate)
+		VALUES ($1 , $2 , $3 , $4, $5 , $6 ,  $7 , $8 , $9)
+		ON CONFLICT (project, id) DO NOTHING
+		`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("prepare batch insert: %w", err)
+	}
+	defer stmt.Close()
+
+	var inserted []model.Event
+	for _, e := range events {
+		var data any
+		if e.Data != nil {
+			jsonData, err := json.Marshal(e.Data)
+			if err != nil {
+				return nil, fmt.Errorf("marshal event data: %w", err)
+			}
+			data = jsonData
+		}
+
+		res, err := stmt.Exec(e.ID, model.ProjectOrDefault(e.Project), e.Timestamp, e.Level, e.Service, e.Name, e.TraceID, data, model.SampleRateOrOne(e.SampleRate))
+		if err != nil {
+			return nil, fmt.Errorf("insert event: %w", err)
+		}
+		if n, err := res.RowsAffected(); err == nil && n > 0 {
+			inserted = append(inserted, e)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit batch: %w", err)
+	}
+	return inserted, nil
+}
+
+func (p *PostgresStore) GetStats(project string) (*model.Stats, error) {
 	var stats model.Stats
+	var totalEvents, errorEvents float64
 
+	/*
+	* a sampled event stands for 1 / sample_rate events
+	**/
 	err := p.db.QueryRow(
 		`
 		SELECT
-			COUNT(*) AS total_events,
-			COUNT(*) FILTER (WHERE level = 'error') AS error_events,
+			COUNT(*) AS stored_events,
+			COALESCE(SUM(1.0 / sample_rate), 0) AS total_events,
+			COALESCE(SUM(1.0 / sample_rate) FILTER (WHERE `+isError+`), 0) AS error_events,
 			COUNT(DISTINCT service) AS active_services
 		FROM events
+		WHERE project = $1
 		`,
-	).Scan(&stats.TotalEvents, &stats.ErrorRate, &stats.ActiveServices)
+		project,
+	).Scan(&stats.StoredEvents, &totalEvents, &errorEvents, &stats.ActiveServices)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get stats: %w", err)
 	}
 
-	if stats.TotalEvents > 0 {
-		stats.ErrorRate = stats.ErrorRate / float64(stats.TotalEvents) * 100
+	stats.TotalEvents = int(math.Round(totalEvents))
+	if totalEvents > 0 {
+		stats.ErrorRate = errorEvents / totalEvents * 100
 	}
 
 	return &stats, nil
 }
 
-func (p *PostgresStore) GetServices() ([]model.ServiceInfo, error) {
+func (p *PostgresStore) GetServices(project string) ([]model.ServiceInfo, error) {
 	rows, err := p.db.Query(
 		`
 		SELECT
 			service,
-			COUNT(*) FILTER (WHERE level = 'error') AS error_count,
+			COUNT(*) FILTER (WHERE `+isError+`) AS error_count,
 			MAX(timestamp) AS last_activity,
 			COUNT(*) AS event_count
 		FROM events
+		WHERE project = $1
 		GROUP BY service
 		ORDER BY last_activity DESC
 		`,
+		project,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query services: %w", err)
@@ -128,7 +198,7 @@ func (p *PostgresStore) GetServices() ([]model.ServiceInfo, error) {
 	return results, rows.Err()
 }
 
-func (p *PostgresStore) GetTraces(limit int) ([]model.TraceInfo, error) {
+func (p *PostgresStore) GetTraces(project string, limit int) ([]model.TraceInfo, error) {
 	query := `
 		SELECT
 			trace_id,
@@ -137,14 +207,15 @@ func (p *PostgresStore) GetTraces(limit int) ([]model.TraceInfo, error) {
 			COUNT(*) AS span_count,
 			MIN(timestamp) AS start_time,
 			MAX(timestamp) AS end_time,
-			BOOL_OR(level = 'error') AS has_error
+			BOOL_OR(` + isError + `) AS has_error
 		FROM events
+		WHERE project = $1
 		GROUP BY trace_id, service
 		ORDER BY start_time DESC
-		LIMIT $1
+		LIMIT $2
 	`
 
-	rows, err := p.db.Query(query, limit)
+	rows, err := p.db.Query(query, project, limit)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query traces: %w", err)
 	}
@@ -176,14 +247,16 @@ func (p *PostgresStore) GetTraces(limit int) ([]model.TraceInfo, error) {
 	return results, nil
 }
 
-func (p *PostgresStore) Recent(n int) ([]model.Event, error) {
+func (p *PostgresStore) Recent(project string, n int) ([]model.Event, error) {
 	rows, err := p.db.Query(
 		`
-		SELECT id, timestamp, level, service, name, trace_id, data
+		SELECT id, project, timestamp, level, service, name, trace_id, data
 		FROM events
+		WHERE project = $1
 		ORDER BY timestamp DESC
-		LIMIT $1
+		LIMIT $2
 		`,
+		project,
 		n,
 	)
 	if err != nil {
@@ -199,6 +272,7 @@ func (p *PostgresStore) Recent(n int) ([]model.Event, error) {
 
 		err := rows.Scan(
 			&e.ID,
+			&e.Project,
 			&e.Timestamp,
 			&e.Level,
 			&e.Service,
@@ -218,20 +292,26 @@ func (p *PostgresStore) Recent(n int) ([]model.Event, error) {
 
 		events = append(events, e)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
 
-	return events, rows.Err()
+	slices.Reverse(events)
+	return events, nil
 }
 
-func (p *PostgresStore) GetErrorsByService(hours int) ([]model.ErrorByService, error) {
+func (p *PostgresStore) GetErrorsByService(project string, hours int) ([]model.ErrorByService, error) {
 	rows, err := p.db.Query(
 		`
 		SELECT service, COUNT(*) AS count
 		FROM events
-		WHERE level = 'error'
-		  AND timestamp >= NOW() - INTERVAL '1 hour' * $1
+		WHERE project = $1
+		  AND `+isError+`
+		  AND timestamp >= NOW() - INTERVAL '1 hour' * $2
 		GROUP BY service
 		ORDER BY count DESC
 		`,
+		project,
 		hours,
 	)
 	if err != nil {
@@ -251,19 +331,21 @@ func (p *PostgresStore) GetErrorsByService(hours int) ([]model.ErrorByService, e
 	return results, rows.Err()
 }
 
-func (p *PostgresStore) SearchEvents(query string, limit int) ([]model.Event, error) {
+func (p *PostgresStore) SearchEvents(project string, query string, limit int) ([]model.Event, error) {
 	like := "%" + query + "%"
 
 	rows, err := p.db.Query(
 		`
-		SELECT id, timestamp, level, service, name, trace_id, data
+		SELECT id, project, timestamp, level, service, name, trace_id, data
 		FROM events
-		WHERE name ILIKE $1
-		   OR service ILIKE $1
-		   OR trace_id ILIKE $1
+		WHERE project = $1
+		  AND (name ILIKE $2
+		   OR service ILIKE $2
+		   OR trace_id ILIKE $2)
 		ORDER BY timestamp DESC
-		LIMIT $2
+		LIMIT $3
 		`,
+		project,
 		like,
 		limit,
 	)
@@ -279,6 +361,7 @@ func (p *PostgresStore) SearchEvents(query string, limit int) ([]model.Event, er
 
 		if err := rows.Scan(
 			&e.ID,
+			&e.Project,
 			&e.Timestamp,
 			&e.Level,
 			&e.Service,
@@ -299,14 +382,15 @@ func (p *PostgresStore) SearchEvents(query string, limit int) ([]model.Event, er
 	return events, rows.Err()
 }
 
-func (p *PostgresStore) GetEventsByTraceID(traceID string) ([]model.Event, error) {
+func (p *PostgresStore) GetEventsByTraceID(project string, traceID string) ([]model.Event, error) {
 	rows, err := p.db.Query(
 		`
-		SELECT id, timestamp, level, service, name, trace_id, data
+		SELECT id, project, timestamp, level, service, name, trace_id, data
 		FROM events
-		WHERE trace_id = $1
+		WHERE project = $1 AND trace_id = $2
 		ORDER BY timestamp ASC
 		`,
+		project,
 		traceID,
 	)
 	if err != nil {
@@ -321,6 +405,7 @@ func (p *PostgresStore) GetEventsByTraceID(traceID string) ([]model.Event, error
 
 		if err := rows.Scan(
 			&e.ID,
+			&e.Project,
 			&e.Timestamp,
 			&e.Level,
 			&e.Service,
@@ -341,7 +426,7 @@ func (p *PostgresStore) GetEventsByTraceID(traceID string) ([]model.Event, error
 	return events, rows.Err()
 }
 
-func (p *PostgresStore) GetThroughput(hours int) ([]model.ThroughputData, error) {
+func (p *PostgresStore) GetThroughput(project string, hours int) ([]model.ThroughputData, error) {
 	if hours <= 0 {
 		hours = 24
 	}
@@ -352,10 +437,12 @@ func (p *PostgresStore) GetThroughput(hours int) ([]model.ThroughputData, error)
 			date_trunc('hour', timestamp) AS time,
 			COUNT(*) AS events
 		FROM events
-		WHERE timestamp >= NOW() - INTERVAL '1 hour' * $1
+		WHERE project = $1
+		  AND timestamp >= NOW() - INTERVAL '1 hour' * $2
 		GROUP BY time
 		ORDER BY time ASC
 		`,
+		project,
 		hours,
 	)
 	if err != nil {
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"math"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -11,6 +12,11 @@ import (
 	migrateable "github.com/xonoxc/scopion/internal/store/migratable"
 )
 
+/*
+* matches the levels counted as errors
+**/
+var isError = model.ErrorLevelSQL()
+
 type SqliteStore struct {
 	db *sql.DB
 }
@@ -60,8 +66,8 @@ func (s *SqliteStore) Append(e model.Event) error {
 	}
 
 	_, err = s.db.Exec(
-		"INSERT INTO events (id, timestamp, level, service, name, trace_id, data) VALUES (?, ?, ?, ?, ?, ?, ?)",
-		e.ID, e.Timestamp, e.Level, e.Service, e.Name, e.TraceID, string(dataJSON),
+		"INSERT OR IGNORE INTO events (id, project, timestamp, level, service, name, trace_id, data, sample_rate) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		e.ID, model.ProjectOrDefault(e.Project), e.Timestamp, e.Level, e.Service, e.Name, e.TraceID, string(dataJSON), model.SampleRateOrOne(e.SampleRate),
 	)
 	if err != nil {
 		return fmt.Errorf("failed to insert event: %w", err)
@@ -69,10 +75,58 @@ func (s *SqliteStore) Append(e model.Event) error {
 	return nil
 }
 
-func (s *SqliteStore) Recent(n int) ([]model.Event, error) {
+func (s *SqliteStore) AppendBatch(events []model.Event) error {
+	_, err := s.AppendBatchInserted(events)
+	return err
+}
+
+/*
+* AppendBatch, also returning the events that weren't stored before
+**/
+func (s *SqliteStore) AppendBatchInserted(events []model.Event) ([]model.Event, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin batch: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(
+		"INSERT OR IGNORE INTO events (id, project, timestamp, level, service, name, trace_id, data, sample_rate) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare batch insert: %w", err)
+	}
+	defer stmt.Close()
+
+	var inserted []model.Event
+	for _, e := range events {
+		var dataJSON []byte
+		if e.Data != nil {
+			dataJSON, err = json.Marshal(e.Data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal event data: %w", err)
+			}
+		}
+
+		res, err := stmt.Exec(e.ID, model.ProjectOrDefault(e.Project), e.Timestamp, e.Level, e.Service, e.Name, e.TraceID, string(dataJSON), model.SampleRateOrOne(e.SampleRate))
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert event: %w", err)
+		}
+		if n, err := res.RowsAffected(); err == nil && n > 0 {
+			inserted = append(inserted, e)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit batch: %w", err)
+	}
+	return inserted, nil
+}
+
+func (s *SqliteStore) Recent(project string, n int) ([]model.Event, error) {
 	rows, err := s.db.Query(
-		"SELECT id, timestamp, level, service, name, trace_id, data FROM events ORDER BY timestamp DESC LIMIT ?",
-		n,
+		"SELECT id, project, timestamp, level, service, name, trace_id, data FROM events WHERE project = ? ORDER BY timestamp DESC LIMIT ?",
+		project, n,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query events: %w", err)
@@ -83,7 +137,7 @@ func (s *SqliteStore) Recent(n int) ([]model.Event, error) {
 	for rows.Next() {
 		var e model.Event
 		var dataStr sql.NullString
-		err := rows.Scan(&e.ID, &e.Timestamp, &e.Level, &e.Service, &e.Name, &e.TraceID, &dataStr)
+		err := rows.Scan(&e.ID, &e.Project, &e.Timestamp, &e.Level, &e.Service, &e.Name, &e.TraceID, &dataStr)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan event: %w", err)
 		}
@@ -109,48 +163,53 @@ func (s *SqliteStore) Recent(n int) ([]model.Event, error) {
 	return events, nil
 }
 
-func (s *SqliteStore) GetStats() (*model.Stats, error) {
-	var totalEvents int
-	err := s.db.QueryRow("SELECT COUNT(*) FROM events").Scan(&totalEvents)
+func (s *SqliteStore) GetStats(project string) (*model.Stats, error) {
+	/*
+	* a sampled event stands for 1 / sample_rate events
+	**/
+	var storedEvents int
+	var totalEvents, errorEvents float64
+	err := s.db.QueryRow(`
+		SELECT
+			COUNT(*),
+			COALESCE(SUM(1.0 / sample_rate), 0),
+			COALESCE(SUM(CASE WHEN `+isError+` THEN 1.0 / sample_rate ELSE 0 END), 0)
+		FROM events WHERE project = ?
+	`, project).Scan(&storedEvents, &totalEvents, &errorEvents)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get total events: %w", err)
 	}
 
-	var errorEvents int
-	err = s.db.QueryRow("SELECT COUNT(*) FROM events WHERE level = 'error'").Scan(&errorEvents)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get error events: %w", err)
-	}
-
 	var activeServices int
-	err = s.db.QueryRow("SELECT COUNT(DISTINCT service) FROM events").Scan(&activeServices)
+	err = s.db.QueryRow("SELECT COUNT(DISTINCT service) FROM events WHERE project = ?", project).Scan(&activeServices)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get active services: %w", err)
 	}
 
 	var errorRate float64
 	if totalEvents > 0 {
-		errorRate = float64(errorEvents) / float64(totalEvents) * 100
+		errorRate = errorEvents / totalEvents * 100
 	}
 
 	return &model.Stats{
-		TotalEvents:    totalEvents,
+		TotalEvents:    int(math.Round(totalEvents)),
+		StoredEvents:   storedEvents,
 		ErrorRate:      errorRate,
 		ActiveServices: activeServices,
 	}, nil
 }
 
-func (s *SqliteStore) GetErrorsByService(hours int) ([]model.ErrorByService, error) {
+func (s *SqliteStore) GetErrorsByService(project string, hours int) ([]model.ErrorByService, error) {
 	query := `
 		SELECT service, COUNT(*) as count
 		FROM events
-		WHERE level = 'error' AND timestamp >= datetime('now', '-%d hours')
+		WHERE project = ? AND ` + isError + ` AND timestamp >= datetime('now', '-%d hours')
 		GROUP BY service
 		ORDER BY count DESC
 	`
 	query = fmt.Sprintf(query, hours)
 
-	rows, err := s.db.Query(query)
+	rows, err := s.db.Query(query, project)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query errors by service: %w", err)
 	}
@@ -169,19 +228,20 @@ func (s *SqliteStore) GetErrorsByService(hours int) ([]model.ErrorByService, err
 	return results, rows.Err()
 }
 
-func (s *SqliteStore) GetServices() ([]model.ServiceInfo, error) {
+func (s *SqliteStore) GetServices(project string) ([]model.ServiceInfo, error) {
 	query := `
 		SELECT
 			service,
-			COUNT(CASE WHEN level = 'error' THEN 1 END) as error_count,
+			COUNT(CASE WHEN ` + isError + ` THEN 1 END) as error_count,
 			MAX(timestamp) as last_activity,
 			COUNT(*) as event_count
 		FROM events
+		WHERE project = ?
 		GROUP BY service
 		ORDER BY last_activity DESC
 	`
 
-	rows, err := s.db.Query(query)
+	rows, err := s.db.Query(query, project)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query services: %w", err)
 	}
@@ -190,17 +250,26 @@ func (s *SqliteStore) GetServices() ([]model.ServiceInfo, error) {
 	var results []model.ServiceInfo
 	for rows.Next() {
 		var s model.ServiceInfo
-		err := rows.Scan(&s.Name, &s.ErrorCount, &s.LastActivity, &s.EventCount)
+		var lastActivityStr string
+		err := rows.Scan(&s.Name, &s.ErrorCount, &lastActivityStr, &s.EventCount)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan service info: %w", err)
 		}
+
+		/*
+		* MAX of a DATETIME column comes back as text
+		**/
+		s.LastActivity, err = time.Parse("2006-01-02 15:04:05.999999999-07:00", lastActivityStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse last activity: %w", err)
+		}
 		results = append(results, s)
 	}
 
 	return results, rows.Err()
 }
 
-func (s *SqliteStore) GetTraces(limit int) ([]model.TraceInfo, error) {
+func (s *SqliteStore) GetTraces(project string, limit int) ([]model.TraceInfo, error) {
 	// For now, group events by trace_id to simulate traces
 	query := `
 		SELECT
@@ -210,14 +279,15 @@ func (s *SqliteStore) GetTraces(limit int) ([]model.TraceInfo, error) {
 			COUNT(*) as span_count,
 			MIN(timestamp) as start_time,
 			MAX(timestamp) as end_time,
-			CASE WHEN SUM(CASE WHEN level = 'error' THEN 1 ELSE 0 END) > 0 THEN 1 ELSE 0 END as has_error
+			CASE WHEN SUM(CASE WHEN ` + isError + ` THEN 1 ELSE 0 END) > 0 THEN 1 ELSE 0 END as has_error
 		FROM events
+		WHERE project = ?
 		GROUP BY trace_id, service
 		ORDER BY start_time DESC
 		LIMIT ?
 	`
 
-	rows, err := s.db.Query(query, limit)
+	rows, err := s.db.Query(query, project, limit)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query traces: %w", err)
 	}
@@ -256,18 +326,18 @@ func (s *SqliteStore) GetTraces(limit int) ([]model.TraceInfo, error) {
 	return results, rows.Err()
 }
 
-func (s *SqliteStore) SearchEvents(query string, limit int) ([]model.Event, error) {
+func (s *SqliteStore) SearchEvents(project string, query string, limit int) ([]model.Event, error) {
 	// Search in name, service, and trace_id fields
 	searchQuery := `
-		SELECT id, timestamp, level, service, name, trace_id, data
+		SELECT id, project, timestamp, level, service, name, trace_id, data
 		FROM events
-		WHERE name LIKE ? OR service LIKE ? OR trace_id LIKE ?
+		WHERE project = ? AND (name LIKE ? OR service LIKE ? OR trace_id LIKE ?)
 		ORDER BY timestamp DESC
 		LIMIT ?
 	`
 
 	likeQuery := "%" + query + "%"
-	rows, err := s.db.Query(searchQuery, likeQuery, likeQuery, likeQuery, limit)
+	rows, err := s.db.Query(searchQuery, project, likeQuery, likeQuery, likeQuery, limit)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search events: %w", err)
 	}
@@ -277,7 +347,7 @@ func (s *SqliteStore) SearchEvents(query string, limit int) ([]model.Event, erro
 	for rows.Next() {
 		var e model.Event
 		var dataStr sql.NullString
-		err := rows.Scan(&e.ID, &e.Timestamp, &e.Level, &e.Service, &e.Name, &e.TraceID, &dataStr)
+		err := rows.Scan(&e.ID, &e.Project, &e.Timestamp, &e.Level, &e.Service, &e.Name, &e.TraceID, &dataStr)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan event: %w", err)
 		}
@@ -295,15 +365,15 @@ func (s *SqliteStore) SearchEvents(query string, limit int) ([]model.Event, erro
 	return events, rows.Err()
 }
 
-func (s *SqliteStore) GetEventsByTraceID(traceID string) ([]model.Event, error) {
+func (s *SqliteStore) GetEventsByTraceID(project string, traceID string) ([]model.Event, error) {
 	query := `
-		SELECT id, timestamp, level, service, name, trace_id, data
+		SELECT id, project, timestamp, level, service, name, trace_id, data
 		FROM events
-		WHERE trace_id = ?
+		WHERE project = ? AND trace_id = ?
 		ORDER BY timestamp ASC
 	`
 
-	rows, err := s.db.Query(query, traceID)
+	rows, err := s.db.Query(query, project, traceID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query events by trace ID: %w", err)
 	}
@@ -313,7 +383,7 @@ func (s *SqliteStore) GetEventsByTraceID(traceID string) ([]model.Event, error)
 	for rows.Next() {
 		var e model.Event
 		var dataStr sql.NullString
-		err := rows.Scan(&e.ID, &e.Timestamp, &e.Level, &e.Service, &e.Name, &e.TraceID, &dataStr)
+		err := rows.Scan(&e.ID, &e.Project, &e.Timestamp, &e.Level, &e.Service, &e.Name, &e.TraceID, &dataStr)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan event: %w", err)
 		}
@@ -331,7 +401,7 @@ func (s *SqliteStore) GetEventsByTraceID(traceID string) ([]model.Event, error)
 	return events, rows.Err()
 }
 
-func (s *SqliteStore) GetThroughput(hours int) ([]model.ThroughputData, error) {
+func (s *SqliteStore) GetThroughput(project string, hours int) ([]model.ThroughputData, error) {
 	if hours <= 0 {
 		hours = 24
 	}
@@ -347,12 +417,12 @@ func (s *SqliteStore) GetThroughput(hours int) ([]model.ThroughputData, error) {
 			strftime('%H:00', h.hour_start) as time,
 			COUNT(e.id) as events
 		FROM hours h
-		LEFT JOIN events e ON e.timestamp >= h.hour_start AND e.timestamp < datetime(h.hour_start, '+1 hour')
+		LEFT JOIN events e ON e.project = ? AND e.timestamp >= h.hour_start AND e.timestamp < datetime(h.hour_start, '+1 hour')
 		GROUP BY h.hour_start
 		ORDER BY h.hour_start ASC
 	`
 
-	rows, err := s.db.Query(query, hours)
+	rows, err := s.db.Query(query, hours, project)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query throughput: %w", err)
 	}
@@ -0,0 +1,293 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/xonoxc/scopion/internal/model"
+	"github.com/xonoxc/scopion/internal/store/migrations"
+
+	migrateable "github.com/xonoxc/scopion/internal/store/migratable"
+)
+
+func newTestStore(t *testing.T) *SqliteStore {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	m := migrations.New(path)
+	if err := m.Migrate(migrateable.SQLITE, migrations.GetAll()); err != nil {
+		t.Fatal(err)
+	}
+	/*
+	* runs on every server start, must not fail on a migrated db
+	**/
+	if err := m.Migrate(migrateable.SQLITE, migrations.Idempotent()); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+func TestQueriesAreScopedByProject(t *testing.T) {
+	s := newTestStore(t)
+
+	now := time.Now()
+	events := []model.Event{
+		{ID: "1", Project: "shop", Timestamp: now, Level: "error", Service: "checkout", Name: "payment failed", TraceID: "t1"},
+		{ID: "2", Project: "shop", Timestamp: now, Level: "info", Service: "checkout", Name: "payment ok", TraceID: "t2"},
+		{ID: "3", Project: "blog", Timestamp: now, Level: "info", Service: "checkout", Name: "payment ok", TraceID: "t1"},
+	}
+	if err := s.AppendBatch(events); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Append(model.Event{ID: "4", Timestamp: now, Level: "info", Service: "cron", Name: "tick"}); err != nil {
+		t.Fatal(err)
+	}
+
+	recent, err := s.Recent("shop", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recent) != 2 || recent[0].Project != "shop" {
+		t.Errorf("Expected 2 shop events, got %+v", recent)
+	}
+
+	defaults, err := s.Recent(model.DEFAULT_PROJECT, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(defaults) != 1 || defaults[0].ID != "4" {
+		t.Errorf("Expected events without a project in the default project, got %+v", defaults)
+	}
+
+	stats, err := s.GetStats("blog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalEvents != 1 || stats.ErrorRate != 0 {
+		t.Errorf("Unexpected blog stats %+v", stats)
+	}
+
+	found, err := s.SearchEvents("blog", "payment", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].ID != "3" {
+		t.Errorf("Expected only the blog event, got %+v", found)
+	}
+
+	trace, err := s.GetEventsByTraceID("shop", "t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trace) != 1 || trace[0].ID != "1" {
+		t.Errorf("Expected only the shop span of t1, got %+v", trace)
+	}
+
+	errorsBy, err := s.GetErrorsByService("blog", 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errorsBy) != 0 {
+		t.Errorf("Expected no blog errors, got %+v", errorsBy)
+	}
+}
+
+func TestDuplicateIDsAreSkipped(t *testing.T) {
+	s := newTestStore(t)
+
+	e := model.Event{ID: "dup", Timestamp: time.Now(), Level: "info", Service: "api", Name: "request"}
+	if err := s.Append(e); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Append(e); err != nil {
+		t.Fatalf("Expected appending a stored id to succeed, got %v", err)
+	}
+	if err := s.AppendBatch([]model.Event{e}); err != nil {
+		t.Fatalf("Expected replaying a batch to succeed, got %v", err)
+	}
+
+	events, err := s.Recent(model.DEFAULT_PROJECT, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Errorf("Expected 1 event, got %d", len(events))
+	}
+}
+
+func TestMigrateSqliteNewAndExistingDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.db")
+
+	/*
+	* the second run finds the schema the first one created
+	**/
+	for range 2 {
+		if err := migrations.MigrateSqlite(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Append(model.Event{ID: "1", Timestamp: time.Now(), Level: "info", Service: "api", Name: "request"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDuplicateIDsAreScopedByProject(t *testing.T) {
+	s := newTestStore(t)
+
+	e := model.Event{ID: "same", Project: "shop", Timestamp: time.Now(), Level: "info", Service: "api", Name: "request"}
+	other := e
+	other.Project = "blog"
+
+	inserted, err := s.AppendBatchInserted([]model.Event{e, other, e})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inserted) != 2 || inserted[0].Project != "shop" || inserted[1].Project != "blog" {
+		t.Errorf("Expected the first event of each project to be inserted, got %+v", inserted)
+	}
+
+	inserted, err = s.AppendBatchInserted([]model.Event{other})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inserted) != 0 {
+		t.Errorf("Expected a retry to insert nothing, got %+v", inserted)
+	}
+
+	for _, project := range []string{"shop", "blog"} {
+		events, err := s.Recent(project, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 1 {
+			t.Errorf("%s: expected 1 event, got %d", project, len(events))
+		}
+	}
+}
+
+func TestScopeEventIDMigrationKeepsEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "old.db")
+
+	/*
+	* a database from before ids were scoped to their project
+	**/
+	all := migrations.GetAll()
+	if err := migrations.New(path).Migrate(migrateable.SQLITE, all[:len(all)-1]); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	e := model.Event{ID: "old", Project: "shop", Timestamp: time.Now(), Level: "info", Service: "api", Name: "request", Data: map[string]any{"k": "v"}}
+	if err := s.Append(e); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrations.New(path).Migrate(migrateable.SQLITE, migrations.Idempotent()); err != nil {
+		t.Fatal(err)
+	}
+
+	e.Project = "blog"
+	if err := s.Append(e); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, project := range []string{"shop", "blog"} {
+		events, err := s.Recent(project, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 1 || events[0].Data["k"] != "v" {
+			t.Errorf("%s: expected the event with its data, got %+v", project, events)
+		}
+	}
+}
+
+func TestFatalEventsCountAsErrors(t *testing.T) {
+	s := newTestStore(t)
+
+	now := time.Now()
+	events := []model.Event{
+		{ID: "1", Timestamp: now, Level: model.LEVEL_FATAL, Service: "api", Name: "crashed", TraceID: "t1"},
+		{ID: "2", Timestamp: now, Level: model.LEVEL_INFO, Service: "api", Name: "request", TraceID: "t2"},
+	}
+	if err := s.AppendBatch(events); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := s.GetStats(model.DEFAULT_PROJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.ErrorRate != 50 {
+		t.Errorf("Expected an error rate of 50%%, got %v", stats.ErrorRate)
+	}
+
+	byService, err := s.GetErrorsByService(model.DEFAULT_PROJECT, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byService) != 1 || byService[0].Count != 1 {
+		t.Errorf("Expected 1 error for api, got %+v", byService)
+	}
+
+	services, err := s.GetServices(model.DEFAULT_PROJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 1 || services[0].ErrorCount != 1 {
+		t.Errorf("Expected api to have 1 error, got %+v", services)
+	}
+
+	traces, err := s.GetTraces(model.DEFAULT_PROJECT, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, trace := range traces {
+		if trace.HasError != (trace.ID == "t1") {
+			t.Errorf("Unexpected has_error for trace %+v", trace)
+		}
+	}
+}
+
+func TestStatsExtrapolateSampledEvents(t *testing.T) {
+	s := newTestStore(t)
+
+	events := []model.Event{
+		{ID: "1", Timestamp: time.Now(), Level: "info", Service: "api", Name: "request", SampleRate: 0.1},
+		{ID: "2", Timestamp: time.Now(), Level: "error", Service: "api", Name: "request"},
+	}
+	if err := s.AppendBatch(events); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := s.GetStats(model.DEFAULT_PROJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.StoredEvents != 2 || stats.TotalEvents != 11 {
+		t.Errorf("Expected 2 stored events standing for 11, got %+v", stats)
+	}
+	if stats.ErrorRate < 9 || stats.ErrorRate > 9.1 {
+		t.Errorf("Expected an error rate of 1 in 11, got %v", stats.ErrorRate)
+	}
+}
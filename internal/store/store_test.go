@@ -41,7 +41,7 @@ func TestAppend(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	events, err := s.Recent(10)
+	events, err := s.Recent(model.DEFAULT_PROJECT, 10)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -107,7 +107,7 @@ func TestSearchEvents(t *testing.T) {
 	}
 
 	// Search by service
-	results, err := s.SearchEvents("auth", 10)
+	results, err := s.SearchEvents(model.DEFAULT_PROJECT, "auth", 10)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -119,7 +119,7 @@ func TestSearchEvents(t *testing.T) {
 	}
 
 	// Search by trace ID
-	results, err = s.SearchEvents("trace1", 10)
+	results, err = s.SearchEvents(model.DEFAULT_PROJECT, "trace1", 10)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -128,7 +128,7 @@ func TestSearchEvents(t *testing.T) {
 	}
 
 	// Search with no matches
-	results, err = s.SearchEvents("nonexistent", 10)
+	results, err = s.SearchEvents(model.DEFAULT_PROJECT, "nonexistent", 10)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -177,7 +177,7 @@ func TestAppendWithCustomData(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	events, err := s.Recent(10)
+	events, err := s.Recent(model.DEFAULT_PROJECT, 10)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -259,7 +259,7 @@ func TestGetEventsByTraceID(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	events, err := s.GetEventsByTraceID(traceID)
+	events, err := s.GetEventsByTraceID(model.DEFAULT_PROJECT, traceID)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -330,7 +330,7 @@ func TestGetTraces(t *testing.T) {
 		}
 	}
 
-	traces, err := s.GetTraces(10)
+	traces, err := s.GetTraces(model.DEFAULT_PROJECT, 10)
 	if err != nil {
 		t.Fatalf("GetTraces failed: %v", err)
 	}
@@ -0,0 +1,309 @@
+package syslog
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/xonoxc/scopion/internal/model"
+)
+
+var ErrInvalidMessage = errors.New("invalid syslog message")
+
+const nilValue = "-"
+
+/*
+* longer messages become the name cut to this, the default
+* ingest field limit, and are kept whole under data.message
+**/
+const maxNameLength = 256
+
+/*
+* a parsed syslog message, RFC 3164 messages leave
+* MsgID and StructuredData empty
+**/
+type Message struct {
+	Facility       int
+	Severity       int
+	Timestamp      time.Time
+	Hostname       string
+	AppName        string
+	ProcID         string
+	MsgID          string
+	StructuredData map[string]map[string]string
+	Message        string
+}
+
+/*
+* Parse accepts both RFC 5424 and the older BSD (RFC 3164)
+* format, telling them apart by the version digit after PRI
+**/
+func Parse(raw []byte) (*Message, error) {
+	line := strings.TrimRight(string(raw), "\r\n\x00")
+
+	pri, rest, err := parsePRI(line)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &Message{
+		Facility: pri / 8,
+		Severity: pri % 8,
+	}
+
+	if strings.HasPrefix(rest, "1 ") {
+		err = parse5424(msg, rest[2:])
+	} else {
+		parse3164(msg, rest)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+func parsePRI(line string) (int, string, error) {
+	if len(line) < 3 || line[0] != '<' {
+		return 0, "", fmt.Errorf("%w: missing PRI", ErrInvalidMessage)
+	}
+
+	end := strings.IndexByte(line, '>')
+	if end < 2 || end > 4 {
+		return 0, "", fmt.Errorf("%w: malformed PRI", ErrInvalidMessage)
+	}
+
+	pri, err := strconv.Atoi(line[1:end])
+	if err != nil || pri < 0 || pri > 191 {
+		return 0, "", fmt.Errorf("%w: malformed PRI", ErrInvalidMessage)
+	}
+
+	return pri, line[end+1:], nil
+}
+
+/*
+* TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA [MSG]
+**/
+func parse5424(msg *Message, rest string) error {
+	fields := make([]string, 5)
+	for i := range fields {
+		var ok bool
+		fields[i], rest, ok = strings.Cut(rest, " ")
+		if !ok && i < len(fields)-1 {
+			return fmt.Errorf("%w: truncated header", ErrInvalidMessage)
+		}
+	}
+
+	if fields[0] != nilValue {
+		ts, err := time.Parse(time.RFC3339Nano, fields[0])
+		if err != nil {
+			return fmt.Errorf("%w: bad timestamp: %v", ErrInvalidMessage, err)
+		}
+		msg.Timestamp = ts
+	}
+
+	msg.Hostname = orEmpty(fields[1])
+	msg.AppName = orEmpty(fields[2])
+	msg.ProcID = orEmpty(fields[3])
+	msg.MsgID = orEmpty(fields[4])
+
+	sd, rest, err := parseStructuredData(rest)
+	if err != nil {
+		return err
+	}
+	msg.StructuredData = sd
+
+	msg.Message = strings.TrimPrefix(strings.TrimPrefix(rest, " "), "\ufeff")
+	return nil
+}
+
+/*
+* "-" or one or more [id key="value" ...] elements,
+* values may escape '"', '\' and ']' with a backslash
+**/
+func parseStructuredData(s string) (map[string]map[string]string, string, error) {
+	if s == "" {
+		return nil, "", nil
+	}
+	if strings.HasPrefix(s, nilValue) {
+		return nil, s[1:], nil
+	}
+	if s[0] != '[' {
+		return nil, "", fmt.Errorf("%w: bad structured data", ErrInvalidMessage)
+	}
+
+	sd := map[string]map[string]string{}
+	i := 0
+	for i < len(s) && s[i] == '[' {
+		i++
+		start := i
+		for i < len(s) && s[i] != ' ' && s[i] != ']' {
+			i++
+		}
+		id := s[start:i]
+		params := map[string]string{}
+
+		for i < len(s) && s[i] == ' ' {
+			i++
+			start = i
+			for i < len(s) && s[i] != '=' {
+				i++
+			}
+			key := s[start:i]
+
+			if i+1 >= len(s) || s[i+1] != '"' {
+				return nil, "", fmt.Errorf("%w: bad structured data param", ErrInvalidMessage)
+			}
+			i += 2
+
+			var value strings.Builder
+			for i < len(s) && s[i] != '"' {
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte(`"\]`, s[i+1]) >= 0 {
+					i++
+				}
+				value.WriteByte(s[i])
+				i++
+			}
+			if i >= len(s) {
+				return nil, "", fmt.Errorf("%w: unterminated structured data value", ErrInvalidMessage)
+			}
+			// skip closing quote
+			i++
+			params[key] = value.String()
+		}
+
+		if i >= len(s) || s[i] != ']' {
+			return nil, "", fmt.Errorf("%w: unterminated structured data", ErrInvalidMessage)
+		}
+		i++
+		sd[id] = params
+	}
+
+	return sd, s[i:], nil
+}
+
+/*
+* Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG
+* BSD syslog is loosely specified, anything that does not
+* fit is kept whole as the message
+**/
+func parse3164(msg *Message, rest string) {
+	const stampLen = len(time.Stamp)
+
+	if len(rest) > stampLen && rest[stampLen] == ' ' {
+		if ts, err := time.ParseInLocation(time.Stamp, rest[:stampLen], time.Local); err == nil {
+			now := time.Now()
+			ts = ts.AddDate(now.Year(), 0, 0)
+			// a december message read in january belongs to last year
+			if ts.After(now.Add(24 * time.Hour)) {
+				ts = ts.AddDate(-1, 0, 0)
+			}
+			msg.Timestamp = ts
+			rest = rest[stampLen+1:]
+
+			if host, after, ok := strings.Cut(rest, " "); ok {
+				msg.Hostname = host
+				rest = after
+			}
+		}
+	}
+
+	if tag, after, ok := strings.Cut(rest, ": "); ok && !strings.ContainsAny(tag, " ") {
+		if name, pid, hasPID := strings.Cut(tag, "["); hasPID {
+			msg.AppName = name
+			msg.ProcID = strings.TrimSuffix(pid, "]")
+		} else {
+			msg.AppName = tag
+		}
+		rest = after
+	}
+
+	msg.Message = rest
+}
+
+func orEmpty(s string) string {
+	if s == nilValue {
+		return ""
+	}
+	return s
+}
+
+/*
+* severity 0 - 7 onto the levels the dashboard knows,
+* emergency, alert and critical are fatal
+**/
+func Level(severity int) string {
+	switch {
+	case severity <= 2:
+		return model.LEVEL_FATAL
+	case severity == 3:
+		return model.LEVEL_ERROR
+	case severity == 4:
+		return model.LEVEL_WARN
+	case severity == 7:
+		return model.LEVEL_DEBUG
+	default:
+		return model.LEVEL_INFO
+	}
+}
+
+func (m *Message) ToEvent() model.Event {
+	service := m.AppName
+	if service == "" {
+		service = "syslog"
+	}
+
+	data := map[string]any{
+		"facility": m.Facility,
+		"severity": m.Severity,
+	}
+	if m.Hostname != "" {
+		data["hostname"] = m.Hostname
+	}
+	if m.ProcID != "" {
+		data["procid"] = m.ProcID
+	}
+	if m.MsgID != "" {
+		data["msgid"] = m.MsgID
+	}
+	for id, params := range m.StructuredData {
+		values := make(map[string]any, len(params))
+		for k, v := range params {
+			values[k] = v
+		}
+		data[id] = values
+	}
+
+	name := m.Message
+	if name == "" {
+		name = m.MsgID
+	}
+	if len(name) > maxNameLength {
+		data["message"] = name
+		name = truncate(name, maxNameLength)
+	}
+
+	return model.Event{
+		Timestamp: m.Timestamp,
+		Level:     Level(m.Severity),
+		Service:   service,
+		Name:      name,
+		Data:      data,
+	}
+}
+
+/*
+* cuts s to at most n bytes without splitting a rune
+**/
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,109 @@
+package syslog
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestParseRFC5424(t *testing.T) {
+	raw := `<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog 1234 ID47 [exampleSDID@32473 iut="3" eventSource="Application" note="a \"quoted\" \]"] An application event`
+
+	msg, err := Parse([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.Facility != 20 || msg.Severity != 5 {
+		t.Errorf("Expected facility 20 severity 5, got %d %d", msg.Facility, msg.Severity)
+	}
+	if !msg.Timestamp.Equal(time.Date(2003, 10, 11, 22, 14, 15, 3000000, time.UTC)) {
+		t.Errorf("Unexpected timestamp %v", msg.Timestamp)
+	}
+	if msg.Hostname != "mymachine.example.com" || msg.AppName != "evntslog" || msg.ProcID != "1234" || msg.MsgID != "ID47" {
+		t.Errorf("Unexpected header %+v", msg)
+	}
+
+	params := msg.StructuredData["exampleSDID@32473"]
+	if params["eventSource"] != "Application" {
+		t.Errorf("Expected eventSource Application, got %v", params)
+	}
+	if params["note"] != `a "quoted" ]` {
+		t.Errorf("Expected escapes to be resolved, got %q", params["note"])
+	}
+	if msg.Message != "An application event" {
+		t.Errorf("Unexpected message %q", msg.Message)
+	}
+
+	e := msg.ToEvent()
+	if e.Service != "evntslog" || e.Level != "info" || e.Name != "An application event" {
+		t.Errorf("Unexpected event %+v", e)
+	}
+	if sd, ok := e.Data["exampleSDID@32473"].(map[string]any); !ok || sd["iut"] != "3" {
+		t.Errorf("Expected structured data in event data, got %v", e.Data)
+	}
+}
+
+func TestParseRFC5424NilValues(t *testing.T) {
+	msg, err := Parse([]byte(`<11>1 - - - - - -`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !msg.Timestamp.IsZero() || msg.AppName != "" || msg.Message != "" {
+		t.Errorf("Expected empty fields, got %+v", msg)
+	}
+
+	e := msg.ToEvent()
+	if e.Service != "syslog" || e.Level != "error" {
+		t.Errorf("Expected fallback service and error level, got %+v", e)
+	}
+}
+
+func TestParseRFC3164(t *testing.T) {
+	msg, err := Parse([]byte("<34>Oct 11 22:14:15 mymachine su[230]: 'su root' failed for lonvick on /dev/pts/8\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.Severity != 2 || msg.Hostname != "mymachine" || msg.AppName != "su" || msg.ProcID != "230" {
+		t.Errorf("Unexpected message %+v", msg)
+	}
+	if msg.Timestamp.Month() != time.October || msg.Timestamp.Day() != 11 {
+		t.Errorf("Unexpected timestamp %v", msg.Timestamp)
+	}
+	if msg.Message != "'su root' failed for lonvick on /dev/pts/8" {
+		t.Errorf("Unexpected message %q", msg.Message)
+	}
+}
+
+func TestParseRejectsGarbage(t *testing.T) {
+	for _, raw := range []string{"", "hello", "<999>1 - - - - - -", "<13>1 2003-10-11T22:14:15Z host"} {
+		if _, err := Parse([]byte(raw)); err == nil {
+			t.Errorf("Expected error for %q", raw)
+		}
+	}
+}
+
+func TestLevel(t *testing.T) {
+	tests := map[int]string{0: "fatal", 1: "fatal", 2: "fatal", 3: "error", 4: "warn", 5: "info", 6: "info", 7: "debug"}
+	for severity, want := range tests {
+		if got := Level(severity); got != want {
+			t.Errorf("Level(%d) = %s, want %s", severity, got, want)
+		}
+	}
+}
+
+func TestToEventKeepsLongMessages(t *testing.T) {
+	long := strings.Repeat("é", 200)
+	msg, err := Parse([]byte("<14>1 - host app - - - " + long))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := msg.ToEvent()
+	if len(e.Name) != maxNameLength || !utf8.ValidString(e.Name) || e.Data["message"] != long {
+		t.Errorf("Expected a cut name and the whole message in data, got %q %v", e.Name, e.Data["message"])
+	}
+}
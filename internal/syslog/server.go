@@ -0,0 +1,246 @@
+package syslog
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/xonoxc/scopion/internal/model"
+)
+
+/*
+* largest message we accept, anything announcing more
+* on an octet counted TCP frame closes the connection
+**/
+const maxMessageSize = 64 * 1024
+
+type Config struct {
+	UDPAddr string
+	TCPAddr string
+}
+
+func (c Config) Enabled() bool {
+	return c.UDPAddr != "" || c.TCPAddr != ""
+}
+
+/*
+* Server receives syslog over UDP and / or TCP and hands
+* every parsed message to handle as an event
+**/
+type Server struct {
+	config Config
+	handle func(model.Event)
+
+	udp net.PacketConn
+	tcp net.Listener
+
+	/*
+	* closed is set under mu so a connection accepted while
+	* Close runs is either tracked and closed, or turned away
+	**/
+	mu     sync.Mutex
+	closed bool
+	conns  map[net.Conn]struct{}
+
+	wg sync.WaitGroup
+}
+
+func NewServer(config Config, handle func(model.Event)) *Server {
+	return &Server{
+		config: config,
+		handle: handle,
+		conns:  map[net.Conn]struct{}{},
+	}
+}
+
+func (s *Server) Start() error {
+	if s.config.UDPAddr != "" {
+		conn, err := net.ListenPacket("udp", s.config.UDPAddr)
+		if err != nil {
+			return fmt.Errorf("syslog udp listen: %w", err)
+		}
+		s.udp = conn
+
+		s.wg.Add(1)
+		go s.serveUDP()
+	}
+
+	if s.config.TCPAddr != "" {
+		ln, err := net.Listen("tcp", s.config.TCPAddr)
+		if err != nil {
+			s.Close()
+			return fmt.Errorf("syslog tcp listen: %w", err)
+		}
+		s.tcp = ln
+
+		s.wg.Add(1)
+		go s.serveTCP()
+	}
+
+	return nil
+}
+
+func (s *Server) Close() error {
+	var errs []error
+	if s.udp != nil {
+		errs = append(errs, s.udp.Close())
+	}
+	if s.tcp != nil {
+		errs = append(errs, s.tcp.Close())
+	}
+
+	s.mu.Lock()
+	s.closed = true
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+func (s *Server) UDPAddr() net.Addr {
+	if s.udp == nil {
+		return nil
+	}
+	return s.udp.LocalAddr()
+}
+
+func (s *Server) TCPAddr() net.Addr {
+	if s.tcp == nil {
+		return nil
+	}
+	return s.tcp.Addr()
+}
+
+func (s *Server) serveUDP() {
+	defer s.wg.Done()
+
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, _, err := s.udp.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("syslog: udp read failed: %v", err)
+			continue
+		}
+
+		s.dispatch(buf[:n])
+	}
+}
+
+func (s *Server) serveTCP() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.tcp.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("syslog: tcp accept failed: %v", err)
+			continue
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	r := bufio.NewReaderSize(conn, maxMessageSize)
+	for {
+		frame, err := readFrame(r)
+		if len(bytes.TrimSpace(frame)) > 0 {
+			s.dispatch(frame)
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Printf("syslog: tcp connection from %s closed: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+	}
+}
+
+/*
+* RFC 6587 framing: octet counted ("LEN SP MSG") when the frame
+* starts with a digit, newline delimited otherwise.
+* r must be sized maxMessageSize, a longer frame closes the connection
+**/
+func readFrame(r *bufio.Reader) ([]byte, error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	if first[0] >= '0' && first[0] <= '9' {
+		prefix, err := readUntil(r, ' ')
+		if err != nil {
+			return nil, err
+		}
+		lenStr := string(prefix)
+
+		n, err := strconv.Atoi(lenStr[:len(lenStr)-1])
+		if err != nil || n <= 0 || n > maxMessageSize {
+			return nil, fmt.Errorf("%w: bad frame length %q", ErrInvalidMessage, lenStr)
+		}
+
+		frame := make([]byte, n)
+		if _, err := io.ReadFull(r, frame); err != nil {
+			return nil, err
+		}
+		return frame, nil
+	}
+
+	return readUntil(r, '\n')
+}
+
+/*
+* like ReadBytes, but a frame that doesn't fit the buffer
+* is an error instead of growing without bound
+**/
+func readUntil(r *bufio.Reader, delim byte) ([]byte, error) {
+	line, err := r.ReadSlice(delim)
+	if errors.Is(err, bufio.ErrBufferFull) {
+		return nil, fmt.Errorf("%w: frame longer than %d bytes", ErrInvalidMessage, maxMessageSize)
+	}
+	return bytes.Clone(line), err
+}
+
+func (s *Server) dispatch(raw []byte) {
+	msg, err := Parse(raw)
+	if err != nil {
+		log.Printf("syslog: dropping message: %v", err)
+		return
+	}
+
+	s.handle(msg.ToEvent())
+}
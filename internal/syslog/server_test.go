@@ -0,0 +1,143 @@
+package syslog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xonoxc/scopion/internal/model"
+)
+
+func startTestServer(t *testing.T, cfg Config) (*Server, chan model.Event) {
+	t.Helper()
+
+	events := make(chan model.Event, 16)
+	s := NewServer(cfg, func(e model.Event) { events <- e })
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	return s, events
+}
+
+func expectEvent(t *testing.T, events chan model.Event, name string) {
+	t.Helper()
+
+	select {
+	case e := <-events:
+		if e.Name != name {
+			t.Errorf("Expected event %q, got %q", name, e.Name)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Timed out waiting for %q", name)
+	}
+}
+
+func TestServerTCPFraming(t *testing.T) {
+	s, events := startTestServer(t, Config{TCPAddr: "127.0.0.1:0"})
+
+	conn, err := net.Dial("tcp", s.TCPAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	counted := "<14>1 - host app - - - counted message"
+	payload := fmt.Sprintf("%d %s", len(counted), counted) + "<14>1 - host app - - - newline message\n"
+
+	if _, err := conn.Write([]byte(payload)); err != nil {
+		t.Fatal(err)
+	}
+
+	expectEvent(t, events, "counted message")
+	expectEvent(t, events, "newline message")
+}
+
+func TestServerUDP(t *testing.T) {
+	s, events := startTestServer(t, Config{UDPAddr: "127.0.0.1:0"})
+
+	conn, err := net.Dial("udp", s.UDPAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("<11>Oct 11 22:14:15 router kernel: link down")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case e := <-events:
+		if e.Service != "kernel" || e.Level != "error" || e.Name != "link down" {
+			t.Errorf("Unexpected event %+v", e)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for udp event")
+	}
+}
+
+func TestServerClosesConnectionOnOversizedFrame(t *testing.T) {
+	s, events := startTestServer(t, Config{TCPAddr: "127.0.0.1:0"})
+
+	conn, err := net.Dial("tcp", s.TCPAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	/*
+	* no newline ever comes, the server must not keep buffering
+	**/
+	go conn.Write([]byte("<14>1 - host app - - - " + strings.Repeat("x", 2*maxMessageSize)))
+
+	/*
+	* closed with unread data the connection may be reset instead
+	* of ending cleanly, only running into the deadline is a failure
+	**/
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := io.Copy(io.Discard, conn); errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatal("Expected the server to close the connection")
+	}
+
+	select {
+	case e := <-events:
+		t.Errorf("Expected nothing from an oversized frame, got %+v", e)
+	default:
+	}
+}
+
+func TestServerCloseWhileConnecting(t *testing.T) {
+	s := NewServer(Config{TCPAddr: "127.0.0.1:0"}, func(model.Event) {})
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	addr := s.TCPAddr().String()
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if conn, err := net.Dial("tcp", addr); err == nil {
+				defer conn.Close()
+				conn.Write([]byte("<14>1 - host app - - - message\n"))
+			}
+		}()
+	}
+
+	s.Close()
+	wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.conns) != 0 {
+		t.Errorf("Expected every connection closed, %d still open", len(s.conns))
+	}
+}